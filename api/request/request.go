@@ -6,33 +6,77 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
+// RefreshRequest 刷新令牌请求参数
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
 // FindVacantSeatsReq 查找今天的空座位
 type FindVacantSeatsReq struct {
-	IsTomorrow *bool  `json:"is_tomorrow" binding:"required"`
+	IsTomorrow *bool  `json:"is_tomorrow,omitempty"` // 可选参数,不指定 date 时查询今天或明天
+	Date       string `json:"date,omitempty"`        // 可选参数,查询日期,例如 2025-11-24,指定时忽略 is_tomorrow
 	StartTime  string `json:"start_time" binding:"required"`
 	EndTime    string `json:"end_time" binding:"required"`
 	KeyWord    string `json:"key_word,omitempty"` // 可选参数，模糊搜索关键词
+	Room       string `json:"room,omitempty"`     // 可选参数,只查询这个房间,房间ID或房间名称
+	Floor      string `json:"floor,omitempty"`    // 可选参数,只查询这一层的房间
 }
 
 type IsInLibraryReq struct {
 	StudentName string `json:"student_name" binding:"required"` // 学生姓名
+	Room        string `json:"room,omitempty"`                  // 可选参数,只查询这个房间,房间ID或房间名称
+	Floor       string `json:"floor,omitempty"`                 // 可选参数,只查询这一层的房间
 }
 
 type SeatToNameReq struct {
 	SeatName   string `json:"seat_name" binding:"required"` // 座位名称,例如 N1224
-	IsTomorrow *bool  `json:"is_tomorrow" binding:"required"`
+	IsTomorrow *bool  `json:"is_tomorrow,omitempty"`        // 可选参数,不指定 date 时查询今天或明天
+	Date       string `json:"date,omitempty"`               // 可选参数,查询日期,例如 2025-11-24,指定时忽略 is_tomorrow
+	Room       string `json:"room,omitempty"`               // 可选参数,只查询这个房间,房间ID或房间名称
+	Floor      string `json:"floor,omitempty"`              // 可选参数,只查询这一层的房间
 }
 
 type GarbReq struct {
 	StartTime  string `json:"start_time" binding:"required"`
 	EndTime    string `json:"end_time" binding:"required"`
-	IsTomorrow *bool  `json:"is_tomorrow" binding:"required"`
-	SeatID     string `json:"seat_id,omitempty"` // 可选参数,指定座位ID,不指定则自动选择
+	IsTomorrow *bool  `json:"is_tomorrow,omitempty"` // 可选参数,不指定 date 时预约今天或明天
+	Date       string `json:"date,omitempty"`        // 可选参数,预约日期,例如 2025-11-24,指定时忽略 is_tomorrow
+	SeatID     string `json:"seat_id,omitempty"`     // 可选参数,指定座位名称(例如 N1224)或座位ID,不指定则自动选择
+	KeyWord    string `json:"key_word,omitempty"`    // 可选参数,自动选择座位时的模糊搜索关键词
+	Room       string `json:"room,omitempty"`        // 可选参数,自动选择座位时只选这个房间,房间ID或房间名称
+	Floor      string `json:"floor,omitempty"`       // 可选参数,自动选择座位时只选这一层的房间
+}
+
+type SeatLookupReq struct {
+	KeyWord string `form:"key_word" json:"key_word,omitempty"` // 可选参数,座位名称模糊搜索关键词
+	Room    string `form:"room" json:"room,omitempty"`         // 可选参数,只查询这个房间,房间ID或房间名称
+	Floor   string `form:"floor" json:"floor,omitempty"`       // 可选参数,只查询这一层的房间
+}
+
+type GarbBurstReq struct {
+	StartTime string `json:"start_time" binding:"required"`
+	EndTime   string `json:"end_time" binding:"required"`
+	SeatID    string `json:"seat_id" binding:"required"` // 座位名称(例如 N1224)或座位ID
+	ReleaseAt string `json:"release_at,omitempty"`       // 可选参数,放座时刻,例如 2025-11-24 18:00:00,默认今天 18:00
 }
 
 type ReserveReq struct {
-	Data      string `json:"data" binding:"required"`
+	Data      string `json:"data" binding:"required"` // 预约日期,例如 2025-11-24
 	StartTime string `json:"start_time" binding:"required"`
 	EndTime   string `json:"end_time" binding:"required"`
-	KeyWord   string `json:"key_word,omitempty"`
+	KeyWord   string `json:"key_word,omitempty"` // 可选参数,未指定座位时按关键词选择空座位
+	SeatID    string `json:"seat_id,omitempty"`  // 可选参数,指定座位名称(例如 N1224)或座位ID
+}
+
+type CancelReserveReq struct {
+	JobID string `json:"job_id" binding:"required"` // 预约任务ID
+}
+
+type CancelReservationReq struct {
+	RsvID string `json:"rsv_id,omitempty"` // 可选参数,预约记录ID,指定时只取消这一条
+	Date  string `json:"date,omitempty"`   // 可选参数,不指定 rsv_id 时取消这一天的所有预约,例如 2025-11-24
+}
+
+type EvictSessionReq struct {
+	UserID string `json:"user_id" binding:"required"` // 学号
 }
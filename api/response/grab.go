@@ -24,3 +24,79 @@ type Occupant struct {
 type SearchResp struct {
 	Data []Seat `json:"data"`
 }
+
+// GrabResult 抢座结果
+type GrabResult struct {
+	SeatID   string `json:"seat_id"`         // 预约成功的座位ID
+	Title    string `json:"title,omitempty"` // 座位名称，例如 N1224
+	Attempts int    `json:"attempts"`        // 尝试预约的次数
+}
+
+// BurstResult 放座时刻连发抢座的结果
+type BurstResult struct {
+	SeatID             string         `json:"seat_id"`
+	Success            bool           `json:"success"`
+	ReleaseAt          string         `json:"release_at"`           // 放座时刻（服务器时间）
+	ClockOffsetMs      int64          `json:"clock_offset_ms"`      // 服务器时钟 - 本地时钟
+	ClockUncertaintyMs int64          `json:"clock_uncertainty_ms"` // 时钟偏差的误差范围
+	Attempts           []BurstAttempt `json:"attempts"`
+}
+
+// BurstAttempt 单次预约请求的时间信息
+type BurstAttempt struct {
+	Index     int    `json:"index"`
+	PlannedMs int64  `json:"planned_ms"` // 计划发出时间相对放座时刻的偏移（服务器时间）
+	SentMs    int64  `json:"sent_ms"`    // 实际发出时间相对放座时刻的偏移（服务器时间）
+	LatencyMs int64  `json:"latency_ms"` // 请求耗时
+	Success   bool   `json:"success"`
+	Msg       string `json:"msg"`
+}
+
+// SeatDevice 座位名称与设备ID、区域的对应关系
+type SeatDevice struct {
+	Title string `json:"title"` // 座位名称，例如 N1224
+	DevId string `json:"devId"`
+	Room  string `json:"room"` // 座位所在区域ID
+}
+
+// Room 可以预约座位的房间
+type Room struct {
+	ID        string `json:"id"`         // kjyy 的 room_id
+	Name      string `json:"name"`       // 房间名称
+	Branch    string `json:"branch"`     // 所在分馆
+	Floor     string `json:"floor"`      // 所在楼层
+	OpenTime  string `json:"open_time"`  // 开放时间，例如 8:00
+	CloseTime string `json:"close_time"` // 关闭时间，例如 22:00
+}
+
+// CancelResult 取消预约的结果
+type CancelResult struct {
+	Canceled []string `json:"canceled"` // 已取消的预约记录ID
+}
+
+// Reservation 个人预约记录中的一条预约
+type Reservation struct {
+	RsvID     string   `json:"rsv_id,omitempty"` // 预约记录ID，取消预约时使用，已结束的预约没有
+	Title     string   `json:"title"`            // 座位名称，例如 N1224
+	Room      string   `json:"room"`             // 区域名称，例如 南湖分馆一楼
+	Owner     string   `json:"owner"`
+	Start     string   `json:"start"` // 例如 2025-11-24 19:00
+	End       string   `json:"end"`
+	CreatedAt string   `json:"created_at"`
+	Reserved  bool     `json:"reserved"` // 预约成功
+	Effected  bool     `json:"effected"` // 已生效，false 表示未生效
+	Approved  bool     `json:"approved"` // 审核通过
+	States    []string `json:"states"`   // 原始状态，例如 预约成功、未生效、审核通过
+}
+
+// PoolSession cookie 池中缓存的图书馆会话
+type PoolSession struct {
+	UserID   string `json:"user_id"`
+	ExpireAt string `json:"expire_at"` // 过期时间 2006-01-02 15:04:05
+	Expired  bool   `json:"expired"`   // 已过期，等待清理或下次请求时重新登录
+}
+
+// FlushResult 清空 cookie 池的结果
+type FlushResult struct {
+	Evicted int `json:"evicted"` // 被移除的会话数
+}
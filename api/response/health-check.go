@@ -2,42 +2,78 @@ package response
 
 // HealthCheckResponse 健康检查接口的响应结构体
 type HealthCheckResponse struct {
-	// Status 服务状态，例如 "ok"
+	// Status 服务状态，"ok"、redis 不可用时的 "degraded"，或者图书馆上游熔断时的 "unavailable"
 	Status string `json:"status"`
 	// ResponseMs 响应耗时，单位毫秒
-	ResponseMs string `json:"response_ms"`
+	ResponseMs int64 `json:"response_ms"`
 	// System 系统资源使用情况
 	System SystemStats `json:"system"`
 	// Process 当前进程的运行状态
 	Process ProcessStats `json:"process"`
+	// Dependencies 依赖的外部服务状态
+	Dependencies []DependencyStatus `json:"dependencies"`
+}
+
+// LiveResponse 存活检查的响应，进程能够处理请求即返回
+type LiveResponse struct {
+	// Status 固定为 "ok"
+	Status string `json:"status"`
+	// UptimeSeconds 进程已经运行的秒数
+	UptimeSeconds int64 `json:"uptime_seconds"`
+}
+
+// ReadyResponse 就绪检查的响应
+type ReadyResponse struct {
+	// Status "ok"、redis 不可用时的 "degraded"，或者图书馆上游不可达时的 "unavailable"
+	Status string `json:"status"`
+	// Dependencies 每个依赖的检查结果
+	Dependencies []DependencyStatus `json:"dependencies"`
+}
+
+// DependencyStatus 外部依赖的状态
+type DependencyStatus struct {
+	// Name 依赖名称，例如 "redis"、"cas"、"kjyy"
+	Name string `json:"name"`
+	// Healthy 当前是否可用
+	Healthy bool `json:"healthy"`
+	// Required 不可用时服务是否无法就绪，redis 不可用时服务以降级模式运行
+	Required bool `json:"required"`
+	// LatencyMs 本次检查的耗时，单位毫秒，没有实时检查时为 0
+	LatencyMs int64 `json:"latency_ms"`
+	// Error 检查失败的原因
+	Error string `json:"error,omitempty"`
+	// Since 进入当前状态的时间，只有后台持续检查的依赖才有
+	Since string `json:"since,omitempty"`
+	// Circuit 图书馆上游的熔断器状态，"closed"、"open" 或 "half_open"
+	Circuit string `json:"circuit,omitempty"`
 }
 
 // SystemStats 系统级别的资源使用情况
 type SystemStats struct {
 	// CPUPercent CPU 使用率（%）
-	CPUPercent string `json:"cpu_percent"`
+	CPUPercent float64 `json:"cpu_percent"`
 	// MemoryTotalMB 内存总量（MB）
-	MemoryTotalMB string `json:"memory_total"`
+	MemoryTotalMB uint64 `json:"memory_total_mb"`
 	// MemoryUsedMB 已使用内存（MB）
-	MemoryUsedMB string `json:"memory_used"`
+	MemoryUsedMB uint64 `json:"memory_used_mb"`
 	// MemoryPercent 内存使用率（%）
-	MemoryPercent string `json:"memory_percent"`
+	MemoryPercent float64 `json:"memory_percent"`
 	// DiskTotalGB 磁盘总量（GB）
-	DiskTotalGB string `json:"disk_total"`
+	DiskTotalGB uint64 `json:"disk_total_gb"`
 	// DiskUsedGB 已使用磁盘（GB）
-	DiskUsedGB string `json:"disk_used"`
+	DiskUsedGB uint64 `json:"disk_used_gb"`
 	// DiskPercent 磁盘使用率（%）
-	DiskPercent string `json:"disk_percent"`
+	DiskPercent float64 `json:"disk_percent"`
 }
 
 // ProcessStats 当前 Go 进程的资源使用情况
 type ProcessStats struct {
 	// CPUPercent 进程 CPU 使用率（%）
-	CPUPercent string `json:"cpu_percent"`
+	CPUPercent float64 `json:"cpu_percent"`
 	// MemoryRSSMB 进程常驻内存（MB）
-	MemoryRSSMB string `json:"memory_rss_mb"`
+	MemoryRSSMB uint64 `json:"memory_rss_mb"`
 	// Goroutines 当前运行的 goroutine 数
-	Goroutines string `json:"goroutines"`
+	Goroutines int `json:"goroutines"`
 	// GoHeapAllocMB Go 堆分配的内存（MB）
-	GoHeapAllocMB string `json:"go_heap_alloc_mb"`
+	GoHeapAllocMB uint64 `json:"go_heap_alloc_mb"`
 }
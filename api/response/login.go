@@ -0,0 +1,8 @@
+package response
+
+// TokenPair 登录或刷新后返回的令牌
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`  // 访问令牌，放在 Authorization 头中使用
+	RefreshToken string `json:"refresh_token"` // 刷新令牌，只能使用一次，刷新后会返回新的刷新令牌
+	ExpiresIn    int64  `json:"expires_in"`    // 访问令牌的有效期，单位秒
+}
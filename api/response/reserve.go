@@ -0,0 +1,15 @@
+package response
+
+// ReserveJob 预约任务
+type ReserveJob struct {
+	ID        string `json:"id"`
+	Date      string `json:"date"`       // 预约日期 2006-01-02
+	StartTime string `json:"start_time"` // 开始时间 08:00
+	EndTime   string `json:"end_time"`   // 结束时间 22:00
+	KeyWord   string `json:"key_word,omitempty"`
+	SeatID    string `json:"seat_id,omitempty"`
+	FireAt    string `json:"fire_at"` // 任务执行时间
+	Status    string `json:"status"`  // pending, running, success, failed, canceled
+	Result    string `json:"result,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
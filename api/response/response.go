@@ -4,4 +4,13 @@ type Response struct {
 	Msg  string      `json:"msg"`
 	Code int         `json:"code"`
 	Data interface{} `json:"data"`
+	Meta *Meta       `json:"meta,omitempty"` // 附加信息，没有时不返回
+}
+
+// Meta 响应的附加信息
+type Meta struct {
+	// Warnings 部分区域查询失败时的警告，此时 Data 只包含成功区域的结果
+	Warnings []string `json:"warnings,omitempty"`
+	// SnapshotAgeMs 座位状态快照距今的时间，单位毫秒，为 0 表示刚从图书馆获取
+	SnapshotAgeMs int64 `json:"snapshot_age_ms"`
 }
@@ -2,24 +2,99 @@ package config
 
 import (
 	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/google/wire"
 	"github.com/spf13/viper"
 )
 
 var ProviderSet = wire.NewSet(
+	NewServerConfig,
 	NewJWTConfig,
 	NewMiddlewareConfig,
 	NewLogConfig,
 	NewLimiterConfig,
 	NewBasicAuthConfig,
 	NewRedisConfig,
+	NewBurstConfig,
+	NewSearchConfig,
+	NewDeadlineConfig,
+	NewUpstreamConfig,
+	NewTopologyConfig,
+	NewLibraryConfig,
 )
 
+type ServerConfig struct {
+	Addr              string `yaml:"addr"`              // 监听地址，默认 :8080
+	ReadTimeout       int    `yaml:"readTimeout"`       // 读取整个请求的超时时间(秒)
+	ReadHeaderTimeout int    `yaml:"readHeaderTimeout"` // 读取请求头的超时时间(秒)
+	WriteTimeout      int    `yaml:"writeTimeout"`      // 写响应的超时时间(秒)，需要大于放座连发的最长等待时间 burst.maxWait 加上 burstWriteMargin
+	IdleTimeout       int    `yaml:"idleTimeout"`       // keep-alive 连接的空闲超时时间(秒)
+	ShutdownTimeout   int    `yaml:"shutdownTimeout"`   // 收到退出信号后等待请求和预约任务结束的最长时间(秒)
+	CertFile          string `yaml:"certFile"`          // TLS 证书路径，与 keyFile 同时配置时启用 HTTPS
+	KeyFile           string `yaml:"keyFile"`           // TLS 私钥路径
+	// TrustedProxies 可信的反向代理地址或网段，只有来自这些地址的请求才使用 X-Forwarded-For 中的客户端 IP，为空时直接使用连接的地址
+	TrustedProxies []string `yaml:"trustedProxies"`
+}
+
+// burstWriteMargin 放座连发接口在等待 burst.maxWait 之外，登录、校准时钟和连发请求还需要的时间(秒)
+const burstWriteMargin = 30
+
+func NewServerConfig(burst *BurstConfig) *ServerConfig {
+	cfg := &ServerConfig{}
+	err := viper.UnmarshalKey("server", &cfg)
+	if err != nil {
+		panic(fmt.Sprintf("无法解析服务配置: %v", err))
+	}
+	if (cfg.CertFile == "") != (cfg.KeyFile == "") {
+		panic("服务配置无效: certFile 和 keyFile 需要同时配置")
+	}
+	if cfg.Addr == "" {
+		cfg.Addr = ":8080"
+	}
+	if cfg.ReadTimeout <= 0 {
+		cfg.ReadTimeout = 15
+	}
+	if cfg.ReadHeaderTimeout <= 0 {
+		cfg.ReadHeaderTimeout = 5
+	}
+	if cfg.WriteTimeout <= 0 {
+		cfg.WriteTimeout = 360
+	}
+	// 写超时不够时放座连发接口还没返回连接就被断开，客户端收不到抢座结果
+	if cfg.WriteTimeout <= burst.MaxWait+burstWriteMargin {
+		panic(fmt.Sprintf("服务配置无效: writeTimeout(%d秒) 需要大于 burst.maxWait(%d秒) + %d秒", cfg.WriteTimeout, burst.MaxWait, burstWriteMargin))
+	}
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = 60
+	}
+	if cfg.ShutdownTimeout <= 0 {
+		cfg.ShutdownTimeout = 30
+	}
+
+	return cfg
+}
+
 type JWTConfig struct {
-	JwtKey  string `yaml:"jwtKey"` //秘钥
-	EncKey  string `yaml:"encKey"`
-	Timeout int    `yaml:"timeout"` //过期时间
+	JwtKey        string `yaml:"jwtKey"` //秘钥
+	EncKey        string `yaml:"encKey"`
+	Timeout       int    `yaml:"timeout"`       //刷新令牌和会话的过期时间
+	AccessTimeout int    `yaml:"accessTimeout"` //访问令牌的过期时间
+	// Keys 签名密钥集合，为空时使用 jwtKey 作为唯一的 HS256 密钥
+	Keys     []JWTKeyConfig `yaml:"keys"`
+	KeyGrace int            `yaml:"keyGrace"` //旧密钥被替换后仍然可以用于校验的时间
+}
+
+type JWTKeyConfig struct {
+	Kid        string `yaml:"kid"`        //密钥ID，写入 token 的 kid 头
+	Alg        string `yaml:"alg"`        //HS256(默认)、EdDSA 或 RS256
+	Secret     string `yaml:"secret"`     //HS256 的密钥
+	PrivateKey string `yaml:"privateKey"` //EdDSA/RS256 私钥 PEM 文件路径，为空时只用于校验
+	PublicKey  string `yaml:"publicKey"`  //EdDSA/RS256 公钥 PEM 文件路径，为空时从私钥中得到
+	ActiveFrom string `yaml:"activeFrom"` //开始用于签名的时间，例如 2025-11-24 00:00:00，为空表示一直有效，多个签名密钥中只有最早的一个可以为空
 }
 
 func NewJWTConfig() *JWTConfig {
@@ -28,12 +103,27 @@ func NewJWTConfig() *JWTConfig {
 	if err != nil {
 		panic(fmt.Sprintf("无法解析 JWT 配置: %v", err))
 	}
+	if cfg.AccessTimeout <= 0 {
+		cfg.AccessTimeout = 900
+	}
+	if cfg.KeyGrace <= 0 {
+		// 默认等到用旧密钥签发的刷新令牌全部过期
+		cfg.KeyGrace = cfg.Timeout
+	}
 
 	return cfg
 }
 
 type MiddlewareConfig struct {
-	AllowedOrigins []string `yaml:"allowedOrigins"`
+	// AllowedOrigins 允许跨域访问的来源，支持三种写法：
+	// 精确匹配 https://example.com，通配子域名 https://*.example.com，
+	// 以 regex: 开头的正则表达式 regex:^https://.*\.example\.com$；单独的 * 表示允许所有来源
+	AllowedOrigins   []string `yaml:"allowedOrigins"`
+	AllowedMethods   []string `yaml:"allowedMethods"`   // 允许的请求方法
+	AllowedHeaders   []string `yaml:"allowedHeaders"`   // 允许的请求头
+	ExposedHeaders   []string `yaml:"exposedHeaders"`   // 允许前端读取的响应头
+	AllowCredentials bool     `yaml:"allowCredentials"` // 是否允许携带凭证（如 Cookies），不能与 * 同时使用
+	MaxAge           int      `yaml:"maxAge"`           // 预检请求的缓存时间(秒)
 }
 
 func NewMiddlewareConfig() *MiddlewareConfig {
@@ -42,6 +132,29 @@ func NewMiddlewareConfig() *MiddlewareConfig {
 	if err != nil {
 		panic(fmt.Sprintf("无法解析中间件配置: %v", err))
 	}
+	for _, origin := range cfg.AllowedOrigins {
+		if origin == "*" && cfg.AllowCredentials {
+			panic("中间件配置无效: allowedOrigins 为 * 时不能开启 allowCredentials")
+		}
+		if expr, ok := strings.CutPrefix(origin, "regex:"); ok {
+			if _, err = regexp.Compile(expr); err != nil {
+				panic(fmt.Sprintf("中间件配置无效: 无法解析来源正则 %s: %v", expr, err))
+			}
+		}
+	}
+	if len(cfg.AllowedMethods) == 0 {
+		cfg.AllowedMethods = []string{"GET", "POST", "OPTIONS"}
+	}
+	if len(cfg.AllowedHeaders) == 0 {
+		cfg.AllowedHeaders = []string{"Content-Type", "Authorization", "Origin"}
+	}
+	if len(cfg.ExposedHeaders) == 0 {
+		// 登录接口在 Authorization 中返回 token，限流中间件返回 RateLimit-* 和 Retry-After
+		cfg.ExposedHeaders = []string{"Authorization", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After"}
+	}
+	if cfg.MaxAge <= 0 {
+		cfg.MaxAge = 12 * 3600
+	}
 
 	return cfg
 }
@@ -68,6 +181,19 @@ type LimiterConfig struct {
 	Capacity     int `yaml:"capacity"`     // 令牌桶容量
 	FillInterval int `yaml:"fillInterval"` // 每秒补充令牌的次数
 	Quantum      int `yaml:"quantum"`      // 每次放置的令牌数
+	// KeyBy 令牌桶按哪些维度区分，可选 route、user、ip，默认按路由和用户，user 按访问令牌中的用户区分（学号的 HMAC），同一用户的多个会话共用令牌桶
+	// 未登录的请求没有用户，按 user 区分时改用客户端 IP
+	KeyBy []string `yaml:"keyBy"`
+	// Routes 单独配置的路由，未配置的字段使用上面的默认值
+	Routes []RouteLimiterConfig `yaml:"routes"`
+}
+
+type RouteLimiterConfig struct {
+	Path         string   `yaml:"path"` // 注册的路由，例如 /api/v1/garb/garb
+	Capacity     int      `yaml:"capacity"`
+	FillInterval int      `yaml:"fillInterval"`
+	Quantum      int      `yaml:"quantum"`
+	KeyBy        []string `yaml:"keyBy"`
 }
 
 func NewLimiterConfig() *LimiterConfig {
@@ -79,10 +205,47 @@ func NewLimiterConfig() *LimiterConfig {
 	if cfg.Capacity <= 0 || cfg.FillInterval <= 0 || cfg.Quantum <= 0 {
 		panic("限流器配置无效: capacity, fillInterval, 和 quantum 必须大于 0")
 	}
+	if len(cfg.KeyBy) == 0 {
+		cfg.KeyBy = []string{"route", "user"}
+	}
+	checkLimiterKeyBy(cfg.KeyBy)
+
+	for i := range cfg.Routes {
+		rc := &cfg.Routes[i]
+		if rc.Path == "" {
+			panic("限流器配置无效: routes 中的 path 不能为空")
+		}
+		if rc.Capacity < 0 || rc.FillInterval < 0 || rc.Quantum < 0 {
+			panic(fmt.Sprintf("限流器配置无效: 路由 %s 的 capacity, fillInterval, 和 quantum 不能小于 0", rc.Path))
+		}
+		if rc.Capacity == 0 {
+			rc.Capacity = cfg.Capacity
+		}
+		if rc.FillInterval == 0 {
+			rc.FillInterval = cfg.FillInterval
+		}
+		if rc.Quantum == 0 {
+			rc.Quantum = cfg.Quantum
+		}
+		if len(rc.KeyBy) == 0 {
+			rc.KeyBy = cfg.KeyBy
+		}
+		checkLimiterKeyBy(rc.KeyBy)
+	}
 
 	return cfg
 }
 
+func checkLimiterKeyBy(keyBy []string) {
+	for _, k := range keyBy {
+		switch k {
+		case "route", "user", "ip":
+		default:
+			panic(fmt.Sprintf("限流器配置无效: 不支持的 keyBy %q，可选 route、user、ip", k))
+		}
+	}
+}
+
 type BasicAuthConfig struct {
 	Username string `yaml:"username"`
 	Password string `yaml:"password"`
@@ -106,9 +269,11 @@ func NewBasicAuthConfig() []BasicAuthConfig {
 }
 
 type RedisConfig struct {
-	Addr     string `yaml:"addr"`
-	Password string `yaml:"password"`
-	DB       int    `yaml:"db"`
+	Addr          string `yaml:"addr"`
+	Password      string `yaml:"password"`
+	DB            int    `yaml:"db"`
+	Timeout       int    `yaml:"timeout"`       // 连接和读写超时(毫秒)，redis 不可用时请求尽快降级
+	CheckInterval int    `yaml:"checkInterval"` // 后台检查 redis 是否可用的间隔(秒)
 }
 
 func NewRedisConfig() *RedisConfig {
@@ -120,6 +285,291 @@ func NewRedisConfig() *RedisConfig {
 	if cfg.Addr == "" {
 		panic("Redis 配置无效: addr 不能为空")
 	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 500
+	}
+	if cfg.CheckInterval <= 0 {
+		cfg.CheckInterval = 5
+	}
+
+	return cfg
+}
+
+type BurstConfig struct {
+	Attempts    int `yaml:"attempts"`    // 放座时刻附近最多发起的预约请求数
+	IntervalMs  int `yaml:"intervalMs"`  // 相邻两次请求的间隔，单位毫秒
+	LeadMs      int `yaml:"leadMs"`      // 第一次请求比放座时刻提前的时间，单位毫秒
+	SyncSamples int `yaml:"syncSamples"` // 校准服务器时钟的采样次数
+	MaxWait     int `yaml:"maxWait"`     // 接口最多等待放座的时间，单位秒
+}
+
+func NewBurstConfig() *BurstConfig {
+	cfg := &BurstConfig{}
+	err := viper.UnmarshalKey("burst", &cfg)
+	if err != nil {
+		panic(fmt.Sprintf("无法解析抢座配置: %v", err))
+	}
+	// 未配置时使用默认值
+	if cfg.Attempts <= 0 {
+		cfg.Attempts = 6
+	}
+	if cfg.IntervalMs <= 0 {
+		cfg.IntervalMs = 100
+	}
+	if cfg.LeadMs < 0 {
+		cfg.LeadMs = 0
+	}
+	if cfg.SyncSamples <= 0 {
+		cfg.SyncSamples = 5
+	}
+	if cfg.MaxWait <= 0 {
+		cfg.MaxWait = 300
+	}
+
+	return cfg
+}
+
+type SearchConfig struct {
+	Parallelism   int  `yaml:"parallelism"`   // 同时查询的区域数
+	SnapshotTTL   int  `yaml:"snapshotTTL"`   // 座位状态快照的有效期，单位秒
+	SnapshotRedis bool `yaml:"snapshotRedis"` // 是否把快照同时保存到 redis，多实例部署时共用
+	DeviceRefresh int  `yaml:"deviceRefresh"` // 座位名称索引的刷新间隔，单位秒
+}
+
+func NewSearchConfig() *SearchConfig {
+	cfg := &SearchConfig{}
+	err := viper.UnmarshalKey("search", &cfg)
+	if err != nil {
+		panic(fmt.Sprintf("无法解析查询配置: %v", err))
+	}
+	if cfg.Parallelism <= 0 {
+		cfg.Parallelism = 4
+	}
+	if cfg.SnapshotTTL <= 0 {
+		cfg.SnapshotTTL = 10
+	}
+	if cfg.DeviceRefresh <= 0 {
+		cfg.DeviceRefresh = 3600
+	}
+
+	return cfg
+}
+
+// DeadlineConfig 每类操作访问图书馆的最长时间，超时后取消正在进行的上游请求
+type DeadlineConfig struct {
+	Search int `yaml:"search"` // 查询座位、预约记录，单位秒
+	Grab   int `yaml:"grab"`   // 预约和取消预约，单位秒
+	Login  int `yaml:"login"`  // 登录 CAS 和图书馆，单位秒
+	// Exchange 登录后用 CAS 会话换取图书馆会话，单位秒，与登录 CAS 分开计时，超时只影响 cookie 池的预热
+	Exchange int `yaml:"exchange"`
+}
+
+func NewDeadlineConfig() *DeadlineConfig {
+	cfg := &DeadlineConfig{}
+	err := viper.UnmarshalKey("deadline", &cfg)
+	if err != nil {
+		panic(fmt.Sprintf("无法解析超时配置: %v", err))
+	}
+	if cfg.Search <= 0 {
+		cfg.Search = 20
+	}
+	if cfg.Grab <= 0 {
+		cfg.Grab = 30
+	}
+	if cfg.Login <= 0 {
+		cfg.Login = 20
+	}
+	if cfg.Exchange <= 0 {
+		cfg.Exchange = 10
+	}
+	return cfg
+}
+
+// UpstreamConfig 访问图书馆上游的重试和熔断策略
+type UpstreamConfig struct {
+	Retry   RetryConfig   `yaml:"retry"`
+	Breaker BreakerConfig `yaml:"breaker"`
+}
+
+// RetryConfig 重试策略，只重试查询座位和预约记录这类幂等的读请求
+type RetryConfig struct {
+	MaxAttempts int  `yaml:"maxAttempts"` // 最多请求的次数，包括第一次，1 表示不重试
+	BaseDelayMs int  `yaml:"baseDelayMs"` // 第一次重试前的等待时间，之后每次翻倍，单位毫秒
+	MaxDelayMs  int  `yaml:"maxDelayMs"`  // 重试等待时间的上限，单位毫秒
+	Reserve     bool `yaml:"reserve"`     // 是否也重试预约请求 set_resv，请求可能已经成功只是响应丢失，默认不重试
+}
+
+// BreakerConfig 熔断策略，每个上游域名单独熔断
+type BreakerConfig struct {
+	Threshold   int `yaml:"threshold"`   // 连续失败多少次后熔断
+	OpenSeconds int `yaml:"openSeconds"` // 熔断后多久放行一个探测请求，单位秒
+}
+
+func NewUpstreamConfig() *UpstreamConfig {
+	cfg := &UpstreamConfig{}
+	err := viper.UnmarshalKey("upstream", &cfg)
+	if err != nil {
+		panic(fmt.Sprintf("无法解析上游配置: %v", err))
+	}
+	if cfg.Retry.MaxAttempts <= 0 {
+		cfg.Retry.MaxAttempts = 3
+	}
+	if cfg.Retry.BaseDelayMs <= 0 {
+		cfg.Retry.BaseDelayMs = 200
+	}
+	if cfg.Retry.MaxDelayMs < cfg.Retry.BaseDelayMs {
+		cfg.Retry.MaxDelayMs = max(2000, cfg.Retry.BaseDelayMs)
+	}
+	if cfg.Breaker.Threshold <= 0 {
+		cfg.Breaker.Threshold = 5
+	}
+	if cfg.Breaker.OpenSeconds <= 0 {
+		cfg.Breaker.OpenSeconds = 30
+	}
+	return cfg
+}
+
+// defaultRooms 没有配置房间并且没有开启自动发现时使用的房间
+var defaultRooms = []string{"101699191", "101699189", "101699187", "101699179"}
+
+// TopologyConfig 图书馆的房间、开放时间和可预约的天数
+type TopologyConfig struct {
+	OpenTime         string       `yaml:"openTime"`         // 默认开放时间，例如 8:00
+	CloseTime        string       `yaml:"closeTime"`        // 默认关闭时间，例如 22:00
+	HorizonDays      int          `yaml:"horizonDays"`      // 最多可以查询和预约今天之后几天的座位
+	QueueDays        int          `yaml:"queueDays"`        // 预约任务最多可以提前几天提交，任务在放座时执行，执行时仍然需要在 HorizonDays 之内
+	Discover         bool         `yaml:"discover"`         // 是否从 kjyy 的房间列表接口发现房间
+	DiscoverInterval int          `yaml:"discoverInterval"` // 重新发现房间的间隔，单位秒
+	Rooms            []RoomConfig `yaml:"rooms"`
+}
+
+// RoomConfig 单个房间的配置，开启自动发现时用于补充上游没有的分馆和楼层信息
+type RoomConfig struct {
+	ID        string `yaml:"id"`        // kjyy 的 room_id
+	Name      string `yaml:"name"`      // 房间名称
+	Branch    string `yaml:"branch"`    // 所在分馆
+	Floor     string `yaml:"floor"`     // 所在楼层
+	OpenTime  string `yaml:"openTime"`  // 为空时使用默认开放时间
+	CloseTime string `yaml:"closeTime"` // 为空时使用默认关闭时间
+}
+
+func NewTopologyConfig() *TopologyConfig {
+	cfg := &TopologyConfig{}
+	err := viper.UnmarshalKey("topology", &cfg)
+	if err != nil {
+		panic(fmt.Sprintf("无法解析图书馆拓扑配置: %v", err))
+	}
+	if cfg.OpenTime == "" {
+		cfg.OpenTime = "8:00"
+	}
+	if cfg.CloseTime == "" {
+		cfg.CloseTime = "22:00"
+	}
+	checkOpenHours("topology", cfg.OpenTime, cfg.CloseTime)
+	if cfg.HorizonDays <= 0 {
+		cfg.HorizonDays = 1
+	}
+	if cfg.QueueDays <= 0 {
+		cfg.QueueDays = 5
+	}
+	if cfg.DiscoverInterval <= 0 {
+		cfg.DiscoverInterval = 3600
+	}
+	if len(cfg.Rooms) == 0 && !cfg.Discover {
+		for _, id := range defaultRooms {
+			cfg.Rooms = append(cfg.Rooms, RoomConfig{ID: id})
+		}
+	}
+
+	seen := make(map[string]bool, len(cfg.Rooms))
+	for i := range cfg.Rooms {
+		room := &cfg.Rooms[i]
+		if room.ID == "" {
+			panic("图书馆拓扑配置无效: 房间缺少 id")
+		}
+		if seen[room.ID] {
+			panic(fmt.Sprintf("图书馆拓扑配置无效: 房间 %s 重复", room.ID))
+		}
+		seen[room.ID] = true
+		if room.OpenTime == "" {
+			room.OpenTime = cfg.OpenTime
+		}
+		if room.CloseTime == "" {
+			room.CloseTime = cfg.CloseTime
+		}
+		checkOpenHours("房间 "+room.ID, room.OpenTime, room.CloseTime)
+	}
+	return cfg
+}
+
+// checkOpenHours 检查开放时间的格式，开放时间需要早于关闭时间
+func checkOpenHours(name, open, close string) {
+	o, err1 := time.Parse("15:04", open)
+	c, err2 := time.Parse("15:04", close)
+	if err1 != nil || err2 != nil || !o.Before(c) {
+		panic(fmt.Sprintf("图书馆拓扑配置无效: %s 的开放时间 %s-%s", name, open, close))
+	}
+}
+
+// LibraryConfig 访问 CAS 和 kjyy 的地址以及 http 客户端的设置
+type LibraryConfig struct {
+	CASUrl              string `yaml:"casUrl"`              // 统一身份认证的根地址
+	KjyyUrl             string `yaml:"kjyyUrl"`             // 空间预约系统的根地址
+	UserAgent           string `yaml:"userAgent"`           // 请求上游时使用的 User-Agent
+	Timeout             int    `yaml:"timeout"`             // 单个请求的超时时间(秒)
+	Proxy               string `yaml:"proxy"`               // 出站代理，为空时使用 HTTP_PROXY/HTTPS_PROXY 环境变量
+	CAFile              string `yaml:"caFile"`              // 额外信任的 CA 证书(PEM)，为空时只使用系统证书
+	DialTimeout         int    `yaml:"dialTimeout"`         // 建立 TCP 连接的超时时间(秒)
+	KeepAlive           int    `yaml:"keepAlive"`           // TCP keep-alive 的间隔(秒)
+	TLSHandshakeTimeout int    `yaml:"tlsHandshakeTimeout"` // TLS 握手的超时时间(秒)
+	IdleConnTimeout     int    `yaml:"idleConnTimeout"`     // 空闲连接保留的时间(秒)
+}
 
+func NewLibraryConfig() *LibraryConfig {
+	cfg := &LibraryConfig{}
+	err := viper.UnmarshalKey("library", &cfg)
+	if err != nil {
+		panic(fmt.Sprintf("无法解析图书馆上游配置: %v", err))
+	}
+	if cfg.CASUrl == "" {
+		cfg.CASUrl = "https://account.ccnu.edu.cn"
+	}
+	if cfg.KjyyUrl == "" {
+		cfg.KjyyUrl = "http://kjyy.ccnu.edu.cn"
+	}
+	cfg.CASUrl = strings.TrimRight(cfg.CASUrl, "/")
+	cfg.KjyyUrl = strings.TrimRight(cfg.KjyyUrl, "/")
+	for _, raw := range []string{cfg.CASUrl, cfg.KjyyUrl} {
+		checkBaseURL(raw)
+	}
+	if cfg.Proxy != "" {
+		checkBaseURL(cfg.Proxy)
+	}
+	if cfg.UserAgent == "" {
+		cfg.UserAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/142.0.0.0 Safari/537.36"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 15
+	}
+	if cfg.DialTimeout <= 0 {
+		cfg.DialTimeout = 5
+	}
+	if cfg.KeepAlive <= 0 {
+		cfg.KeepAlive = 30
+	}
+	if cfg.TLSHandshakeTimeout <= 0 {
+		cfg.TLSHandshakeTimeout = 10
+	}
+	if cfg.IdleConnTimeout <= 0 {
+		cfg.IdleConnTimeout = 90
+	}
 	return cfg
 }
+
+// checkBaseURL 地址需要带有 scheme 和 host，例如 http://kjyy.ccnu.edu.cn
+func checkBaseURL(raw string) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		panic(fmt.Sprintf("图书馆上游配置无效: 地址 %q 需要带有 scheme 和 host", raw))
+	}
+}
@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"github.com/Serendipity565/GrabSeat/api/request"
+	"github.com/Serendipity565/GrabSeat/api/response"
+	"github.com/Serendipity565/GrabSeat/pkg/ginx"
+	"github.com/Serendipity565/GrabSeat/service"
+	"github.com/gin-gonic/gin"
+)
+
+type AdminController struct {
+	gs service.GrabberService
+}
+
+func NewAdminController(gs service.GrabberService) *AdminController {
+	return &AdminController{
+		gs: gs,
+	}
+}
+
+func (ac *AdminController) RegisterAdminRouter(r *gin.RouterGroup, basicAuthMiddleware gin.HandlerFunc) {
+	c := r.Group("/admin", basicAuthMiddleware)
+	{
+		c.GET("/sessions", ginx.Wrap(ac.Sessions))
+		c.POST("/sessions/evict", ginx.WrapReq(ac.EvictSession))
+		c.POST("/sessions/flush", ginx.Wrap(ac.FlushSessions))
+	}
+}
+
+// Sessions 查看 cookie 池接口
+//
+//	@Summary		查看 cookie 池接口
+//	@Description	列出 cookie 池中缓存的图书馆会话，只返回学号和过期时间，需要 BasicAuth
+//	@Tags			admin
+//	@Produce		json
+//	@Success		200	{object}	response.Response{data=[]response.PoolSession}	"成功返回会话列表"
+//	@Failure		401	{object}	response.Response								"认证失败"
+//	@Router			/api/v1/admin/sessions [get]
+func (ac *AdminController) Sessions(c *gin.Context) (response.Response, error) {
+	return response.Response{
+		Code: 0,
+		Msg:  "Success",
+		Data: ac.gs.PoolSessions(),
+	}, nil
+}
+
+// EvictSession 移除用户会话接口
+//
+//	@Summary		移除用户会话接口
+//	@Description	从 cookie 池中移除某个用户的图书馆会话，下次请求时重新登录，需要 BasicAuth
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		request.EvictSessionReq	true	"移除会话请求参数"
+//	@Success		200		{object}	response.Response		"成功移除会话"
+//	@Failure		401		{object}	response.Response		"认证失败"
+//	@Failure		404		{object}	response.Response		"该用户没有缓存的图书馆会话"
+//	@Router			/api/v1/admin/sessions/evict [post]
+func (ac *AdminController) EvictSession(c *gin.Context, req request.EvictSessionReq) (response.Response, error) {
+	if err := ac.gs.EvictSession(req.UserID); err != nil {
+		return response.Response{}, err
+	}
+	return response.Response{
+		Code: 0,
+		Msg:  "Success",
+		Data: nil,
+	}, nil
+}
+
+// FlushSessions 清空 cookie 池接口
+//
+//	@Summary		清空 cookie 池接口
+//	@Description	移除 cookie 池中所有的图书馆会话，需要 BasicAuth
+//	@Tags			admin
+//	@Produce		json
+//	@Success		200	{object}	response.Response{data=response.FlushResult}	"成功清空 cookie 池"
+//	@Failure		401	{object}	response.Response								"认证失败"
+//	@Router			/api/v1/admin/sessions/flush [post]
+func (ac *AdminController) FlushSessions(c *gin.Context) (response.Response, error) {
+	return response.Response{
+		Code: 0,
+		Msg:  "Success",
+		Data: response.FlushResult{Evicted: ac.gs.FlushSessions()},
+	}, nil
+}
@@ -2,6 +2,7 @@ package controller
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/Serendipity565/GrabSeat/api/request"
 	"github.com/Serendipity565/GrabSeat/api/response"
@@ -24,17 +25,73 @@ func NewGarbHandler(gs service.GrabberService) *GarbController {
 func (gc *GarbController) RegisterGarbRouter(r *gin.RouterGroup, authMiddleware gin.HandlerFunc) {
 	c := r.Group("/garb")
 	{
+		c.GET("/rooms", authMiddleware, ginx.WrapClaims(gc.Rooms))
 		c.POST("/findvacantseats", authMiddleware, ginx.WrapClaimsAndReq(gc.FindVacantSeats))
 		c.POST("/seattoname", authMiddleware, ginx.WrapClaimsAndReq(gc.SeatToName))
 		c.POST("/isinlibrary", authMiddleware, ginx.WrapClaimsAndReq(gc.IsInLibrary))
 		c.POST("/garb", authMiddleware, ginx.WrapClaimsAndReq(gc.Garb))
+		c.POST("/burst", authMiddleware, ginx.WrapClaimsAndReq(gc.Burst))
+		c.GET("/seats", authMiddleware, ginx.WrapClaimsAndReq(gc.Seats))
+		c.POST("/cancel", authMiddleware, ginx.WrapClaimsAndReq(gc.Cancel))
+		c.GET("/myreservations", authMiddleware, ginx.WrapClaims(gc.MyReservations))
 	}
 }
 
+// queryDate 请求中的日期，指定 date 时使用 date，否则根据 is_tomorrow 选择今天或明天
+func queryDate(date string, isTomorrow *bool) (time.Time, error) {
+	if date != "" {
+		return time.ParseInLocation("2006-01-02", date, time.Local)
+	}
+	d := time.Now()
+	if isTomorrow != nil && *isTomorrow {
+		d = d.AddDate(0, 0, 1)
+	}
+	return d, nil
+}
+
+// validTimeRange 开始和结束时间都是 15:04 格式，并且开始时间早于结束时间
+func validTimeRange(start, end string) bool {
+	st, err := time.Parse("15:04", start)
+	if err != nil {
+		return false
+	}
+	et, err := time.Parse("15:04", end)
+	if err != nil {
+		return false
+	}
+	return st.Before(et)
+}
+
+// Rooms 房间列表接口
+//
+//	@Summary		房间列表接口
+//	@Description	列出可以预约座位的房间，以及房间所在的分馆、楼层和开放时间，查询接口的 room 和 floor 参数取自这里
+//	@Tags			garb
+//	@Produce		json
+//	@Param			Authorization	header		string									true	"Bearer {{JWT}}"
+//	@Success		200				{object}	response.Response{data=[]response.Room}	"成功返回房间列表"
+//	@Failure		500				{object}	response.Response						"服务器内部错误"
+//	@Router			/api/v1/garb/rooms [get]
+func (gc *GarbController) Rooms(c *gin.Context, uc ijwt.UserClaims) (response.Response, error) {
+	client, err := gc.gs.GetClient(c.Request.Context(), uc.UserId)
+	if err != nil {
+		return response.Response{}, err
+	}
+	rooms, err := gc.gs.Rooms(c.Request.Context(), client)
+	if err != nil {
+		return response.Response{}, err
+	}
+	return response.Response{
+		Code: 0,
+		Msg:  "Success",
+		Data: rooms,
+	}, nil
+}
+
 // FindVacantSeats 查找空座位接口，可指定条件或模糊查找
 //
 //	@Summary		查找空座位接口
-//	@Description	查找空座位接口
+//	@Description	查找空座位接口，可以按房间或楼层过滤
 //	@Tags			garb
 //	@Accept			json
 //	@Produce		json
@@ -45,18 +102,27 @@ func (gc *GarbController) RegisterGarbRouter(r *gin.RouterGroup, authMiddleware
 //	@Failure		500				{object}	response.Response						"服务器内部错误"
 //	@Router			/api/v1/garb/findvacantseats [post]
 func (gc *GarbController) FindVacantSeats(c *gin.Context, req request.FindVacantSeatsReq, uc ijwt.UserClaims) (response.Response, error) {
-	if req.StartTime >= req.EndTime {
+	if !validTimeRange(req.StartTime, req.EndTime) {
+		return response.Response{
+			Code: http.StatusBadRequest,
+			Msg:  "请求参数错误",
+			Data: "时间格式应为 15:04，且开始时间必须小于结束时间",
+		}, nil
+	}
+	date, err := queryDate(req.Date, req.IsTomorrow)
+	if err != nil {
 		return response.Response{
 			Code: http.StatusBadRequest,
 			Msg:  "请求参数错误",
-			Data: "开始时间必须小于结束时间",
+			Data: "date 格式应为 2006-01-02",
 		}, nil
 	}
-	client, err := gc.gs.GetClient(uc.UserId, uc.Password)
+	client, err := gc.gs.GetClient(c.Request.Context(), uc.UserId)
 	if err != nil {
 		return response.Response{}, err
 	}
-	seats, err := gc.gs.FindVacantSeats(client, req.StartTime, req.EndTime, req.KeyWord, *req.IsTomorrow)
+	filter := service.RoomFilter{Room: req.Room, Floor: req.Floor}
+	seats, meta, err := gc.gs.FindVacantSeats(c.Request.Context(), client, req.StartTime, req.EndTime, req.KeyWord, date, filter)
 	if err != nil {
 		return response.Response{}, err
 	}
@@ -64,6 +130,7 @@ func (gc *GarbController) FindVacantSeats(c *gin.Context, req request.FindVacant
 		Code: 0,
 		Msg:  "Success",
 		Data: seats,
+		Meta: meta,
 	}, nil
 }
 
@@ -81,11 +148,20 @@ func (gc *GarbController) FindVacantSeats(c *gin.Context, req request.FindVacant
 //	@Failure		500				{object}	response.Response						"服务器内部错误"
 //	@Router			/api/v1/garb/seattoname [post]
 func (gc *GarbController) SeatToName(c *gin.Context, req request.SeatToNameReq, uc ijwt.UserClaims) (response.Response, error) {
-	client, err := gc.gs.GetClient(uc.UserId, uc.Password)
+	date, err := queryDate(req.Date, req.IsTomorrow)
+	if err != nil {
+		return response.Response{
+			Code: http.StatusBadRequest,
+			Msg:  "请求参数错误",
+			Data: "date 格式应为 2006-01-02",
+		}, nil
+	}
+	client, err := gc.gs.GetClient(c.Request.Context(), uc.UserId)
 	if err != nil {
 		return response.Response{}, err
 	}
-	ts, err := gc.gs.SeatToName(client, req.SeatName, *req.IsTomorrow)
+	filter := service.RoomFilter{Room: req.Room, Floor: req.Floor}
+	ts, meta, err := gc.gs.SeatToName(c.Request.Context(), client, req.SeatName, date, filter)
 	if err != nil {
 		return response.Response{}, err
 	}
@@ -93,6 +169,7 @@ func (gc *GarbController) SeatToName(c *gin.Context, req request.SeatToNameReq,
 		Code: 0,
 		Msg:  "Success",
 		Data: ts,
+		Meta: meta,
 	}, nil
 }
 
@@ -110,11 +187,12 @@ func (gc *GarbController) SeatToName(c *gin.Context, req request.SeatToNameReq,
 //	@Failure		500				{object}	response.Response				"服务器内部错误"
 //	@Router			/api/v1/garb/isinlibrary [post]
 func (gc *GarbController) IsInLibrary(c *gin.Context, req request.IsInLibraryReq, uc ijwt.UserClaims) (response.Response, error) {
-	client, err := gc.gs.GetClient(uc.UserId, uc.Password)
+	client, err := gc.gs.GetClient(c.Request.Context(), uc.UserId)
 	if err != nil {
 		return response.Response{}, err
 	}
-	ot, err := gc.gs.IsInLibrary(client, req.StudentName)
+	filter := service.RoomFilter{Room: req.Room, Floor: req.Floor}
+	ot, meta, err := gc.gs.IsInLibrary(c.Request.Context(), client, req.StudentName, filter)
 	if err != nil {
 		return response.Response{}, err
 	}
@@ -122,6 +200,7 @@ func (gc *GarbController) IsInLibrary(c *gin.Context, req request.IsInLibraryReq
 		Code: 0,
 		Msg:  "Success",
 		Data: ot,
+		Meta: meta,
 	}, nil
 
 }
@@ -129,29 +208,54 @@ func (gc *GarbController) IsInLibrary(c *gin.Context, req request.IsInLibraryReq
 // Garb 抢座接口
 //
 //	@Summary		抢座接口
-//	@Description	抢座接口
+//	@Description	抢座接口，seat_id 可以是座位名称（例如 N1224）或座位ID，不指定时根据时间段和关键词自动选择座位
 //	@Tags			garb
 //	@Accept			json
 //	@Produce		json
-//	@Param			Authorization	header		string							true	"Bearer {{JWT}}"
-//	@Param			request			body		request.GarbReq					true	"抢座请求参数"
-//	@Success		200				{object}	response.Response{data=string}	"成功返回抢座结果"
+//	@Param			Authorization	header		string										true	"Bearer {{JWT}}"
+//	@Param			request			body		request.GarbReq								true	"抢座请求参数"
+//	@Success		200				{object}	response.Response{data=response.GrabResult}	"成功返回抢座结果"
 //	@Failure		400				{object}	response.Response				"请求参数错误"
 //	@Failure		500				{object}	response.Response				"服务器内部错误"
 //	@Router			/api/v1/garb/garb [post]
 func (gc *GarbController) Garb(c *gin.Context, req request.GarbReq, uc ijwt.UserClaims) (response.Response, error) {
-	if req.StartTime >= req.EndTime {
+	if !validTimeRange(req.StartTime, req.EndTime) {
+		return response.Response{
+			Code: http.StatusBadRequest,
+			Msg:  "请求参数错误",
+			Data: "时间格式应为 15:04，且开始时间必须小于结束时间",
+		}, nil
+	}
+	date, err := queryDate(req.Date, req.IsTomorrow)
+	if err != nil {
 		return response.Response{
 			Code: http.StatusBadRequest,
 			Msg:  "请求参数错误",
-			Data: "开始时间必须小于结束时间",
+			Data: "date 格式应为 2006-01-02",
+		}, nil
+	}
+	client, err := gc.gs.GetClient(c.Request.Context(), uc.UserId)
+	if err != nil {
+		return response.Response{}, err
+	}
+	if req.SeatID == "" {
+		filter := service.RoomFilter{Room: req.Room, Floor: req.Floor}
+		res, err := gc.gs.AutoGrab(c.Request.Context(), client, req.StartTime, req.EndTime, req.KeyWord, date, filter)
+		if err != nil {
+			return response.Response{}, err
+		}
+		return response.Response{
+			Code: http.StatusOK,
+			Msg:  "success",
+			Data: res,
 		}, nil
 	}
-	client, err := gc.gs.GetClient(uc.UserId, uc.Password)
+
+	seat, err := gc.gs.ResolveSeat(c.Request.Context(), client, req.SeatID)
 	if err != nil {
 		return response.Response{}, err
 	}
-	success, err := gc.gs.Grab(client, req.SeatID, req.StartTime, req.EndTime, *req.IsTomorrow)
+	success, err := gc.gs.Grab(c.Request.Context(), client, seat.DevId, req.StartTime, req.EndTime, date)
 	if err != nil {
 		return response.Response{}, err
 	}
@@ -165,6 +269,168 @@ func (gc *GarbController) Garb(c *gin.Context, req request.GarbReq, uc ijwt.User
 	return response.Response{
 		Code: http.StatusOK,
 		Msg:  "success",
-		Data: "抢座成功",
+		Data: response.GrabResult{
+			SeatID:   seat.DevId,
+			Title:    seat.Title,
+			Attempts: 1,
+		},
+	}, nil
+}
+
+// Burst 放座时刻连发抢座接口
+//
+//	@Summary		放座时刻连发抢座接口
+//	@Description	校准服务器时钟后，在放座时刻附近连续发起预约请求，直到有一次成功，返回每次请求的时间信息
+//	@Tags			garb
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string											true	"Bearer {{JWT}}"
+//	@Param			request			body		request.GarbBurstReq							true	"连发抢座请求参数"
+//	@Success		200				{object}	response.Response{data=response.BurstResult}	"成功返回抢座结果"
+//	@Failure		400				{object}	response.Response								"请求参数错误"
+//	@Failure		500				{object}	response.Response								"服务器内部错误"
+//	@Router			/api/v1/garb/burst [post]
+func (gc *GarbController) Burst(c *gin.Context, req request.GarbBurstReq, uc ijwt.UserClaims) (response.Response, error) {
+	if !validTimeRange(req.StartTime, req.EndTime) {
+		return response.Response{
+			Code: http.StatusBadRequest,
+			Msg:  "请求参数错误",
+			Data: "时间格式应为 15:04，且开始时间必须小于结束时间",
+		}, nil
+	}
+	// 默认今天 18:00 放明天的座位
+	now := time.Now()
+	releaseAt := time.Date(now.Year(), now.Month(), now.Day(), 18, 0, 0, 0, now.Location())
+	if req.ReleaseAt != "" {
+		t, err := time.ParseInLocation(time.DateTime, req.ReleaseAt, time.Local)
+		if err != nil {
+			return response.Response{
+				Code: http.StatusBadRequest,
+				Msg:  "请求参数错误",
+				Data: "release_at 格式应为 2006-01-02 15:04:05",
+			}, nil
+		}
+		releaseAt = t
+	}
+
+	client, err := gc.gs.GetClient(c.Request.Context(), uc.UserId)
+	if err != nil {
+		return response.Response{}, err
+	}
+	seat, err := gc.gs.ResolveSeat(c.Request.Context(), client, req.SeatID)
+	if err != nil {
+		return response.Response{}, err
+	}
+	res, err := gc.gs.BurstGrab(c.Request.Context(), client, seat.DevId, req.StartTime, req.EndTime, releaseAt)
+	if err != nil {
+		return response.Response{}, err
+	}
+	msg := "success"
+	if !res.Success {
+		msg = "fail"
+	}
+	return response.Response{
+		Code: http.StatusOK,
+		Msg:  msg,
+		Data: res,
+	}, nil
+}
+
+// Seats 座位名称查询接口
+//
+//	@Summary		座位名称查询接口
+//	@Description	查询座位名称与座位ID、所在区域的对应关系，可按座位名称模糊查找
+//	@Tags			garb
+//	@Produce		json
+//	@Param			Authorization	header		string											true	"Bearer {{JWT}}"
+//	@Param			key_word		query		string											false	"座位名称模糊搜索关键词，例如 N12"
+//	@Param			room			query		string											false	"只查询这个房间，房间ID或房间名称"
+//	@Param			floor			query		string											false	"只查询这一层的房间"
+//	@Success		200				{object}	response.Response{data=[]response.SeatDevice}	"成功返回座位列表"
+//	@Failure		500				{object}	response.Response								"服务器内部错误"
+//	@Router			/api/v1/garb/seats [get]
+func (gc *GarbController) Seats(c *gin.Context, req request.SeatLookupReq, uc ijwt.UserClaims) (response.Response, error) {
+	client, err := gc.gs.GetClient(c.Request.Context(), uc.UserId)
+	if err != nil {
+		return response.Response{}, err
+	}
+	filter := service.RoomFilter{Room: req.Room, Floor: req.Floor}
+	seats, err := gc.gs.SearchSeats(c.Request.Context(), client, req.KeyWord, filter)
+	if err != nil {
+		return response.Response{}, err
+	}
+	return response.Response{
+		Code: 0,
+		Msg:  "Success",
+		Data: seats,
+	}, nil
+}
+
+// Cancel 取消预约接口
+//
+//	@Summary		取消预约接口
+//	@Description	按预约记录ID取消预约，或者取消指定日期的所有预约，取消后会重新读取预约记录确认
+//	@Tags			garb
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string											true	"Bearer {{JWT}}"
+//	@Param			request			body		request.CancelReservationReq					true	"取消预约请求参数"
+//	@Success		200				{object}	response.Response{data=response.CancelResult}	"成功取消预约"
+//	@Failure		400				{object}	response.Response								"请求参数错误"
+//	@Failure		404				{object}	response.Response								"没有找到对应的预约"
+//	@Failure		500				{object}	response.Response								"服务器内部错误"
+//	@Router			/api/v1/garb/cancel [post]
+func (gc *GarbController) Cancel(c *gin.Context, req request.CancelReservationReq, uc ijwt.UserClaims) (response.Response, error) {
+	var date time.Time
+	if req.RsvID == "" {
+		t, err := time.ParseInLocation("2006-01-02", req.Date, time.Local)
+		if err != nil {
+			return response.Response{
+				Code: http.StatusBadRequest,
+				Msg:  "请求参数错误",
+				Data: "需要指定 rsv_id 或格式为 2006-01-02 的 date",
+			}, nil
+		}
+		date = t
+	}
+
+	client, err := gc.gs.GetClient(c.Request.Context(), uc.UserId)
+	if err != nil {
+		return response.Response{}, err
+	}
+	canceled, err := gc.gs.CancelReservation(c.Request.Context(), client, req.RsvID, date)
+	if err != nil {
+		return response.Response{}, err
+	}
+	return response.Response{
+		Code: 0,
+		Msg:  "Success",
+		Data: response.CancelResult{Canceled: canceled},
+	}, nil
+}
+
+// MyReservations 个人预约记录接口
+//
+//	@Summary		个人预约记录接口
+//	@Description	获取当前用户在图书馆的预约记录
+//	@Tags			garb
+//	@Produce		json
+//	@Param			Authorization	header		string											true	"Bearer {{JWT}}"
+//	@Success		200				{object}	response.Response{data=[]response.Reservation}	"成功返回预约记录"
+//	@Failure		500				{object}	response.Response								"服务器内部错误"
+//	@Router			/api/v1/garb/myreservations [get]
+func (gc *GarbController) MyReservations(c *gin.Context, uc ijwt.UserClaims) (response.Response, error) {
+	client, err := gc.gs.GetClient(c.Request.Context(), uc.UserId)
+	if err != nil {
+		return response.Response{}, err
+	}
+	rsvs, err := gc.gs.MyReservations(c.Request.Context(), client)
+	if err != nil {
+		return response.Response{}, err
+	}
+	return response.Response{
+		Code: 0,
+		Msg:  "Success",
+		Data: rsvs,
 	}, nil
 }
@@ -0,0 +1,23 @@
+package controller
+
+import "testing"
+
+func TestValidTimeRange(t *testing.T) {
+	cases := []struct {
+		start, end string
+		want       bool
+	}{
+		{"9:00", "10:00", true},
+		{"09:00", "10:00", true},
+		{"20:00", "21:30", true},
+		{"10:00", "9:00", false},
+		{"10:00", "10:00", false},
+		{"25:00", "26:00", false},
+		{"ten", "11:00", false},
+	}
+	for _, c := range cases {
+		if got := validTimeRange(c.start, c.end); got != c.want {
+			t.Errorf("validTimeRange(%q, %q) = %v, 期望 %v", c.start, c.end, got, c.want)
+		}
+	}
+}
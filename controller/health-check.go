@@ -1,6 +1,8 @@
 package controller
 
 import (
+	"net/http"
+
 	"github.com/Serendipity565/GrabSeat/api/response"
 	"github.com/Serendipity565/GrabSeat/pkg/ginx"
 	"github.com/Serendipity565/GrabSeat/service"
@@ -21,12 +23,14 @@ func (hc *HealthCheckController) RegisterHealthCheckRouter(r *gin.RouterGroup) {
 	c := r.Group("/health")
 	{
 		c.GET("/check", ginx.Wrap(hc.HealthCheck))
+		c.GET("/live", ginx.Wrap(hc.Live))
+		c.GET("/ready", ginx.Wrap(hc.Ready))
 	}
 }
 
 // HealthCheck 健康检查
 // @Summary		健康检查，返回当前服务占用的资源等信息
-// @Description	健康检查，返回当前服务占用的资源等信息
+// @Description	健康检查，返回当前服务占用的资源以及依赖的最近状态，数值均为数字
 // @Tags			health
 // @Accept			json
 // @Produce		json
@@ -43,3 +47,43 @@ func (hc *HealthCheckController) HealthCheck(c *gin.Context) (response.Response,
 		Data: resp,
 	}, nil
 }
+
+// Live 存活检查
+// @Summary		存活检查
+// @Description	进程能够处理请求即返回 200，不检查任何依赖
+// @Tags			health
+// @Produce		json
+// @Success		200	{object}	response.Response{data=response.LiveResponse}	"服务存活"
+// @Router			/api/v1/health/live [get]
+func (hc *HealthCheckController) Live(c *gin.Context) (response.Response, error) {
+	return response.Response{
+		Code: 0,
+		Msg:  "Success",
+		Data: hc.hs.Live(),
+	}, nil
+}
+
+// Ready 就绪检查
+// @Summary		就绪检查
+// @Description	实时检查 redis 以及 CAS、kjyy 是否可达；CAS 或 kjyy 不可达时返回 503，redis 不可用时以降级模式运行，仍然返回 200
+// @Tags			health
+// @Produce		json
+// @Success		200	{object}	response.Response{data=response.ReadyResponse}	"服务就绪"
+// @Failure		503	{object}	response.Response{data=response.ReadyResponse}	"图书馆上游不可达"
+// @Router			/api/v1/health/ready [get]
+func (hc *HealthCheckController) Ready(c *gin.Context) (response.Response, error) {
+	resp := hc.hs.Ready(c.Request.Context())
+	if resp.Status == service.StatusUnavailable {
+		c.Status(http.StatusServiceUnavailable)
+		return response.Response{
+			Code: http.StatusServiceUnavailable,
+			Msg:  "服务未就绪",
+			Data: resp,
+		}, nil
+	}
+	return response.Response{
+		Code: 0,
+		Msg:  "Success",
+		Data: resp,
+	}, nil
+}
@@ -1,6 +1,8 @@
 package controller
 
 import (
+	"net/http"
+
 	"github.com/Serendipity565/GrabSeat/api/request"
 	"github.com/Serendipity565/GrabSeat/api/response"
 	"github.com/Serendipity565/GrabSeat/pkg/ginx"
@@ -17,43 +19,103 @@ type LoginController struct {
 
 func NewLoginController(jwtHandler *ijwt.JWT, ls service.LoginService) *LoginController {
 	return &LoginController{
-		ls:         ls,
 		jwtHandler: jwtHandler,
+		ls:         ls,
 	}
 }
 
-func (lc *LoginController) RegisterLoginRouter(r *gin.RouterGroup) {
+func (lc *LoginController) RegisterLoginRouter(r *gin.RouterGroup, authMiddleware gin.HandlerFunc) {
 	c := r.Group("/ccnu")
 	{
 		c.POST("/login", ginx.WrapReq(lc.Login))
+		c.POST("/refresh", ginx.WrapReq(lc.Refresh))
+		c.POST("/logout", authMiddleware, ginx.WrapClaims(lc.Logout))
+		c.GET("/jwks", lc.JWKS)
 	}
 }
 
 // Login 登录接口
 //
 //	@Summary		用户登录
-//	@Description	用户登录，返回 JWT 令牌
+//	@Description	用户登录，返回短期有效的访问令牌和刷新令牌，访问令牌同时放在 Authorization 响应头中
 //	@Tags			auth
 //	@Accept			json
 //	@Produce		json
-//	@Param			request	body		request.LoginRequest	true	"登录请求参数"
-//	@Success		200		{object}	response.Response		"成功返回 JWT 令牌"
-//	@Failure		400		{object}	response.Response		"请求参数错误"
-//	@Failure		500		{object}	response.Response		"服务器内部错误"
+//	@Param			request	body		request.LoginRequest						true	"登录请求参数"
+//	@Success		200		{object}	response.Response{data=response.TokenPair}	"成功返回令牌"
+//	@Failure		400		{object}	response.Response							"请求参数错误"
+//	@Failure		500		{object}	response.Response							"服务器内部错误"
 //	@Router			/api/v1/ccnu/login [post]
 func (lc *LoginController) Login(c *gin.Context, req request.LoginRequest) (response.Response, error) {
-	// 验证用户名和密码（这里假设验证通过）
-	_, err := lc.ls.Login2CAS(req.Username, req.Password)
+	// 验证用户名和密码，凭据保存在服务端，token 中只有会话ID
+	pair, err := lc.ls.Login(c.Request.Context(), req.Username, req.Password)
+	if err != nil {
+		return response.Response{}, err
+	}
+
+	c.Header("Authorization", pair.AccessToken)
+	return response.Response{
+		Code: 0,
+		Msg:  "Success",
+		Data: pair,
+	}, nil
+}
+
+// Refresh 刷新令牌接口
+//
+//	@Summary		刷新令牌
+//	@Description	使用刷新令牌换取新的访问令牌和刷新令牌，旧的刷新令牌随即失效，重复使用会导致整个会话被吊销
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		request.RefreshRequest						true	"刷新令牌请求参数"
+//	@Success		200		{object}	response.Response{data=response.TokenPair}	"成功返回新的令牌"
+//	@Failure		401		{object}	response.Response							"刷新令牌无效或会话已被吊销"
+//	@Failure		500		{object}	response.Response							"服务器内部错误"
+//	@Router			/api/v1/ccnu/refresh [post]
+func (lc *LoginController) Refresh(c *gin.Context, req request.RefreshRequest) (response.Response, error) {
+	pair, err := lc.ls.Refresh(c.Request.Context(), req.RefreshToken)
 	if err != nil {
 		return response.Response{}, err
 	}
-	// 生成JWT令牌
-	token, _ := lc.jwtHandler.SetJWTToken(req.Username, req.Password)
 
-	c.Header("Authorization", token)
+	c.Header("Authorization", pair.AccessToken)
+	return response.Response{
+		Code: 0,
+		Msg:  "Success",
+		Data: pair,
+	}, nil
+}
+
+// Logout 退出登录接口
+//
+//	@Summary		退出登录
+//	@Description	吊销当前会话，会话下的访问令牌和刷新令牌立即失效
+//	@Tags			auth
+//	@Produce		json
+//	@Param			Authorization	header		string				true	"Bearer {{JWT}}"
+//	@Success		200				{object}	response.Response	"成功退出登录"
+//	@Failure		500				{object}	response.Response	"服务器内部错误"
+//	@Router			/api/v1/ccnu/logout [post]
+func (lc *LoginController) Logout(c *gin.Context, uc ijwt.UserClaims) (response.Response, error) {
+	if err := lc.ls.Logout(c.Request.Context(), uc.ID); err != nil {
+		return response.Response{}, err
+	}
 	return response.Response{
 		Code: 0,
 		Msg:  "Success",
 		Data: nil,
 	}, nil
 }
+
+// JWKS 公钥接口
+//
+//	@Summary		签名公钥
+//	@Description	以 JWKS 格式返回仍然有效的 EdDSA/RS256 公钥，其他服务可以据此按 kid 校验 token，HS256 密钥不会公开
+//	@Tags			auth
+//	@Produce		json
+//	@Success		200	{object}	map[string][]ijwt.JWK	"JWKS"
+//	@Router			/api/v1/ccnu/jwks [get]
+func (lc *LoginController) JWKS(c *gin.Context) {
+	c.JSON(http.StatusOK, lc.jwtHandler.JWKS())
+}
@@ -2,7 +2,6 @@ package controller
 
 import (
 	"net/http"
-	"time"
 
 	"github.com/Serendipity565/GrabSeat/api/request"
 	"github.com/Serendipity565/GrabSeat/api/response"
@@ -13,50 +12,92 @@ import (
 )
 
 type ReserveController struct {
+	rs service.ReserveService
 }
 
-func NewReserveHandler() *ReserveController {
-	return &ReserveController{}
+func NewReserveHandler(rs service.ReserveService) *ReserveController {
+	return &ReserveController{
+		rs: rs,
+	}
 }
 
 func (rc *ReserveController) RegisterReserveRouter(r *gin.RouterGroup, authMiddleware gin.HandlerFunc) {
 	c := r.Group("/reserve")
 	{
 		c.POST("/reserve", authMiddleware, ginx.WrapClaimsAndReq(rc.Reserve))
+		c.GET("/list", authMiddleware, ginx.WrapClaims(rc.List))
+		c.POST("/cancel", authMiddleware, ginx.WrapClaimsAndReq(rc.Cancel))
 	}
 }
 
 // Reserve 预约座位接口
 //
 //	@Summary		预约座位接口
-//	@Description	预约5天内座位接口
+//	@Description	提交预约任务，最多可以提前 topology.queueDays 天（默认 5 天）提交，任务会在放座时间（前一天 18:00）自动执行
 //	@Tags			reserve
 //	@Accept			json
 //	@Produce		json
+//	@Param			Authorization	header		string										true	"Bearer {{JWT}}"
+//	@Param			request			body		request.ReserveReq							true	"预约请求参数"
+//	@Success		200				{object}	response.Response{data=response.ReserveJob}	"成功加入预约队列"
+//	@Failure		400				{object}	response.Response							"请求参数错误"
+//	@Failure		500				{object}	response.Response							"服务器内部错误"
+//	@Router			/api/v1/reserve/reserve [post]
 func (rc *ReserveController) Reserve(c *gin.Context, req request.ReserveReq, uc ijwt.UserClaims) (response.Response, error) {
-	// 预约逻辑
-	bData, err := service.BeforeDate(req.Data)
+	// 日期和时间段由 Submit 校验
+	job, err := rc.rs.Submit(c.Request.Context(), uc.UserId, req)
 	if err != nil {
-		return response.Response{
-			Code: http.StatusBadRequest,
-			Msg:  "data错误",
-			Data: err.Error(),
-		}, nil
-	}
-	diff := bData.Truncate(24 * time.Hour).Sub(time.Now().Truncate(24 * time.Hour))
-	days := int(diff.Hours() / 24)
-	if days > 5 {
-		return response.Response{
-			Code: http.StatusBadRequest,
-			Msg:  "预约时间超过5天",
-			Data: nil,
-		}, nil
+		return response.Response{}, err
 	}
-
 	return response.Response{
 		Code: http.StatusOK,
 		Msg:  "已加入预约队列",
-		Data: nil,
+		Data: job,
+	}, nil
+}
+
+// List 查看预约任务接口
+//
+//	@Summary		查看预约任务接口
+//	@Description	查看当前用户的所有预约任务及执行结果
+//	@Tags			reserve
+//	@Produce		json
+//	@Param			Authorization	header		string											true	"Bearer {{JWT}}"
+//	@Success		200				{object}	response.Response{data=[]response.ReserveJob}	"成功返回预约任务列表"
+//	@Failure		500				{object}	response.Response								"服务器内部错误"
+//	@Router			/api/v1/reserve/list [get]
+func (rc *ReserveController) List(c *gin.Context, uc ijwt.UserClaims) (response.Response, error) {
+	jobs, err := rc.rs.List(c.Request.Context(), uc.UserId)
+	if err != nil {
+		return response.Response{}, err
+	}
+	return response.Response{
+		Code: 0,
+		Msg:  "Success",
+		Data: jobs,
 	}, nil
+}
 
+// Cancel 取消预约任务接口
+//
+//	@Summary		取消预约任务接口
+//	@Description	取消尚未执行的预约任务
+//	@Tags			reserve
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string						true	"Bearer {{JWT}}"
+//	@Param			request			body		request.CancelReserveReq	true	"取消预约任务请求参数"
+//	@Success		200				{object}	response.Response			"成功取消预约任务"
+//	@Failure		404				{object}	response.Response			"预约任务不存在或已开始执行"
+//	@Failure		500				{object}	response.Response			"服务器内部错误"
+//	@Router			/api/v1/reserve/cancel [post]
+func (rc *ReserveController) Cancel(c *gin.Context, req request.CancelReserveReq, uc ijwt.UserClaims) (response.Response, error) {
+	if err := rc.rs.Cancel(c.Request.Context(), uc.UserId, req.JobID); err != nil {
+		return response.Response{}, err
+	}
+	return response.Response{
+		Code: 0,
+		Msg:  "Success",
+		Data: nil,
+	}, nil
 }
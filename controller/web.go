@@ -15,7 +15,9 @@ import (
 var ProviderSet = wire.NewSet(
 	NewLoginController,
 	NewGarbHandler,
+	NewReserveHandler,
 	NewHealthCheckController,
+	NewAdminController,
 	NewGinEngine,
 )
 
@@ -23,6 +25,8 @@ func NewGinEngine(
 	hc *HealthCheckController,
 	lc *LoginController,
 	gc *GarbController,
+	rc *ReserveController,
+	ac *AdminController,
 
 	corsMiddleware *middleware.CorsMiddleware,
 	authMiddleware *middleware.AuthMiddleware,
@@ -49,8 +53,10 @@ func NewGinEngine(
 	api := r.Group("/api/v1")
 
 	hc.RegisterHealthCheckRouter(api)
-	lc.RegisterLoginRouter(api)
+	lc.RegisterLoginRouter(api, authMiddleware.MiddlewareFunc())
 	gc.RegisterGarbRouter(api, authMiddleware.MiddlewareFunc())
+	rc.RegisterReserveRouter(api, authMiddleware.MiddlewareFunc())
+	ac.RegisterAdminRouter(api, basicAuthMiddleware.MiddlewareFunc())
 
 	return r
 }
@@ -15,9 +15,160 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/api/v1/admin/sessions": {
+            "get": {
+                "description": "列出 cookie 池中缓存的图书馆会话，只返回学号和过期时间，需要 BasicAuth",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "查看 cookie 池接口",
+                "responses": {
+                    "200": {
+                        "description": "成功返回会话列表",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/response.PoolSession"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "401": {
+                        "description": "认证失败",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/sessions/evict": {
+            "post": {
+                "description": "从 cookie 池中移除某个用户的图书馆会话，下次请求时重新登录，需要 BasicAuth",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "移除用户会话接口",
+                "parameters": [
+                    {
+                        "description": "移除会话请求参数",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/request.EvictSessionReq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "成功移除会话",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "认证失败",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "该用户没有缓存的图书馆会话",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/sessions/flush": {
+            "post": {
+                "description": "移除 cookie 池中所有的图书馆会话，需要 BasicAuth",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "清空 cookie 池接口",
+                "responses": {
+                    "200": {
+                        "description": "成功清空 cookie 池",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/response.FlushResult"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "401": {
+                        "description": "认证失败",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/ccnu/jwks": {
+            "get": {
+                "description": "以 JWKS 格式返回仍然有效的 EdDSA/RS256 公钥，其他服务可以据此按 kid 校验 token，HS256 密钥不会公开",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "签名公钥",
+                "responses": {
+                    "200": {
+                        "description": "JWKS",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/ijwt.JWK"
+                                }
+                            }
+                        }
+                    }
+                }
+            }
+        },
         "/api/v1/ccnu/login": {
             "post": {
-                "description": "用户登录，返回 JWT 令牌",
+                "description": "用户登录，返回短期有效的访问令牌和刷新令牌，访问令牌同时放在 Authorization 响应头中",
                 "consumes": [
                     "application/json"
                 ],
@@ -41,9 +192,21 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "成功返回 JWT 令牌",
+                        "description": "成功返回令牌",
                         "schema": {
-                            "$ref": "#/definitions/response.Response"
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/response.TokenPair"
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     },
                     "400": {
@@ -61,19 +224,16 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/garb/findvacantseats": {
+        "/api/v1/ccnu/logout": {
             "post": {
-                "description": "查找空座位接口",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "吊销当前会话，会话下的访问令牌和刷新令牌立即失效",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "garb"
+                    "auth"
                 ],
-                "summary": "查找空座位接口",
+                "summary": "退出登录",
                 "parameters": [
                     {
                         "type": "string",
@@ -81,20 +241,51 @@ const docTemplate = `{
                         "name": "Authorization",
                         "in": "header",
                         "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "成功退出登录",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
                     },
+                    "500": {
+                        "description": "服务器内部错误",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/ccnu/refresh": {
+            "post": {
+                "description": "使用刷新令牌换取新的访问令牌和刷新令牌，旧的刷新令牌随即失效，重复使用会导致整个会话被吊销",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "刷新令牌",
+                "parameters": [
                     {
-                        "description": "查找空座位请求参数",
+                        "description": "刷新令牌请求参数",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/request.FindVacantSeatsReq"
+                            "$ref": "#/definitions/request.RefreshRequest"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "成功返回空座位列表",
+                        "description": "成功返回新的令牌",
                         "schema": {
                             "allOf": [
                                 {
@@ -104,18 +295,15 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "type": "array",
-                                            "items": {
-                                                "$ref": "#/definitions/response.Seat"
-                                            }
+                                            "$ref": "#/definitions/response.TokenPair"
                                         }
                                     }
                                 }
                             ]
                         }
                     },
-                    "400": {
-                        "description": "请求参数错误",
+                    "401": {
+                        "description": "刷新令牌无效或会话已被吊销",
                         "schema": {
                             "$ref": "#/definitions/response.Response"
                         }
@@ -129,9 +317,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/garb/garb": {
+        "/api/v1/garb/burst": {
             "post": {
-                "description": "抢座接口",
+                "description": "校准服务器时钟后，在放座时刻附近连续发起预约请求，直到有一次成功，返回每次请求的时间信息",
                 "consumes": [
                     "application/json"
                 ],
@@ -141,7 +329,7 @@ const docTemplate = `{
                 "tags": [
                     "garb"
                 ],
-                "summary": "抢座接口",
+                "summary": "放座时刻连发抢座接口",
                 "parameters": [
                     {
                         "type": "string",
@@ -151,12 +339,12 @@ const docTemplate = `{
                         "required": true
                     },
                     {
-                        "description": "抢座请求参数",
+                        "description": "连发抢座请求参数",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/request.GarbReq"
+                            "$ref": "#/definitions/request.GarbBurstReq"
                         }
                     }
                 ],
@@ -172,7 +360,7 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "type": "string"
+                                            "$ref": "#/definitions/response.BurstResult"
                                         }
                                     }
                                 }
@@ -194,9 +382,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/garb/isinlibrary": {
+        "/api/v1/garb/cancel": {
             "post": {
-                "description": "检查目标用户当前是否在图书馆",
+                "description": "按预约记录ID取消预约，或者取消指定日期的所有预约，取消后会重新读取预约记录确认",
                 "consumes": [
                     "application/json"
                 ],
@@ -206,7 +394,7 @@ const docTemplate = `{
                 "tags": [
                     "garb"
                 ],
-                "summary": "检查目标用户当前是否在图书馆",
+                "summary": "取消预约接口",
                 "parameters": [
                     {
                         "type": "string",
@@ -216,18 +404,18 @@ const docTemplate = `{
                         "required": true
                     },
                     {
-                        "description": "检查目标用户当前是否在图书馆请求参数",
+                        "description": "取消预约请求参数",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/request.IsInLibraryReq"
+                            "$ref": "#/definitions/request.CancelReservationReq"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "成功返回在图书馆的时间段",
+                        "description": "成功取消预约",
                         "schema": {
                             "allOf": [
                                 {
@@ -237,7 +425,7 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "type": "string"
+                                            "$ref": "#/definitions/response.CancelResult"
                                         }
                                     }
                                 }
@@ -250,6 +438,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/response.Response"
                         }
                     },
+                    "404": {
+                        "description": "没有找到对应的预约",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
                     "500": {
                         "description": "服务器内部错误",
                         "schema": {
@@ -259,9 +453,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/garb/seattoname": {
+        "/api/v1/garb/findvacantseats": {
             "post": {
-                "description": "座位号转名字接口",
+                "description": "查找空座位接口，可以按房间或楼层过滤",
                 "consumes": [
                     "application/json"
                 ],
@@ -271,7 +465,7 @@ const docTemplate = `{
                 "tags": [
                     "garb"
                 ],
-                "summary": "座位号转名字接口",
+                "summary": "查找空座位接口",
                 "parameters": [
                     {
                         "type": "string",
@@ -281,18 +475,18 @@ const docTemplate = `{
                         "required": true
                     },
                     {
-                        "description": "座位号转名字请求参数",
+                        "description": "查找空座位请求参数",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/request.SeatToNameReq"
+                            "$ref": "#/definitions/request.FindVacantSeatsReq"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "成功返回座位号对应的名字",
+                        "description": "成功返回空座位列表",
                         "schema": {
                             "allOf": [
                                 {
@@ -304,7 +498,7 @@ const docTemplate = `{
                                         "data": {
                                             "type": "array",
                                             "items": {
-                                                "$ref": "#/definitions/response.Ts"
+                                                "$ref": "#/definitions/response.Seat"
                                             }
                                         }
                                     }
@@ -327,9 +521,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/health/check": {
-            "get": {
-                "description": "健康检查，返回当前服务占用的资源等信息",
+        "/api/v1/garb/garb": {
+            "post": {
+                "description": "抢座接口，seat_id 可以是座位名称（例如 N1224）或座位ID，不指定时根据时间段和关键词自动选择座位",
                 "consumes": [
                     "application/json"
                 ],
@@ -337,12 +531,30 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "health"
+                    "garb"
+                ],
+                "summary": "抢座接口",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer {{JWT}}",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "抢座请求参数",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/request.GarbReq"
+                        }
+                    }
                 ],
-                "summary": "健康检查，返回当前服务占用的资源等信息",
                 "responses": {
                     "200": {
-                        "description": "成功返回健康检查结果",
+                        "description": "成功返回抢座结果",
                         "schema": {
                             "allOf": [
                                 {
@@ -352,7 +564,7 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "$ref": "#/definitions/response.HealthCheckResponse"
+                                            "$ref": "#/definitions/response.GrabResult"
                                         }
                                     }
                                 }
@@ -373,94 +585,999 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "request.FindVacantSeatsReq": {
-            "type": "object",
-            "required": [
-                "end_time",
-                "is_tomorrow",
-                "start_time"
-            ],
-            "properties": {
-                "end_time": {
-                    "type": "string"
-                },
-                "is_tomorrow": {
-                    "type": "boolean"
-                },
-                "key_word": {
-                    "description": "可选参数，模糊搜索关键词",
-                    "type": "string"
-                },
-                "start_time": {
-                    "type": "string"
-                }
-            }
         },
-        "request.GarbReq": {
-            "type": "object",
-            "required": [
-                "end_time",
-                "is_tomorrow",
-                "start_time"
-            ],
+        "/api/v1/garb/isinlibrary": {
+            "post": {
+                "description": "检查目标用户当前是否在图书馆",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "garb"
+                ],
+                "summary": "检查目标用户当前是否在图书馆",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer {{JWT}}",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "检查目标用户当前是否在图书馆请求参数",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/request.IsInLibraryReq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "成功返回在图书馆的时间段",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/response.Occupant"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "请求参数错误",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "服务器内部错误",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/garb/myreservations": {
+            "get": {
+                "description": "获取当前用户在图书馆的预约记录",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "garb"
+                ],
+                "summary": "个人预约记录接口",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer {{JWT}}",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "成功返回预约记录",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/response.Reservation"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "500": {
+                        "description": "服务器内部错误",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/garb/rooms": {
+            "get": {
+                "description": "列出可以预约座位的房间，以及房间所在的分馆、楼层和开放时间，查询接口的 room 和 floor 参数取自这里",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "garb"
+                ],
+                "summary": "房间列表接口",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer {{JWT}}",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "成功返回房间列表",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/response.Room"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "500": {
+                        "description": "服务器内部错误",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/garb/seats": {
+            "get": {
+                "description": "查询座位名称与座位ID、所在区域的对应关系，可按座位名称模糊查找",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "garb"
+                ],
+                "summary": "座位名称查询接口",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer {{JWT}}",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "座位名称模糊搜索关键词，例如 N12",
+                        "name": "key_word",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "只查询这个房间，房间ID或房间名称",
+                        "name": "room",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "只查询这一层的房间",
+                        "name": "floor",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "成功返回座位列表",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/response.SeatDevice"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "500": {
+                        "description": "服务器内部错误",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/garb/seattoname": {
+            "post": {
+                "description": "座位号转名字接口",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "garb"
+                ],
+                "summary": "座位号转名字接口",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer {{JWT}}",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "座位号转名字请求参数",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/request.SeatToNameReq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "成功返回座位号对应的名字",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/response.Ts"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "请求参数错误",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "服务器内部错误",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/health/check": {
+            "get": {
+                "description": "健康检查，返回当前服务占用的资源以及依赖的最近状态，数值均为数字",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "健康检查，返回当前服务占用的资源等信息",
+                "responses": {
+                    "200": {
+                        "description": "成功返回健康检查结果",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/response.HealthCheckResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "请求参数错误",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "服务器内部错误",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/health/live": {
+            "get": {
+                "description": "进程能够处理请求即返回 200，不检查任何依赖",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "存活检查",
+                "responses": {
+                    "200": {
+                        "description": "服务存活",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/response.LiveResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/health/ready": {
+            "get": {
+                "description": "实时检查 redis 以及 CAS、kjyy 是否可达；CAS 或 kjyy 不可达时返回 503，redis 不可用时以降级模式运行，仍然返回 200",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "就绪检查",
+                "responses": {
+                    "200": {
+                        "description": "服务就绪",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/response.ReadyResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "503": {
+                        "description": "图书馆上游不可达",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/response.ReadyResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/reserve/cancel": {
+            "post": {
+                "description": "取消尚未执行的预约任务",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reserve"
+                ],
+                "summary": "取消预约任务接口",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer {{JWT}}",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "取消预约任务请求参数",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/request.CancelReserveReq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "成功取消预约任务",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "预约任务不存在或已开始执行",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "服务器内部错误",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/reserve/list": {
+            "get": {
+                "description": "查看当前用户的所有预约任务及执行结果",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reserve"
+                ],
+                "summary": "查看预约任务接口",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer {{JWT}}",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "成功返回预约任务列表",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/response.ReserveJob"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "500": {
+                        "description": "服务器内部错误",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/reserve/reserve": {
+            "post": {
+                "description": "提交预约任务，最多可以提前 topology.queueDays 天（默认 5 天）提交，任务会在放座时间（前一天 18:00）自动执行",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reserve"
+                ],
+                "summary": "预约座位接口",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer {{JWT}}",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "预约请求参数",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/request.ReserveReq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "成功加入预约队列",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/response.ReserveJob"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "请求参数错误",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "服务器内部错误",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "ijwt.JWK": {
+            "type": "object",
+            "properties": {
+                "alg": {
+                    "type": "string"
+                },
+                "crv": {
+                    "description": "OKP",
+                    "type": "string"
+                },
+                "e": {
+                    "description": "RSA",
+                    "type": "string"
+                },
+                "kid": {
+                    "type": "string"
+                },
+                "kty": {
+                    "type": "string"
+                },
+                "n": {
+                    "description": "RSA",
+                    "type": "string"
+                },
+                "use": {
+                    "type": "string"
+                },
+                "x": {
+                    "description": "OKP",
+                    "type": "string"
+                }
+            }
+        },
+        "request.CancelReservationReq": {
+            "type": "object",
+            "properties": {
+                "date": {
+                    "description": "可选参数,不指定 rsv_id 时取消这一天的所有预约,例如 2025-11-24",
+                    "type": "string"
+                },
+                "rsv_id": {
+                    "description": "可选参数,预约记录ID,指定时只取消这一条",
+                    "type": "string"
+                }
+            }
+        },
+        "request.CancelReserveReq": {
+            "type": "object",
+            "required": [
+                "job_id"
+            ],
+            "properties": {
+                "job_id": {
+                    "description": "预约任务ID",
+                    "type": "string"
+                }
+            }
+        },
+        "request.EvictSessionReq": {
+            "type": "object",
+            "required": [
+                "user_id"
+            ],
+            "properties": {
+                "user_id": {
+                    "description": "学号",
+                    "type": "string"
+                }
+            }
+        },
+        "request.FindVacantSeatsReq": {
+            "type": "object",
+            "required": [
+                "end_time",
+                "start_time"
+            ],
+            "properties": {
+                "date": {
+                    "description": "可选参数,查询日期,例如 2025-11-24,指定时忽略 is_tomorrow",
+                    "type": "string"
+                },
+                "end_time": {
+                    "type": "string"
+                },
+                "floor": {
+                    "description": "可选参数,只查询这一层的房间",
+                    "type": "string"
+                },
+                "is_tomorrow": {
+                    "description": "可选参数,不指定 date 时查询今天或明天",
+                    "type": "boolean"
+                },
+                "key_word": {
+                    "description": "可选参数，模糊搜索关键词",
+                    "type": "string"
+                },
+                "room": {
+                    "description": "可选参数,只查询这个房间,房间ID或房间名称",
+                    "type": "string"
+                },
+                "start_time": {
+                    "type": "string"
+                }
+            }
+        },
+        "request.GarbBurstReq": {
+            "type": "object",
+            "required": [
+                "end_time",
+                "seat_id",
+                "start_time"
+            ],
+            "properties": {
+                "end_time": {
+                    "type": "string"
+                },
+                "release_at": {
+                    "description": "可选参数,放座时刻,例如 2025-11-24 18:00:00,默认今天 18:00",
+                    "type": "string"
+                },
+                "seat_id": {
+                    "description": "座位名称(例如 N1224)或座位ID",
+                    "type": "string"
+                },
+                "start_time": {
+                    "type": "string"
+                }
+            }
+        },
+        "request.GarbReq": {
+            "type": "object",
+            "required": [
+                "end_time",
+                "start_time"
+            ],
+            "properties": {
+                "date": {
+                    "description": "可选参数,预约日期,例如 2025-11-24,指定时忽略 is_tomorrow",
+                    "type": "string"
+                },
+                "end_time": {
+                    "type": "string"
+                },
+                "floor": {
+                    "description": "可选参数,自动选择座位时只选这一层的房间",
+                    "type": "string"
+                },
+                "is_tomorrow": {
+                    "description": "可选参数,不指定 date 时预约今天或明天",
+                    "type": "boolean"
+                },
+                "key_word": {
+                    "description": "可选参数,自动选择座位时的模糊搜索关键词",
+                    "type": "string"
+                },
+                "room": {
+                    "description": "可选参数,自动选择座位时只选这个房间,房间ID或房间名称",
+                    "type": "string"
+                },
+                "seat_id": {
+                    "description": "可选参数,指定座位名称(例如 N1224)或座位ID,不指定则自动选择",
+                    "type": "string"
+                },
+                "start_time": {
+                    "type": "string"
+                }
+            }
+        },
+        "request.IsInLibraryReq": {
+            "type": "object",
+            "required": [
+                "student_name"
+            ],
+            "properties": {
+                "floor": {
+                    "description": "可选参数,只查询这一层的房间",
+                    "type": "string"
+                },
+                "room": {
+                    "description": "可选参数,只查询这个房间,房间ID或房间名称",
+                    "type": "string"
+                },
+                "student_name": {
+                    "description": "学生姓名",
+                    "type": "string"
+                }
+            }
+        },
+        "request.LoginRequest": {
+            "type": "object",
+            "required": [
+                "password",
+                "username"
+            ],
+            "properties": {
+                "password": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "request.RefreshRequest": {
+            "type": "object",
+            "required": [
+                "refresh_token"
+            ],
+            "properties": {
+                "refresh_token": {
+                    "type": "string"
+                }
+            }
+        },
+        "request.ReserveReq": {
+            "type": "object",
+            "required": [
+                "data",
+                "end_time",
+                "start_time"
+            ],
+            "properties": {
+                "data": {
+                    "description": "预约日期,例如 2025-11-24",
+                    "type": "string"
+                },
+                "end_time": {
+                    "type": "string"
+                },
+                "key_word": {
+                    "description": "可选参数,未指定座位时按关键词选择空座位",
+                    "type": "string"
+                },
+                "seat_id": {
+                    "description": "可选参数,指定座位名称(例如 N1224)或座位ID",
+                    "type": "string"
+                },
+                "start_time": {
+                    "type": "string"
+                }
+            }
+        },
+        "request.SeatToNameReq": {
+            "type": "object",
+            "required": [
+                "seat_name"
+            ],
+            "properties": {
+                "date": {
+                    "description": "可选参数,查询日期,例如 2025-11-24,指定时忽略 is_tomorrow",
+                    "type": "string"
+                },
+                "floor": {
+                    "description": "可选参数,只查询这一层的房间",
+                    "type": "string"
+                },
+                "is_tomorrow": {
+                    "description": "可选参数,不指定 date 时查询今天或明天",
+                    "type": "boolean"
+                },
+                "room": {
+                    "description": "可选参数,只查询这个房间,房间ID或房间名称",
+                    "type": "string"
+                },
+                "seat_name": {
+                    "description": "座位名称,例如 N1224",
+                    "type": "string"
+                }
+            }
+        },
+        "response.BurstAttempt": {
+            "type": "object",
             "properties": {
-                "end_time": {
+                "index": {
+                    "type": "integer"
+                },
+                "latency_ms": {
+                    "description": "请求耗时",
+                    "type": "integer"
+                },
+                "msg": {
                     "type": "string"
                 },
-                "is_tomorrow": {
+                "planned_ms": {
+                    "description": "计划发出时间相对放座时刻的偏移（服务器时间）",
+                    "type": "integer"
+                },
+                "sent_ms": {
+                    "description": "实际发出时间相对放座时刻的偏移（服务器时间）",
+                    "type": "integer"
+                },
+                "success": {
                     "type": "boolean"
+                }
+            }
+        },
+        "response.BurstResult": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/response.BurstAttempt"
+                    }
                 },
-                "seat_id": {
-                    "description": "可选参数,指定座位ID,不指定则自动选择",
+                "clock_offset_ms": {
+                    "description": "服务器时钟 - 本地时钟",
+                    "type": "integer"
+                },
+                "clock_uncertainty_ms": {
+                    "description": "时钟偏差的误差范围",
+                    "type": "integer"
+                },
+                "release_at": {
+                    "description": "放座时刻（服务器时间）",
                     "type": "string"
                 },
-                "start_time": {
+                "seat_id": {
                     "type": "string"
+                },
+                "success": {
+                    "type": "boolean"
                 }
             }
         },
-        "request.IsInLibraryReq": {
+        "response.CancelResult": {
             "type": "object",
-            "required": [
-                "student_name"
-            ],
             "properties": {
-                "student_name": {
-                    "description": "学生姓名",
-                    "type": "string"
+                "canceled": {
+                    "description": "已取消的预约记录ID",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
                 }
             }
         },
-        "request.LoginRequest": {
+        "response.DependencyStatus": {
             "type": "object",
-            "required": [
-                "password",
-                "username"
-            ],
             "properties": {
-                "password": {
+                "circuit": {
+                    "description": "Circuit 图书馆上游的熔断器状态，\"closed\"、\"open\" 或 \"half_open\"",
                     "type": "string"
                 },
-                "username": {
+                "error": {
+                    "description": "Error 检查失败的原因",
+                    "type": "string"
+                },
+                "healthy": {
+                    "description": "Healthy 当前是否可用",
+                    "type": "boolean"
+                },
+                "latency_ms": {
+                    "description": "LatencyMs 本次检查的耗时，单位毫秒，没有实时检查时为 0",
+                    "type": "integer"
+                },
+                "name": {
+                    "description": "Name 依赖名称，例如 \"redis\"、\"cas\"、\"kjyy\"",
+                    "type": "string"
+                },
+                "required": {
+                    "description": "Required 不可用时服务是否无法就绪，redis 不可用时服务以降级模式运行",
+                    "type": "boolean"
+                },
+                "since": {
+                    "description": "Since 进入当前状态的时间，只有后台持续检查的依赖才有",
                     "type": "string"
                 }
             }
         },
-        "request.SeatToNameReq": {
+        "response.FlushResult": {
             "type": "object",
-            "required": [
-                "is_tomorrow",
-                "seat_name"
-            ],
             "properties": {
-                "is_tomorrow": {
-                    "type": "boolean"
+                "evicted": {
+                    "description": "被移除的会话数",
+                    "type": "integer"
+                }
+            }
+        },
+        "response.GrabResult": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "description": "尝试预约的次数",
+                    "type": "integer"
                 },
-                "seat_name": {
-                    "description": "座位名称,例如 N1224",
+                "seat_id": {
+                    "description": "预约成功的座位ID",
+                    "type": "string"
+                },
+                "title": {
+                    "description": "座位名称，例如 N1224",
                     "type": "string"
                 }
             }
@@ -468,6 +1585,13 @@ const docTemplate = `{
         "response.HealthCheckResponse": {
             "type": "object",
             "properties": {
+                "dependencies": {
+                    "description": "Dependencies 依赖的外部服务状态",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/response.DependencyStatus"
+                    }
+                },
                 "process": {
                     "description": "Process 当前进程的运行状态",
                     "allOf": [
@@ -478,10 +1602,10 @@ const docTemplate = `{
                 },
                 "response_ms": {
                     "description": "ResponseMs 响应耗时，单位毫秒",
-                    "type": "string"
+                    "type": "integer"
                 },
                 "status": {
-                    "description": "Status 服务状态，例如 \"ok\"",
+                    "description": "Status 服务状态，\"ok\"、redis 不可用时的 \"degraded\"，或者图书馆上游熔断时的 \"unavailable\"",
                     "type": "string"
                 },
                 "system": {
@@ -494,23 +1618,193 @@ const docTemplate = `{
                 }
             }
         },
+        "response.LiveResponse": {
+            "type": "object",
+            "properties": {
+                "status": {
+                    "description": "Status 固定为 \"ok\"",
+                    "type": "string"
+                },
+                "uptime_seconds": {
+                    "description": "UptimeSeconds 进程已经运行的秒数",
+                    "type": "integer"
+                }
+            }
+        },
+        "response.Meta": {
+            "type": "object",
+            "properties": {
+                "snapshot_age_ms": {
+                    "description": "SnapshotAgeMs 座位状态快照距今的时间，单位毫秒，为 0 表示刚从图书馆获取",
+                    "type": "integer"
+                },
+                "warnings": {
+                    "description": "Warnings 部分区域查询失败时的警告，此时 Data 只包含成功区域的结果",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "response.Occupant": {
+            "type": "object",
+            "properties": {
+                "end": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "start": {
+                    "type": "string"
+                },
+                "state": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "response.PoolSession": {
+            "type": "object",
+            "properties": {
+                "expire_at": {
+                    "description": "过期时间 2006-01-02 15:04:05",
+                    "type": "string"
+                },
+                "expired": {
+                    "description": "已过期，等待清理或下次请求时重新登录",
+                    "type": "boolean"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
         "response.ProcessStats": {
             "type": "object",
             "properties": {
                 "cpu_percent": {
                     "description": "CPUPercent 进程 CPU 使用率（%）",
-                    "type": "string"
+                    "type": "number"
                 },
                 "go_heap_alloc_mb": {
                     "description": "GoHeapAllocMB Go 堆分配的内存（MB）",
-                    "type": "string"
+                    "type": "integer"
                 },
                 "goroutines": {
                     "description": "Goroutines 当前运行的 goroutine 数",
-                    "type": "string"
+                    "type": "integer"
                 },
                 "memory_rss_mb": {
                     "description": "MemoryRSSMB 进程常驻内存（MB）",
+                    "type": "integer"
+                }
+            }
+        },
+        "response.ReadyResponse": {
+            "type": "object",
+            "properties": {
+                "dependencies": {
+                    "description": "Dependencies 每个依赖的检查结果",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/response.DependencyStatus"
+                    }
+                },
+                "status": {
+                    "description": "Status \"ok\"、redis 不可用时的 \"degraded\"，或者图书馆上游不可达时的 \"unavailable\"",
+                    "type": "string"
+                }
+            }
+        },
+        "response.Reservation": {
+            "type": "object",
+            "properties": {
+                "approved": {
+                    "description": "审核通过",
+                    "type": "boolean"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "effected": {
+                    "description": "已生效，false 表示未生效",
+                    "type": "boolean"
+                },
+                "end": {
+                    "type": "string"
+                },
+                "owner": {
+                    "type": "string"
+                },
+                "reserved": {
+                    "description": "预约成功",
+                    "type": "boolean"
+                },
+                "room": {
+                    "description": "区域名称，例如 南湖分馆一楼",
+                    "type": "string"
+                },
+                "rsv_id": {
+                    "description": "预约记录ID，取消预约时使用，已结束的预约没有",
+                    "type": "string"
+                },
+                "start": {
+                    "description": "例如 2025-11-24 19:00",
+                    "type": "string"
+                },
+                "states": {
+                    "description": "原始状态，例如 预约成功、未生效、审核通过",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "title": {
+                    "description": "座位名称，例如 N1224",
+                    "type": "string"
+                }
+            }
+        },
+        "response.ReserveJob": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "date": {
+                    "description": "预约日期 2006-01-02",
+                    "type": "string"
+                },
+                "end_time": {
+                    "description": "结束时间 22:00",
+                    "type": "string"
+                },
+                "fire_at": {
+                    "description": "任务执行时间",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "key_word": {
+                    "type": "string"
+                },
+                "result": {
+                    "type": "string"
+                },
+                "seat_id": {
+                    "type": "string"
+                },
+                "start_time": {
+                    "description": "开始时间 08:00",
+                    "type": "string"
+                },
+                "status": {
+                    "description": "pending, running, success, failed, canceled",
                     "type": "string"
                 }
             }
@@ -522,11 +1816,48 @@ const docTemplate = `{
                     "type": "integer"
                 },
                 "data": {},
+                "meta": {
+                    "description": "附加信息，没有时不返回",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/response.Meta"
+                        }
+                    ]
+                },
                 "msg": {
                     "type": "string"
                 }
             }
         },
+        "response.Room": {
+            "type": "object",
+            "properties": {
+                "branch": {
+                    "description": "所在分馆",
+                    "type": "string"
+                },
+                "close_time": {
+                    "description": "关闭时间，例如 22:00",
+                    "type": "string"
+                },
+                "floor": {
+                    "description": "所在楼层",
+                    "type": "string"
+                },
+                "id": {
+                    "description": "kjyy 的 room_id",
+                    "type": "string"
+                },
+                "name": {
+                    "description": "房间名称",
+                    "type": "string"
+                },
+                "open_time": {
+                    "description": "开放时间，例如 8:00",
+                    "type": "string"
+                }
+            }
+        },
         "response.Seat": {
             "type": "object",
             "properties": {
@@ -544,35 +1875,68 @@ const docTemplate = `{
                 }
             }
         },
+        "response.SeatDevice": {
+            "type": "object",
+            "properties": {
+                "devId": {
+                    "type": "string"
+                },
+                "room": {
+                    "description": "座位所在区域ID",
+                    "type": "string"
+                },
+                "title": {
+                    "description": "座位名称，例如 N1224",
+                    "type": "string"
+                }
+            }
+        },
         "response.SystemStats": {
             "type": "object",
             "properties": {
                 "cpu_percent": {
                     "description": "CPUPercent CPU 使用率（%）",
-                    "type": "string"
+                    "type": "number"
                 },
                 "disk_percent": {
                     "description": "DiskPercent 磁盘使用率（%）",
-                    "type": "string"
+                    "type": "number"
                 },
-                "disk_total": {
+                "disk_total_gb": {
                     "description": "DiskTotalGB 磁盘总量（GB）",
-                    "type": "string"
+                    "type": "integer"
                 },
-                "disk_used": {
+                "disk_used_gb": {
                     "description": "DiskUsedGB 已使用磁盘（GB）",
-                    "type": "string"
+                    "type": "integer"
                 },
                 "memory_percent": {
                     "description": "MemoryPercent 内存使用率（%）",
-                    "type": "string"
+                    "type": "number"
                 },
-                "memory_total": {
+                "memory_total_mb": {
                     "description": "MemoryTotalMB 内存总量（MB）",
-                    "type": "string"
+                    "type": "integer"
                 },
-                "memory_used": {
+                "memory_used_mb": {
                     "description": "MemoryUsedMB 已使用内存（MB）",
+                    "type": "integer"
+                }
+            }
+        },
+        "response.TokenPair": {
+            "type": "object",
+            "properties": {
+                "access_token": {
+                    "description": "访问令牌，放在 Authorization 头中使用",
+                    "type": "string"
+                },
+                "expires_in": {
+                    "description": "访问令牌的有效期，单位秒",
+                    "type": "integer"
+                },
+                "refresh_token": {
+                    "description": "刷新令牌，只能使用一次，刷新后会返回新的刷新令牌",
                     "type": "string"
                 }
             }
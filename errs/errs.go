@@ -9,6 +9,14 @@ import (
 const (
 	userIdOrPasswordErrorCode = iota + 40001
 	unauthorizedErrorCode
+	reserveDateErrorCode
+	reserveJobNotFoundErrorCode
+	burstWindowErrorCode
+	seatNotFoundErrorCode
+	reservationNotFoundErrorCode
+	poolSessionNotFoundErrorCode
+	roomNotFoundErrorCode
+	reserveTimeErrorCode
 )
 
 const (
@@ -17,6 +25,10 @@ const (
 	grabSeatErrorCode
 	getHistoryErrorCode
 	createClientErrorCode
+	cancelReservationErrorCode
+	upstreamTimeoutErrorCode
+	upstreamUnavailableErrorCode
+	grabUnconfirmedErrorCode
 )
 
 var (
@@ -26,6 +38,30 @@ var (
 	UnauthorizedError = func(err error) error {
 		return errorx.New(http.StatusUnauthorized, unauthorizedErrorCode, "Authorization错误，请重新登录", err)
 	}
+	ReserveDateError = func(err error) error {
+		return errorx.New(http.StatusBadRequest, reserveDateErrorCode, "预约日期错误", err)
+	}
+	ReserveJobNotFoundError = func(err error) error {
+		return errorx.New(http.StatusNotFound, reserveJobNotFoundErrorCode, "预约任务不存在或已开始执行", err)
+	}
+	BurstWindowError = func(err error) error {
+		return errorx.New(http.StatusBadRequest, burstWindowErrorCode, "不在放座时间附近，无法连发抢座", err)
+	}
+	SeatNotFoundError = func(err error) error {
+		return errorx.New(http.StatusNotFound, seatNotFoundErrorCode, "座位不存在", err)
+	}
+	ReservationNotFoundError = func(err error) error {
+		return errorx.New(http.StatusNotFound, reservationNotFoundErrorCode, "没有找到对应的预约", err)
+	}
+	PoolSessionNotFoundError = func(err error) error {
+		return errorx.New(http.StatusNotFound, poolSessionNotFoundErrorCode, "该用户没有缓存的图书馆会话", err)
+	}
+	RoomNotFoundError = func(err error) error {
+		return errorx.New(http.StatusNotFound, roomNotFoundErrorCode, "没有符合条件的房间", err)
+	}
+	ReserveTimeError = func(err error) error {
+		return errorx.New(http.StatusBadRequest, reserveTimeErrorCode, "预约时间段错误", err)
+	}
 )
 
 var (
@@ -44,4 +80,16 @@ var (
 	CreateClientError = func(err error) error {
 		return errorx.New(http.StatusInternalServerError, createClientErrorCode, "创建HTTP客户端失败", err)
 	}
+	CancelReservationError = func(err error) error {
+		return errorx.New(http.StatusInternalServerError, cancelReservationErrorCode, "取消预约失败，请稍后重试", err)
+	}
+	UpstreamTimeoutError = func(err error) error {
+		return errorx.New(http.StatusGatewayTimeout, upstreamTimeoutErrorCode, "图书馆响应超时，请稍后重试", err)
+	}
+	UpstreamUnavailableError = func(err error) error {
+		return errorx.New(http.StatusServiceUnavailable, upstreamUnavailableErrorCode, "图书馆暂时无法访问，请稍后重试", err)
+	}
+	GrabUnconfirmedError = func(err error) error {
+		return errorx.New(http.StatusBadGateway, grabUnconfirmedErrorCode, "图书馆返回预约成功，但预约记录中没有这条预约", err)
+	}
 )
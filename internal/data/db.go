@@ -2,4 +2,4 @@ package data
 
 import "github.com/google/wire"
 
-var ProviderSet = wire.NewSet()
+var ProviderSet = wire.NewSet(NewRedisHealth, NewCredentialVault, NewTokenStore)
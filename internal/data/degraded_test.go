@@ -0,0 +1,73 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Serendipity565/GrabSeat/config"
+	"github.com/Serendipity565/GrabSeat/pkg/ijwt"
+	"github.com/Serendipity565/GrabSeat/pkg/logger"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// newDownRedis 返回一个连不上的 redis 客户端和对应的 RedisHealth
+func newDownRedis(t *testing.T) (redis.Cmdable, *RedisHealth) {
+	conf := &config.RedisConfig{Addr: "127.0.0.1:1", Timeout: 100, CheckInterval: 1}
+	rdb := redis.NewClient(&redis.Options{Addr: conf.Addr, DialTimeout: 100 * time.Millisecond, MaxRetries: -1})
+	health := NewRedisHealth(rdb, conf, logger.NewZapLogger(zap.NewNop()))
+	if health.Healthy() {
+		t.Fatal("期望 redis 不可用")
+	}
+	return rdb, health
+}
+
+func TestDegraded_SessionAndTokens(t *testing.T) {
+	rdb, health := newDownRedis(t)
+	conf := &config.JWTConfig{JwtKey: "secret", EncKey: "enc-key", Timeout: 3600}
+	vault := NewCredentialVault(rdb, ijwt.NewJWT(conf), conf, health)
+	tokens := NewTokenStore(rdb, conf, health)
+	ctx := context.Background()
+
+	// redis 不可用时仍然可以登录，会话保存在本实例
+	sid, err := vault.CreateSession(ctx, "2023000000", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userID, err := vault.Session(ctx, sid); err != nil || userID != "2023000000" {
+		t.Fatalf("期望读取到本实例的会话，实际: %q, %v", userID, err)
+	}
+	if password, err := vault.Credential(ctx, "2023000000"); err != nil || password != "password" {
+		t.Fatalf("期望读取到本实例的凭据，实际: %q, %v", password, err)
+	}
+	if _, err = vault.Session(ctx, "unknown"); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("期望 ErrUnavailable，实际: %v", err)
+	}
+
+	// 刷新令牌的轮换和重复使用检测
+	if err = tokens.RotateRefresh(ctx, sid, "", "r1"); err != nil {
+		t.Fatal(err)
+	}
+	if err = tokens.RotateRefresh(ctx, sid, "r1", "r2"); err != nil {
+		t.Fatal(err)
+	}
+	if err = tokens.RotateRefresh(ctx, sid, "r1", "r3"); !errors.Is(err, ErrRefreshReused) {
+		t.Fatalf("期望 ErrRefreshReused，实际: %v", err)
+	}
+	if err = tokens.RotateRefresh(ctx, "other", "r1", "r2"); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("期望 ErrUnavailable，实际: %v", err)
+	}
+
+	// 吊销只在本实例生效，未知的会话放行
+	if err = tokens.Revoke(ctx, sid); err != nil {
+		t.Fatal(err)
+	}
+	if revoked, err := tokens.IsRevoked(ctx, sid); err != nil || !revoked {
+		t.Fatalf("期望会话已吊销，实际: %v, %v", revoked, err)
+	}
+	if revoked, err := tokens.IsRevoked(ctx, "other"); err != nil || revoked {
+		t.Fatalf("期望放行，实际: %v, %v", revoked, err)
+	}
+}
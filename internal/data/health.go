@@ -0,0 +1,114 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Serendipity565/GrabSeat/config"
+	"github.com/Serendipity565/GrabSeat/pkg/logger"
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrUnavailable redis 不可用，并且本实例中也没有需要的数据
+var ErrUnavailable = errors.New("data: redis unavailable")
+
+// RedisHealth 记录 redis 当前是否可用
+// redis 不可用时限流、会话和快照改用本实例内存中的数据，后台定期 ping 直到恢复；
+// go-redis 的连接池会在下一次请求时自动重新建立连接
+type RedisHealth struct {
+	rdb      redis.Cmdable
+	interval time.Duration
+	timeout  time.Duration
+	log      logger.Logger
+
+	mu      sync.RWMutex
+	healthy bool
+	lastErr error
+	since   time.Time // 进入当前状态的时间
+}
+
+func NewRedisHealth(rdb redis.Cmdable, conf *config.RedisConfig, log logger.Logger) *RedisHealth {
+	h := &RedisHealth{
+		rdb:      rdb,
+		interval: time.Duration(conf.CheckInterval) * time.Second,
+		timeout:  time.Duration(conf.Timeout) * time.Millisecond,
+		log:      log,
+		healthy:  true,
+		since:    time.Now(),
+	}
+	h.check()
+	return h
+}
+
+// Start 在后台定期检查 redis，直到 ctx 结束
+func (h *RedisHealth) Start(ctx context.Context) {
+	go func() {
+		t := time.NewTicker(h.interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				h.check()
+			}
+		}
+	}()
+}
+
+// Healthy redis 当前是否可用
+func (h *RedisHealth) Healthy() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.healthy
+}
+
+// Status 返回 redis 是否可用、最近一次错误以及进入当前状态的时间
+func (h *RedisHealth) Status() (bool, error, time.Time) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.healthy, h.lastErr, h.since
+}
+
+// Fail 请求 redis 失败时调用，立即进入降级模式，不用等到下一次检查
+// 调用方取消或超时导致的失败不代表 redis 不可用，直接忽略
+func (h *RedisHealth) Fail(err error) {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return
+	}
+	h.set(err)
+}
+
+// Ping 立即检查一次 redis，返回耗时
+func (h *RedisHealth) Ping(ctx context.Context) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+	start := time.Now()
+	err := h.rdb.Ping(ctx).Err()
+	h.set(err)
+	return time.Since(start), err
+}
+
+func (h *RedisHealth) check() {
+	_, _ = h.Ping(context.Background())
+}
+
+func (h *RedisHealth) set(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	healthy := err == nil
+	if healthy == h.healthy {
+		if !healthy {
+			h.lastErr = err
+		}
+		return
+	}
+	h.healthy, h.lastErr, h.since = healthy, err, time.Now()
+	if healthy {
+		h.log.Info("redis 已恢复，退出降级模式")
+	} else {
+		h.log.Error("redis 不可用，进入降级模式", logger.Error(err))
+	}
+}
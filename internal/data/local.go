@@ -0,0 +1,87 @@
+package data
+
+import (
+	"sync"
+	"time"
+)
+
+const localPruneInterval = time.Minute
+
+// localCache 本实例内存中的 redis 数据副本，redis 不可用时使用
+type localCache struct {
+	mu     sync.Mutex
+	items  map[string]localItem
+	pruned time.Time
+}
+
+type localItem struct {
+	value   string
+	expires time.Time
+	offline bool // redis 不可用时写入，redis 中没有这条数据
+}
+
+func newLocalCache() *localCache {
+	return &localCache{items: make(map[string]localItem), pruned: time.Now()}
+}
+
+func (c *localCache) Set(key, value string, ttl time.Duration, offline bool) {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items[key] = localItem{value: value, expires: now.Add(ttl), offline: offline}
+
+	// 顺便清理过期的数据，避免内存一直增长
+	if now.Sub(c.pruned) > localPruneInterval {
+		for k, item := range c.items {
+			if now.After(item.expires) {
+				delete(c.items, k)
+			}
+		}
+		c.pruned = now
+	}
+}
+
+// Get 返回未过期的数据
+func (c *localCache) Get(key string) (localItem, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	item, ok := c.items[key]
+	if !ok || time.Now().After(item.expires) {
+		return localItem{}, false
+	}
+	return item, true
+}
+
+// CompareAndSet 当前值等于 old 时换成 value，old 为空时直接写入
+// 返回是否存在未过期的数据，以及是否已经写入
+func (c *localCache) CompareAndSet(key, old, value string, ttl time.Duration) (bool, bool) {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	item, ok := c.items[key]
+	if ok && now.After(item.expires) {
+		ok = false
+	}
+	if old != "" && (!ok || item.value != old) {
+		return ok, false
+	}
+	c.items[key] = localItem{value: value, expires: now.Add(ttl), offline: true}
+	return true, true
+}
+
+// Expire 延长未过期数据的有效期，数据不存在时不做处理
+func (c *localCache) Expire(key string, ttl time.Duration) {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if item, ok := c.items[key]; ok && !now.After(item.expires) {
+		item.expires = now.Add(ttl)
+		c.items[key] = item
+	}
+}
+
+func (c *localCache) Del(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.items, key)
+}
@@ -0,0 +1,12 @@
+-- KEYS[1]: 会话当前刷新令牌的 key
+-- ARGV[1]: 旧的刷新令牌ID，为空表示会话还没有刷新令牌
+-- ARGV[2]: 新的刷新令牌ID
+-- ARGV[3]: 过期时间(秒)
+-- 返回 1 表示替换成功，0 表示旧令牌不是当前有效的令牌
+
+local current = redis.call('GET', KEYS[1])
+if (current == false and ARGV[1] == '') or current == ARGV[1] then
+    redis.call('SET', KEYS[1], ARGV[2], 'EX', ARGV[3])
+    return 1
+end
+return 0
@@ -0,0 +1,113 @@
+package data
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"time"
+
+	"github.com/Serendipity565/GrabSeat/config"
+	"github.com/redis/go-redis/v9"
+)
+
+//go:embed scripts/rotate_refresh.lua
+var rotateRefreshScriptSource string
+
+const (
+	tokenRefreshPrefix = "token:refresh:" // string: 会话ID -> 当前有效的刷新令牌ID
+	tokenRevokedPrefix = "token:revoked:" // string: 被吊销的会话ID
+)
+
+// ErrRefreshReused 刷新令牌已经被使用过，可能已经泄露
+var ErrRefreshReused = errors.New("token: refresh token reused")
+
+// TokenStore 记录每个会话当前有效的刷新令牌，以及被吊销的会话
+type TokenStore interface {
+	// RotateRefresh 把会话当前的刷新令牌从 oldID 换成 newID，oldID 为空表示首次签发
+	// oldID 不是当前有效的令牌时返回 ErrRefreshReused
+	RotateRefresh(ctx context.Context, sessionID, oldID, newID string) error
+	// Revoke 吊销会话，会话下所有的访问令牌和刷新令牌立即失效
+	Revoke(ctx context.Context, sessionID string) error
+	// IsRevoked 会话是否已经被吊销
+	IsRevoked(ctx context.Context, sessionID string) (bool, error)
+}
+
+type redisTokenStore struct {
+	rdb    redis.Cmdable
+	script *redis.Script
+	ttl    time.Duration // 与刷新令牌的有效期一致，超过之后令牌本身已经过期
+	health *RedisHealth
+	// local 本实例签发的刷新令牌和吊销的会话，redis 不可用时使用
+	local *localCache
+}
+
+func NewTokenStore(rdb redis.Cmdable, conf *config.JWTConfig, health *RedisHealth) TokenStore {
+	return &redisTokenStore{
+		rdb:    rdb,
+		script: redis.NewScript(rotateRefreshScriptSource),
+		ttl:    time.Duration(conf.Timeout) * time.Second,
+		health: health,
+		local:  newLocalCache(),
+	}
+}
+
+func (s *redisTokenStore) RotateRefresh(ctx context.Context, sessionID, oldID, newID string) error {
+	key := tokenRefreshPrefix + sessionID
+	if s.health.Healthy() {
+		ok, err := s.script.Run(ctx, s.rdb, []string{key}, oldID, newID, int(s.ttl.Seconds())).Int()
+		if err == nil {
+			if ok != 1 {
+				return ErrRefreshReused
+			}
+			s.local.Set(key, newID, s.ttl, false)
+			return nil
+		}
+		s.health.Fail(err)
+	}
+
+	// redis 不可用时只能轮换本实例签发过的刷新令牌
+	found, swapped := s.local.CompareAndSet(key, oldID, newID, s.ttl)
+	switch {
+	case swapped:
+		return nil
+	case !found:
+		return ErrUnavailable
+	default:
+		return ErrRefreshReused
+	}
+}
+
+func (s *redisTokenStore) Revoke(ctx context.Context, sessionID string) error {
+	s.local.Set(tokenRevokedPrefix+sessionID, "1", s.ttl, true)
+	s.local.Del(tokenRefreshPrefix + sessionID)
+	if !s.health.Healthy() {
+		return nil
+	}
+	_, err := s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, tokenRevokedPrefix+sessionID, 1, s.ttl)
+		pipe.Del(ctx, tokenRefreshPrefix+sessionID)
+		return nil
+	})
+	if err != nil {
+		// 本实例已经记录了吊销，redis 恢复前其他实例可能仍然接受这个会话
+		s.health.Fail(err)
+	}
+	return nil
+}
+
+// IsRevoked redis 不可用时只检查本实例吊销的会话
+// 访问令牌的有效期很短，短时间内放行其他实例吊销的会话，换取 redis 故障期间服务仍然可用
+func (s *redisTokenStore) IsRevoked(ctx context.Context, sessionID string) (bool, error) {
+	if _, ok := s.local.Get(tokenRevokedPrefix + sessionID); ok {
+		return true, nil
+	}
+	if !s.health.Healthy() {
+		return false, nil
+	}
+	n, err := s.rdb.Exists(ctx, tokenRevokedPrefix+sessionID).Result()
+	if err != nil {
+		s.health.Fail(err)
+		return false, nil
+	}
+	return n > 0, nil
+}
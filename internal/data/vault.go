@@ -0,0 +1,167 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/Serendipity565/GrabSeat/config"
+	"github.com/Serendipity565/GrabSeat/pkg/ijwt"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	vaultCredPrefix    = "vault:cred:"    // string: 学号 -> 加密后的 CAS 密码
+	vaultSessionPrefix = "vault:session:" // string: 会话ID -> 学号
+)
+
+// ErrNotFound 会话或凭据不存在，或者已经过期
+var ErrNotFound = errors.New("vault: not found")
+
+// CredentialVault 服务端凭据库
+// token 中只保存会话ID，用户的 CAS 密码加密后按学号保存在 redis 中
+type CredentialVault interface {
+	// CreateSession 保存用户的凭据并创建会话，返回会话ID
+	CreateSession(ctx context.Context, userID, password string) (string, error)
+	// Session 返回会话对应的学号
+	Session(ctx context.Context, sessionID string) (string, error)
+	// Credential 返回用户的 CAS 密码，供登录图书馆和后台预约任务使用
+	Credential(ctx context.Context, userID string) (string, error)
+	// ExtendSession 把会话和对应用户的凭据的有效期重新延长到完整的 ttl，刷新令牌时调用
+	ExtendSession(ctx context.Context, sessionID string) error
+	// DeleteSession 删除会话，用户的凭据保留给其他会话和预约任务使用
+	DeleteSession(ctx context.Context, sessionID string) error
+}
+
+type redisVault struct {
+	rdb    redis.Cmdable
+	enc    *ijwt.JWT
+	ttl    time.Duration // 会话和凭据的有效期，与 token 一致
+	health *RedisHealth
+	// local 读写过的会话和凭据，redis 不可用时已经登录的用户仍然可以使用
+	local *localCache
+}
+
+func NewCredentialVault(rdb redis.Cmdable, enc *ijwt.JWT, conf *config.JWTConfig, health *RedisHealth) CredentialVault {
+	return &redisVault{
+		rdb:    rdb,
+		enc:    enc,
+		ttl:    time.Duration(conf.Timeout) * time.Second,
+		health: health,
+		local:  newLocalCache(),
+	}
+}
+
+func (v *redisVault) CreateSession(ctx context.Context, userID, password string) (string, error) {
+	enPassword, err := v.enc.EncryptPassword(password)
+	if err != nil {
+		return "", err
+	}
+	sessionID := newSessionID()
+	offline := !v.health.Healthy()
+	if !offline {
+		_, err = v.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			// 每次登录都会刷新凭据，保证改过密码后使用的是最新的密码
+			pipe.Set(ctx, vaultCredPrefix+userID, enPassword, v.ttl)
+			pipe.Set(ctx, vaultSessionPrefix+sessionID, userID, v.ttl)
+			return nil
+		})
+		if err != nil {
+			v.health.Fail(err)
+			offline = true
+		}
+	}
+	// redis 不可用时会话只保存在本实例
+	v.local.Set(vaultCredPrefix+userID, enPassword, v.ttl, offline)
+	v.local.Set(vaultSessionPrefix+sessionID, userID, v.ttl, offline)
+	return sessionID, nil
+}
+
+func (v *redisVault) Session(ctx context.Context, sessionID string) (string, error) {
+	return v.get(ctx, vaultSessionPrefix+sessionID)
+}
+
+func (v *redisVault) Credential(ctx context.Context, userID string) (string, error) {
+	enPassword, err := v.get(ctx, vaultCredPrefix+userID)
+	if err != nil {
+		return "", err
+	}
+	return v.enc.DecryptPassword(enPassword)
+}
+
+func (v *redisVault) ExtendSession(ctx context.Context, sessionID string) error {
+	userID, err := v.Session(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	sessionKey, credKey := vaultSessionPrefix+sessionID, vaultCredPrefix+userID
+	if v.health.Healthy() {
+		_, err = v.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Expire(ctx, sessionKey, v.ttl)
+			pipe.Expire(ctx, credKey, v.ttl)
+			return nil
+		})
+		if err != nil {
+			v.health.Fail(err)
+		}
+	}
+	v.local.Expire(sessionKey, v.ttl)
+	v.local.Expire(credKey, v.ttl)
+	return nil
+}
+
+func (v *redisVault) DeleteSession(ctx context.Context, sessionID string) error {
+	v.local.Del(vaultSessionPrefix + sessionID)
+	if !v.health.Healthy() {
+		return nil
+	}
+	return v.rdb.Del(ctx, vaultSessionPrefix+sessionID).Err()
+}
+
+// get 优先读取 redis，redis 不可用时读取本实例的副本
+func (v *redisVault) get(ctx context.Context, key string) (string, error) {
+	if v.health.Healthy() {
+		var (
+			get  *redis.StringCmd
+			pttl *redis.DurationCmd
+		)
+		// 每条命令的结果中都带有各自的错误
+		_, _ = v.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			get = pipe.Get(ctx, key)
+			pttl = pipe.PTTL(ctx, key)
+			return nil
+		})
+		value, err := get.Result()
+		switch {
+		case err == nil:
+			// 本实例的副本与 redis 中的数据同时过期
+			if ttl := pttl.Val(); ttl > 0 {
+				v.local.Set(key, value, ttl, false)
+			} else {
+				v.local.Set(key, value, v.ttl, false)
+			}
+			return value, nil
+		case errors.Is(err, redis.Nil):
+			// redis 不可用期间创建的数据 redis 中没有
+			if item, ok := v.local.Get(key); ok && item.offline {
+				return item.value, nil
+			}
+			v.local.Del(key)
+			return "", ErrNotFound
+		default:
+			v.health.Fail(err)
+		}
+	}
+	if item, ok := v.local.Get(key); ok {
+		return item.value, nil
+	}
+	return "", ErrUnavailable
+}
+
+func newSessionID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,574 @@
+// Package fakekjyy 提供一个本地的华师 CAS 与 kjyy 空间预约系统模拟服务，
+// 用于在不访问真实图书馆的情况下对 crawler 和 service 做端到端测试
+package fakekjyy
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Serendipity565/GrabSeat/service/crawler"
+)
+
+const (
+	casCookie     = "CASTGC"
+	sessionCookie = "ASP.NET_SessionId"
+	timeLayout    = "2006-01-02 15:04"
+
+	msgBadPassword = "您输入的用户名或密码有误"
+	msgNotLogin    = "未登录或登录超时，session =null 请重新登录"
+	msgNoData      = "<tbody><tr><td colspan='6' class='text-center'>没有数据</td></tr></tbody>"
+)
+
+// Room 房间信息
+type Room struct {
+	ID   string
+	Name string // 例如 南湖分馆一楼
+}
+
+// Seat 座位信息
+type Seat struct {
+	DevID  string
+	Title  string // 例如 N1224
+	RoomID string
+}
+
+// Reservation 一条预约记录
+type Reservation struct {
+	ID        string // rsvId
+	DevID     string
+	UserID    string // 预约人学号
+	Start     time.Time
+	End       time.Time
+	CreatedAt time.Time
+}
+
+type user struct {
+	password string
+	name     string
+}
+
+// Server 模拟的 CAS + kjyy 服务，所有状态都保存在内存中，可以在调用之间修改
+type Server struct {
+	srv *httptest.Server
+
+	mu           sync.Mutex
+	users        map[string]user   // 学号 -> 用户
+	loginTickets map[string]string // lt -> execution
+	casSessions  map[string]string // CASTGC -> 学号
+	svcTickets   map[string]string // ST -> 学号
+	sessions     map[string]string // ASP.NET_SessionId -> 学号
+	rooms        map[string]*Room
+	roomOrder    []string
+	seats        map[string]*Seat // devId -> 座位
+	seatOrder    []string
+	reservations map[string]*Reservation
+	nextRsvID    int
+	now          func() time.Time
+}
+
+// New 启动一个模拟服务，使用完后需要调用 Close
+func New() *Server {
+	s := &Server{
+		users:        make(map[string]user),
+		loginTickets: make(map[string]string),
+		casSessions:  make(map[string]string),
+		svcTickets:   make(map[string]string),
+		sessions:     make(map[string]string),
+		rooms:        make(map[string]*Room),
+		seats:        make(map[string]*Seat),
+		reservations: make(map[string]*Reservation),
+		nextRsvID:    175901668,
+		now:          time.Now,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/cas/login", s.handleCASLogin)
+	mux.HandleFunc("/loginall.aspx", s.handleLibraryLogin)
+	mux.HandleFunc("/ClientWeb/pro/ajax/device.aspx", s.handleDevice)
+	mux.HandleFunc("/ClientWeb/pro/ajax/reserve.aspx", s.handleReserve)
+	mux.HandleFunc("/ClientWeb/pro/ajax/center.aspx", s.handleCenter)
+	mux.HandleFunc("/ClientWeb/pro/ajax/room.aspx", s.handleRoom)
+	s.srv = httptest.NewServer(mux)
+	return s
+}
+
+// Close 关闭模拟服务
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// URL 模拟服务的根地址
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+func (s *Server) CASURL() string {
+	return s.srv.URL + "/cas/login"
+}
+
+func (s *Server) LibraryLoginURL() string {
+	return s.srv.URL + "/cas/login?service=" + s.srv.URL + "/loginall.aspx?page="
+}
+
+func (s *Server) SearchURL() string {
+	return s.srv.URL + "/ClientWeb/pro/ajax/device.aspx"
+}
+
+func (s *Server) GrabURL() string {
+	return s.srv.URL + "/ClientWeb/pro/ajax/reserve.aspx"
+}
+
+func (s *Server) PersonURL() string {
+	return s.srv.URL + "/ClientWeb/pro/ajax/center.aspx"
+}
+
+func (s *Server) RoomURL() string {
+	return s.srv.URL + "/ClientWeb/pro/ajax/room.aspx"
+}
+
+// Endpoints 指向模拟服务的上游地址，可以直接传给 crawler.NewKjyyBackendWithEndpoints
+func (s *Server) Endpoints() crawler.Endpoints {
+	return crawler.NewEndpoints(s.URL(), s.URL())
+}
+
+// AddUser 添加一个可以登录的用户
+func (s *Server) AddUser(userID, password, name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[userID] = user{password: password, name: name}
+}
+
+// AddRoom 添加一个房间
+func (s *Server) AddRoom(roomID, name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.rooms[roomID]; !ok {
+		s.roomOrder = append(s.roomOrder, roomID)
+	}
+	s.rooms[roomID] = &Room{ID: roomID, Name: name}
+}
+
+// AddSeat 在房间中添加一个座位
+func (s *Server) AddSeat(roomID, devID, title string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.seats[devID]; !ok {
+		s.seatOrder = append(s.seatOrder, devID)
+	}
+	s.seats[devID] = &Seat{DevID: devID, Title: title, RoomID: roomID}
+}
+
+// Book 直接写入一条预约记录（不做冲突检查），返回 rsvId
+func (s *Server) Book(devID, userID string, start, end time.Time) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.book(devID, userID, start, end)
+}
+
+// Cancel 删除一条预约记录
+func (s *Server) Cancel(rsvID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.reservations[rsvID]; !ok {
+		return false
+	}
+	delete(s.reservations, rsvID)
+	return true
+}
+
+// ClearReservations 清空所有预约记录
+func (s *Server) ClearReservations() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reservations = make(map[string]*Reservation)
+}
+
+// Reservations 返回当前所有预约记录，按 rsvId 排序
+func (s *Server) Reservations() []Reservation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := make([]Reservation, 0, len(s.reservations))
+	for _, r := range s.reservations {
+		res = append(res, *r)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+	return res
+}
+
+// ExpireSessions 让所有 kjyy session 失效，模拟登录超时
+func (s *Server) ExpireSessions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions = make(map[string]string)
+}
+
+// SetNow 替换服务内部使用的当前时间
+func (s *Server) SetNow(now func() time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = now
+}
+
+func (s *Server) handleCASLogin(w http.ResponseWriter, r *http.Request) {
+	service := r.URL.Query().Get("service")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.Method == http.MethodGet {
+		// 已经登录过 CAS，直接签发 service ticket
+		if c, err := r.Cookie(casCookie); err == nil && service != "" {
+			if uid, ok := s.casSessions[c.Value]; ok {
+				s.redirectWithTicket(w, r, service, uid)
+				return
+			}
+		}
+		s.writeLoginPage(w, "")
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	lt, exec := r.PostForm.Get("lt"), r.PostForm.Get("execution")
+	if want, ok := s.loginTickets[lt]; !ok || want != exec {
+		s.writeLoginPage(w, "登录流程已过期，请重新登录")
+		return
+	}
+	delete(s.loginTickets, lt)
+
+	uid := r.PostForm.Get("username")
+	u, ok := s.users[uid]
+	if !ok || u.password != r.PostForm.Get("password") {
+		s.writeLoginPage(w, msgBadPassword)
+		return
+	}
+
+	tgc := "TGT-" + randomToken()
+	s.casSessions[tgc] = uid
+	http.SetCookie(w, &http.Cookie{Name: casCookie, Value: tgc, Path: "/", HttpOnly: true})
+	if service != "" {
+		s.redirectWithTicket(w, r, service, uid)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, `<html><body><div id="msg" class="success"><h2>登录成功</h2></div></body></html>`)
+}
+
+func (s *Server) writeLoginPage(w http.ResponseWriter, errMsg string) {
+	lt, exec := "LT-"+randomToken(), "e1s1"
+	s.loginTickets[lt] = exec
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	http.SetCookie(w, &http.Cookie{Name: "JSESSIONID", Value: randomToken(), Path: "/cas"})
+	errDiv := ""
+	if errMsg != "" {
+		errDiv = fmt.Sprintf(`<div id="msg" class="errors">%s</div>`, html.EscapeString(errMsg))
+	}
+	fmt.Fprintf(w, `<html><body><form id="fm1" method="post">%s
+<input type="text" name="username"/><input type="password" name="password"/>
+<input type="hidden" name="lt" value="%s"/>
+<input type="hidden" name="execution" value="%s"/>
+<input type="hidden" name="_eventId" value="submit"/>
+</form></body></html>`, errDiv, lt, exec)
+}
+
+func (s *Server) redirectWithTicket(w http.ResponseWriter, r *http.Request, service, uid string) {
+	u, err := url.Parse(service)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	st := "ST-" + randomToken()
+	s.svcTickets[st] = uid
+	q := u.Query()
+	q.Set("ticket", st)
+	u.RawQuery = q.Encode()
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+func (s *Server) handleLibraryLogin(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := r.URL.Query().Get("ticket")
+	uid, ok := s.svcTickets[st]
+	if !ok {
+		http.Error(w, "invalid ticket", http.StatusForbidden)
+		return
+	}
+	delete(s.svcTickets, st)
+
+	sid := randomToken()
+	s.sessions[sid] = uid
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: sid, Path: "/", HttpOnly: true})
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, `<html><body><div class="uni_trans">空间预约系统</div></body></html>`)
+}
+
+// currentUser 根据 session cookie 获取当前登录用户，调用方需持有锁
+func (s *Server) currentUser(r *http.Request) (string, bool) {
+	c, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return "", false
+	}
+	uid, ok := s.sessions[c.Value]
+	return uid, ok
+}
+
+type actResp struct {
+	Ret  int         `json:"ret"`
+	Act  string      `json:"act"`
+	Msg  string      `json:"msg"`
+	Data interface{} `json:"data"`
+	Ext  interface{} `json:"ext"`
+}
+
+func writeAct(w http.ResponseWriter, ret int, act, msg string, data interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_ = json.NewEncoder(w).Encode(actResp{Ret: ret, Act: act, Msg: msg, Data: data})
+}
+
+type tsJSON struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+	Owner string `json:"owner"`
+	State string `json:"state"`
+}
+
+type seatJSON struct {
+	Title  string   `json:"title"`
+	DevId  string   `json:"devId"`
+	RoomId string   `json:"roomId"`
+	Ts     []tsJSON `json:"ts"`
+}
+
+func (s *Server) handleDevice(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	act := q.Get("act")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.currentUser(r); !ok {
+		writeAct(w, -1, act, msgNotLogin, nil)
+		return
+	}
+	if act != "get_rsv_sta" {
+		writeAct(w, 0, act, "未知操作", nil)
+		return
+	}
+
+	roomID, date := q.Get("room_id"), q.Get("date")
+	data := make([]seatJSON, 0)
+	for _, devID := range s.seatOrder {
+		seat := s.seats[devID]
+		if seat.RoomID != roomID {
+			continue
+		}
+		item := seatJSON{Title: seat.Title, DevId: seat.DevID, RoomId: seat.RoomID, Ts: make([]tsJSON, 0)}
+		for _, rsv := range s.sortedReservations() {
+			if rsv.DevID != devID || rsv.Start.Format("2006-01-02") != date {
+				continue
+			}
+			item.Ts = append(item.Ts, tsJSON{
+				Start: rsv.Start.Format(timeLayout),
+				End:   rsv.End.Format(timeLayout),
+				Owner: s.users[rsv.UserID].name,
+				State: s.stateOf(rsv),
+			})
+		}
+		data = append(data, item)
+	}
+	writeAct(w, 1, act, "ok", data)
+}
+
+type roomJSON struct {
+	RoomId   string `json:"roomId"`
+	RoomName string `json:"roomName"`
+	LabName  string `json:"labName"`
+}
+
+func (s *Server) handleRoom(w http.ResponseWriter, r *http.Request) {
+	act := r.URL.Query().Get("act")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.currentUser(r); !ok {
+		writeAct(w, -1, act, msgNotLogin, nil)
+		return
+	}
+	if act != "get_rm_sta" {
+		writeAct(w, 0, act, "未知操作", nil)
+		return
+	}
+
+	data := make([]roomJSON, 0, len(s.roomOrder))
+	for _, roomID := range s.roomOrder {
+		room := s.rooms[roomID]
+		data = append(data, roomJSON{RoomId: room.ID, RoomName: room.Name, LabName: "图书馆"})
+	}
+	writeAct(w, 1, act, "ok", data)
+}
+
+func (s *Server) handleReserve(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	act := q.Get("act")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	uid, ok := s.currentUser(r)
+	if !ok {
+		writeAct(w, -1, act, msgNotLogin, nil)
+		return
+	}
+
+	switch act {
+	case "set_resv":
+		seat, ok := s.seats[q.Get("dev_id")]
+		if !ok {
+			writeAct(w, 0, act, "设备不存在", nil)
+			return
+		}
+		start, err1 := time.ParseInLocation(timeLayout, q.Get("start"), time.Local)
+		end, err2 := time.ParseInLocation(timeLayout, q.Get("end"), time.Local)
+		if err1 != nil || err2 != nil || !start.Before(end) {
+			writeAct(w, 0, act, "预约时间不合法", nil)
+			return
+		}
+		for _, rsv := range s.reservations {
+			if !(start.Before(rsv.End) && rsv.Start.Before(end)) {
+				continue
+			}
+			if rsv.DevID == seat.DevID {
+				writeAct(w, 0, act, fmt.Sprintf("[%s]该时间段已被预约", seat.Title), nil)
+				return
+			}
+			if rsv.UserID == uid {
+				writeAct(w, 0, act, "您在该时间段已有预约", nil)
+				return
+			}
+		}
+		s.book(seat.DevID, uid, start, end)
+		writeAct(w, 1, act, "操作成功！", nil)
+	case "del_resv":
+		rsv, ok := s.reservations[q.Get("id")]
+		if !ok || rsv.UserID != uid {
+			writeAct(w, 0, act, "预约不存在或已被删除", nil)
+			return
+		}
+		delete(s.reservations, rsv.ID)
+		writeAct(w, 1, act, "操作成功！", nil)
+	default:
+		writeAct(w, 0, act, "未知操作", nil)
+	}
+}
+
+func (s *Server) handleCenter(w http.ResponseWriter, r *http.Request) {
+	act := r.URL.Query().Get("act")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	uid, ok := s.currentUser(r)
+	if !ok {
+		writeAct(w, -1, act, msgNotLogin, nil)
+		return
+	}
+	if act != "get_History_resv" {
+		writeAct(w, 0, act, "未知操作", nil)
+		return
+	}
+
+	var sb strings.Builder
+	for _, rsv := range s.sortedReservations() {
+		if rsv.UserID == uid {
+			sb.WriteString(s.renderHistory(rsv))
+		}
+	}
+	if sb.Len() == 0 {
+		sb.WriteString(msgNoData)
+	}
+	writeAct(w, 1, act, sb.String(), nil)
+}
+
+// renderHistory 按 center.aspx 的格式渲染一条预约记录
+func (s *Server) renderHistory(rsv *Reservation) string {
+	seat := s.seats[rsv.DevID]
+	roomName := ""
+	if room, ok := s.rooms[seat.RoomID]; ok {
+		roomName = room.Name
+	}
+	effective := "<span style='color:orange' class='uni_trans'>未生效</span>"
+	if s.stateOf(rsv) == "doing" {
+		effective = "<span style='color:green' class='uni_trans'>已生效</span>"
+	}
+	created := rsv.CreatedAt.Format(timeLayout)
+	return fmt.Sprintf("<tbody date='%s' state='4482' over='false'>"+
+		"<tr class='head'><td colspan='6'><h3></h3><span><span class='orange uni_trans'>预约成功</span></span><span class='pull-right'><span class='grey'>%s</span></span></td></tr>"+
+		"<tr class='content'><td><div class='box'><a>%s</a><div class='grey'>%s</div</div></td><td>%s</td><td style='max-width:300px'><span class='grey'>个人预约</span></td>"+
+		"<td><div><div><span class='grey'>开始:</span> <span class='text-primary'>%s</span></div><div><span class='grey'>结束:</span> <span class='text-primary'>%s</span></div></div></td>"+
+		"<td><div><span style='color:green' class='uni_trans'>预约成功</span>,%s,<span style='color:green' class='uni_trans'>审核通过</span></div><div style='font-size:12px;color:#777;'></div></td>"+
+		"<td class='text-center' style='vertical-align: middle;'><a class='click' rsvId='%s' onclick='delRsv(this);'>取消</a></td></tr></tbody>",
+		created, created, seat.Title, roomName, s.users[rsv.UserID].name,
+		rsv.Start.Format("01-02 15:04"), rsv.End.Format("01-02 15:04"), effective, rsv.ID)
+}
+
+// book 写入预约记录，调用方需持有锁
+func (s *Server) book(devID, userID string, start, end time.Time) string {
+	id := strconv.Itoa(s.nextRsvID)
+	s.nextRsvID++
+	s.reservations[id] = &Reservation{
+		ID:        id,
+		DevID:     devID,
+		UserID:    userID,
+		Start:     start,
+		End:       end,
+		CreatedAt: s.now(),
+	}
+	return id
+}
+
+// sortedReservations 按开始时间排序的预约记录，调用方需持有锁
+func (s *Server) sortedReservations() []*Reservation {
+	res := make([]*Reservation, 0, len(s.reservations))
+	for _, r := range s.reservations {
+		res = append(res, r)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Start.Equal(res[j].Start) {
+			return res[i].ID < res[j].ID
+		}
+		return res[i].Start.Before(res[j].Start)
+	})
+	return res
+}
+
+// stateOf 预约当前的使用状态，调用方需持有锁
+func (s *Server) stateOf(rsv *Reservation) string {
+	now := s.now()
+	if !now.Before(rsv.Start) && now.Before(rsv.End) {
+		return "doing"
+	}
+	return "undo"
+}
+
+func randomToken() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
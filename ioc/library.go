@@ -0,0 +1,17 @@
+package ioc
+
+import (
+	"fmt"
+
+	"github.com/Serendipity565/GrabSeat/config"
+	"github.com/Serendipity565/GrabSeat/service/crawler"
+)
+
+// InitClientFactory 创建访问图书馆的 http.Client 工厂，代理或 CA 证书无效时启动失败
+func InitClientFactory(conf *config.LibraryConfig) *crawler.ClientFactory {
+	clients, err := crawler.NewClientFactory(conf)
+	if err != nil {
+		panic(fmt.Sprintf("无法创建图书馆 http 客户端: %v", err))
+	}
+	return clients
+}
@@ -1,22 +1,22 @@
 package ioc
 
 import (
-	"context"
-	"fmt"
+	"time"
 
 	"github.com/Serendipity565/GrabSeat/config"
 	"github.com/redis/go-redis/v9"
 )
 
+// InitRedis 创建 redis 客户端
+// 启动时不检查连接，redis 不可用时服务以降级模式运行，由 data.RedisHealth 在后台检查并重连
 func InitRedis(conf *config.RedisConfig) redis.Cmdable {
-	ctx := context.Background()
-	rdb := redis.NewClient(&redis.Options{
-		Addr:     conf.Addr,
-		Password: conf.Password,
-		DB:       conf.DB,
+	timeout := time.Duration(conf.Timeout) * time.Millisecond
+	return redis.NewClient(&redis.Options{
+		Addr:         conf.Addr,
+		Password:     conf.Password,
+		DB:           conf.DB,
+		DialTimeout:  timeout,
+		ReadTimeout:  timeout,
+		WriteTimeout: timeout,
 	})
-	if err := rdb.Ping(ctx).Err(); err != nil {
-		panic(fmt.Sprintf("Redis 连接失败: %v", err))
-	}
-	return rdb
 }
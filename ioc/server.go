@@ -0,0 +1,25 @@
+package ioc
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Serendipity565/GrabSeat/config"
+	"github.com/gin-gonic/gin"
+)
+
+func InitServer(conf *config.ServerConfig, r *gin.Engine) *http.Server {
+	// 限流按 ClientIP 区分，不配置可信代理时任何人都可以伪造 X-Forwarded-For
+	if err := r.SetTrustedProxies(conf.TrustedProxies); err != nil {
+		panic(fmt.Sprintf("可信代理配置无效: %v", err))
+	}
+	return &http.Server{
+		Addr:              conf.Addr,
+		Handler:           r,
+		ReadTimeout:       time.Duration(conf.ReadTimeout) * time.Second,
+		ReadHeaderTimeout: time.Duration(conf.ReadHeaderTimeout) * time.Second,
+		WriteTimeout:      time.Duration(conf.WriteTimeout) * time.Second,
+		IdleTimeout:       time.Duration(conf.IdleTimeout) * time.Second,
+	}
+}
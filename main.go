@@ -1,9 +1,18 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/Serendipity565/GrabSeat/config"
+	"github.com/Serendipity565/GrabSeat/internal/data"
+	"github.com/Serendipity565/GrabSeat/pkg/logger"
 	"github.com/Serendipity565/GrabSeat/service"
 
-	"github.com/gin-gonic/gin"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
@@ -15,14 +24,70 @@ import (
 func main() {
 	initViper()
 	app := InitApp()
-	//app.t.StartDailyTask()
-	app.r.Run(":8080")
+	if err := app.Run(); err != nil {
+		panic(err)
+	}
 }
 
 type App struct {
-	// Logger *log.Logger
-	r *gin.Engine
-	t *service.Ticker
+	srv  *http.Server
+	conf *config.ServerConfig
+	t    *service.Ticker
+	h    *data.RedisHealth
+	gs   service.GrabberService
+	l    logger.Logger
+}
+
+// Run 启动服务并阻塞，收到 SIGINT 或 SIGTERM 后优雅关闭：
+// 停止接收新请求并等待处理中的请求，停止定时器并等待预约任务，最后清理 cookie 池和刷新日志
+func (a *App) Run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	a.h.Start(ctx)
+	if err := a.t.Start(); err != nil {
+		return err
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		var err error
+		if a.conf.CertFile != "" {
+			err = a.srv.ListenAndServeTLS(a.conf.CertFile, a.conf.KeyFile)
+		} else {
+			err = a.srv.ListenAndServe()
+		}
+		if errors.Is(err, http.ErrServerClosed) {
+			err = nil
+		}
+		serveErr <- err
+	}()
+	a.l.Info("服务已启动", logger.String("addr", a.srv.Addr))
+
+	var err error
+	select {
+	case err = <-serveErr:
+		// 监听失败时也要停止定时器
+	case <-ctx.Done():
+		a.l.Info("收到退出信号，开始关闭服务")
+	}
+	stop()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(a.conf.ShutdownTimeout)*time.Second)
+	defer cancel()
+	errs := []error{err}
+	if err := a.srv.Shutdown(shutdownCtx); err != nil {
+		errs = append(errs, err)
+	}
+	if err := a.t.Stop(shutdownCtx); err != nil {
+		a.l.Warn("等待预约任务结束超时", logger.Error(err))
+		errs = append(errs, err)
+	}
+	a.gs.CleanupExpired()
+	a.l.Info("服务已关闭")
+	// 标准输出不支持 sync，忽略它的错误
+	_ = a.l.Sync()
+	return errors.Join(errs...)
 }
 
 func initViper() {
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/Serendipity565/GrabSeat/api/response"
+	"github.com/Serendipity565/GrabSeat/internal/data"
 	"github.com/Serendipity565/GrabSeat/pkg/ginx"
 	"github.com/Serendipity565/GrabSeat/pkg/ijwt"
 	"github.com/prometheus/client_golang/prometheus"
@@ -25,10 +26,12 @@ var (
 
 type AuthMiddleware struct {
 	jwtHandler *ijwt.JWT
+	vault      data.CredentialVault
+	tokens     data.TokenStore
 }
 
-func NewAuthMiddleware(jwtHandler *ijwt.JWT) *AuthMiddleware {
-	return &AuthMiddleware{jwtHandler: jwtHandler}
+func NewAuthMiddleware(jwtHandler *ijwt.JWT, vault data.CredentialVault, tokens data.TokenStore) *AuthMiddleware {
+	return &AuthMiddleware{jwtHandler: jwtHandler, vault: vault, tokens: tokens}
 }
 
 func (am *AuthMiddleware) MiddlewareFunc() gin.HandlerFunc {
@@ -67,6 +70,33 @@ func (am *AuthMiddleware) MiddlewareFunc() gin.HandlerFunc {
 			return
 		}
 
+		// 检查会话是否已经被吊销（退出登录或刷新令牌被重复使用）
+		revoked, err := am.tokens.IsRevoked(ctx, uc.ID)
+		if err != nil || revoked {
+			if err == nil {
+				err = errors.New("会话已被吊销")
+			}
+			ctx.Error(err)
+			ctx.JSON(http.StatusUnauthorized, response.Response{
+				Code: http.StatusUnauthorized,
+				Msg:  "会话已失效，请重新登录",
+				Data: nil,
+			})
+			return
+		}
+
+		// 从凭据库中解析会话对应的用户
+		uc.UserId, err = am.vault.Session(ctx, uc.ID)
+		if err != nil {
+			ctx.Error(err)
+			ctx.JSON(http.StatusUnauthorized, response.Response{
+				Code: http.StatusUnauthorized,
+				Msg:  "会话已失效，请重新登录",
+				Data: nil,
+			})
+			return
+		}
+
 		ginx.SetClaims(ctx, uc)
 
 		userCount.WithLabelValues(uc.UserId).Inc()
@@ -1,6 +1,9 @@
 package middleware
 
 import (
+	"net/url"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/Serendipity565/GrabSeat/config"
@@ -10,32 +13,83 @@ import (
 )
 
 type CorsMiddleware struct {
-	allowedOrigins []string
+	conf      *config.MiddlewareConfig
+	allowAll  bool
+	exact     map[string]bool
+	wildcards []wildcardOrigin
+	patterns  []*regexp.Regexp
+}
+
+// wildcardOrigin 通配子域名，例如 https://*.example.com 匹配 https://a.example.com，不匹配 https://example.com
+type wildcardOrigin struct {
+	scheme string // https://
+	suffix string // .example.com，可以带端口
 }
 
 func NewCorsMiddleware(conf *config.MiddlewareConfig) *CorsMiddleware {
-	return &CorsMiddleware{allowedOrigins: conf.AllowedOrigins}
+	cm := &CorsMiddleware{conf: conf, exact: make(map[string]bool)}
+	for _, origin := range conf.AllowedOrigins {
+		switch {
+		case origin == "*":
+			cm.allowAll = true
+		case strings.HasPrefix(origin, "regex:"):
+			// 正则已经在读取配置时校验过
+			cm.patterns = append(cm.patterns, regexp.MustCompile(strings.TrimPrefix(origin, "regex:")))
+		case strings.Contains(origin, "://*."):
+			scheme, host, _ := strings.Cut(origin, "*")
+			cm.wildcards = append(cm.wildcards, wildcardOrigin{scheme: strings.ToLower(scheme), suffix: strings.ToLower(host)})
+		default:
+			cm.exact[strings.ToLower(strings.TrimSuffix(origin, "/"))] = true
+		}
+	}
+	return cm
 }
 
-func (cm *CorsMiddleware) MiddlewareFunc() gin.HandlerFunc {
-	return cors.New(cors.Config{
-		// 允许的请求头
-		AllowHeaders: []string{"Content-ContentType", "Authorization", "Origin"},
-		// 是否允许携带凭证（如 Cookies）
-		AllowCredentials: true,
-		// 解决跨域问题,这个地方允许所有请求跨域了,之后要改成允许前端的请求,比如localhost
-		AllowOriginFunc: func(origin string) bool {
-			//暂时允许所有跨域请求,根据需要进行调整
+// Allow 来源是否在允许的列表中
+func (cm *CorsMiddleware) Allow(origin string) bool {
+	if cm.allowAll {
+		return true
+	}
+	raw := origin
+	origin = strings.ToLower(origin)
+	if cm.exact[origin] {
+		return true
+	}
+	for _, w := range cm.wildcards {
+		sub, ok := strings.CutPrefix(origin, w.scheme)
+		if !ok {
+			continue
+		}
+		sub, ok = strings.CutSuffix(sub, w.suffix)
+		// 通配的部分必须是合法的子域名，不能包含路径、端口或者用户信息
+		if ok && sub != "" && !strings.ContainsAny(sub, "/:@?#") {
+			if _, err := url.Parse(origin); err == nil {
+				return true
+			}
+		}
+	}
+	for _, p := range cm.patterns {
+		if p.MatchString(raw) {
 			return true
-			//只允许在列表里面的origin可以跨域
-			//if slices.Contains(cm.allowedOrigins, origin) {
-			//	return true
-			//} else {
-			//	return false
-			//}
-		},
+		}
+	}
+	return false
+}
 
+func (cm *CorsMiddleware) MiddlewareFunc() gin.HandlerFunc {
+	cfg := cors.Config{
+		AllowMethods:     cm.conf.AllowedMethods,
+		AllowHeaders:     cm.conf.AllowedHeaders,
+		ExposeHeaders:    cm.conf.ExposedHeaders,
+		AllowCredentials: cm.conf.AllowCredentials,
 		// 预检请求的缓存时间
-		MaxAge: 12 * time.Hour,
-	})
+		MaxAge: time.Duration(cm.conf.MaxAge) * time.Second,
+	}
+	if cm.allowAll {
+		// 读取配置时已经保证 * 不会和 AllowCredentials 同时出现
+		cfg.AllowAllOrigins = true
+	} else {
+		cfg.AllowOriginFunc = cm.Allow
+	}
+	return cors.New(cfg)
 }
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Serendipity565/GrabSeat/config"
+	"github.com/gin-gonic/gin"
+)
+
+func TestCorsMiddleware_Allow(t *testing.T) {
+	cm := NewCorsMiddleware(&config.MiddlewareConfig{AllowedOrigins: []string{
+		"http://localhost:5173",
+		"https://*.example.com",
+		`regex:^https://grab-[0-9]+\.ccnu\.edu\.cn$`,
+	}})
+
+	cases := map[string]bool{
+		"http://localhost:5173":           true,
+		"http://LOCALHOST:5173":           true,
+		"http://localhost:3000":           false,
+		"https://a.example.com":           true,
+		"https://a.b.example.com":         true,
+		"https://example.com":             false,
+		"http://a.example.com":            false,
+		"https://evil.com/.example.com":   false,
+		"https://a.example.com.evil.com":  false,
+		"https://grab-1.ccnu.edu.cn":      true,
+		"https://grab-x.ccnu.edu.cn":      false,
+		"https://grab-1.ccnu.edu.cn.evil": false,
+	}
+	for origin, want := range cases {
+		if got := cm.Allow(origin); got != want {
+			t.Errorf("%s: 期望 %v，实际 %v", origin, want, got)
+		}
+	}
+}
+
+func TestCorsMiddleware_Headers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cm := NewCorsMiddleware(&config.MiddlewareConfig{
+		AllowedOrigins: []string{"http://localhost:5173"},
+		AllowedMethods: []string{"GET", "POST"},
+		AllowedHeaders: []string{"Content-Type", "Authorization"},
+		MaxAge:         60,
+	})
+	r := gin.New()
+	r.Use(cm.MiddlewareFunc())
+	r.POST("/ping", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	preflight := func(origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodOptions, "/ping", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		req.Header.Set("Access-Control-Request-Headers", "Content-Type")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := preflight("http://localhost:5173")
+	if w.Header().Get("Access-Control-Allow-Origin") != "http://localhost:5173" {
+		t.Fatalf("期望允许来源，实际: %v", w.Header())
+	}
+	if got := w.Header().Get("Access-Control-Allow-Headers"); got != "Content-Type,Authorization" {
+		t.Fatalf("期望允许 Content-Type，实际: %s", got)
+	}
+
+	w = preflight("https://evil.com")
+	if w.Code != http.StatusForbidden || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("期望拒绝来源，实际: %d %v", w.Code, w.Header())
+	}
+}
@@ -1,15 +1,21 @@
 package middleware
 
 import (
+	"context"
 	_ "embed"
 	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/Serendipity565/GrabSeat/api/response"
 	"github.com/Serendipity565/GrabSeat/config"
+	"github.com/Serendipity565/GrabSeat/internal/data"
+	"github.com/Serendipity565/GrabSeat/pkg/ijwt"
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
 )
@@ -17,46 +23,67 @@ import (
 //go:embed scripts/limiter.lua
 var limiterScriptSource string // lua 脚本内容将被加载到这个字符串变量中
 
-type LimitMiddleware struct {
+// routeLimit 某个路由的令牌桶配置
+type routeLimit struct {
 	capacity     int // 容量
 	fillInterval int // 每秒补充令牌的次数
 	quantum      int // 每次发放令牌数量
-	client       redis.Cmdable
-	script       *redis.Script
+	byRoute      bool
+	byUser       bool
+	byIP         bool
+}
+
+func newRouteLimit(capacity, fillInterval, quantum int, keyBy []string) *routeLimit {
+	rl := &routeLimit{capacity: capacity, fillInterval: fillInterval, quantum: quantum}
+	for _, k := range keyBy {
+		switch k {
+		case "route":
+			rl.byRoute = true
+		case "user":
+			rl.byUser = true
+		case "ip":
+			rl.byIP = true
+		}
+	}
+	return rl
 }
 
-func NewLimitMiddleware(conf *config.LimiterConfig, client redis.Cmdable) *LimitMiddleware {
+type LimitMiddleware struct {
+	defaults   *routeLimit
+	routes     map[string]*routeLimit // 注册的路由 -> 单独的配置
+	client     redis.Cmdable
+	script     *redis.Script
+	jwtHandler *ijwt.JWT
+	health     *data.RedisHealth
+	local      *localLimiter // redis 不可用时使用
+}
+
+func NewLimitMiddleware(conf *config.LimiterConfig, client redis.Cmdable, jwtHandler *ijwt.JWT, health *data.RedisHealth) *LimitMiddleware {
+	routes := make(map[string]*routeLimit, len(conf.Routes))
+	for _, rc := range conf.Routes {
+		routes[rc.Path] = newRouteLimit(rc.Capacity, rc.FillInterval, rc.Quantum, rc.KeyBy)
+	}
 	return &LimitMiddleware{
-		capacity:     conf.Capacity,
-		fillInterval: conf.FillInterval,
-		quantum:      conf.Quantum,
-		client:       client,
-		script:       redis.NewScript(limiterScriptSource),
+		defaults:   newRouteLimit(conf.Capacity, conf.FillInterval, conf.Quantum, conf.KeyBy),
+		routes:     routes,
+		client:     client,
+		script:     redis.NewScript(limiterScriptSource),
+		jwtHandler: jwtHandler,
+		health:     health,
+		local:      newLocalLimiter(),
 	}
 }
 
 func (m *LimitMiddleware) Middleware() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		prefix := "limit" + strings.ReplaceAll(ctx.FullPath(), ":", "_")
-		if prefix == "limit:" {
-			// 未注册路由使用统一前缀,避免浪费 redis 资源
-			prefix = "limit_unregistered"
+		route := ctx.FullPath()
+		limit, ok := m.routes[route]
+		if !ok {
+			limit = m.defaults
 		}
-		prefix = prefix + "_"
+		prefix := m.keyPrefix(ctx, route, limit)
 
-		availableKey := prefix + "tokens"
-		latestKey := prefix + "ts"
-		now := time.Now().UnixMilli()
-		res, err := m.script.Run(
-			ctx.Request.Context(),
-			m.client,
-			[]string{availableKey, latestKey},
-			m.capacity,     // ARGV[1]
-			m.quantum,      // ARGV[2]
-			m.fillInterval, // ARGV[3] 每秒补充几次
-			now,            // ARGV[4] 毫秒
-			1,              // ARGV[5]
-		).Int()
+		allowed, remaining, retryMs, resetMs, err := m.take(ctx, prefix, limit)
 		if err != nil {
 			ctx.Error(fmt.Errorf("限流器执行错误: %v", err))
 			ctx.JSON(http.StatusInternalServerError, response.Response{
@@ -66,7 +93,12 @@ func (m *LimitMiddleware) Middleware() gin.HandlerFunc {
 			})
 			return
 		}
-		if res == 0 {
+
+		ctx.Header("RateLimit-Limit", strconv.Itoa(limit.capacity))
+		ctx.Header("RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+		ctx.Header("RateLimit-Reset", strconv.FormatInt(ceilSeconds(resetMs), 10))
+		if !allowed {
+			ctx.Header("Retry-After", strconv.FormatInt(max(ceilSeconds(retryMs), 1), 10))
 			ctx.Error(errors.New("请求过于频繁，请稍后再试"))
 			ctx.JSON(http.StatusTooManyRequests, response.Response{
 				Code: http.StatusTooManyRequests,
@@ -78,3 +110,93 @@ func (m *LimitMiddleware) Middleware() gin.HandlerFunc {
 		ctx.Next()
 	}
 }
+
+// take 从令牌桶中取一个令牌，redis 不可用时改用进程内的令牌桶
+func (m *LimitMiddleware) take(ctx *gin.Context, prefix string, limit *routeLimit) (bool, int64, int64, int64, error) {
+	now := time.Now().UnixMilli()
+	if m.health.Healthy() {
+		res, err := m.script.Run(
+			ctx.Request.Context(),
+			m.client,
+			[]string{prefix + "tokens", prefix + "ts"},
+			limit.capacity,     // ARGV[1]
+			limit.quantum,      // ARGV[2]
+			limit.fillInterval, // ARGV[3] 每秒补充几次
+			now,                // ARGV[4] 毫秒
+			1,                  // ARGV[5]
+		).Int64Slice()
+		if err == nil {
+			if len(res) != 4 {
+				return false, 0, 0, 0, fmt.Errorf("限流脚本返回了 %d 个值", len(res))
+			}
+			return res[0] == 1, res[1], res[2], res[3], nil
+		}
+		if !redisUnavailable(err) {
+			// 脚本执行出错说明脚本或数据有问题，redis 本身可用，不切换到进程内的令牌桶
+			return false, 0, 0, 0, fmt.Errorf("限流脚本执行失败: %w", err)
+		}
+		m.health.Fail(err)
+	}
+	allowed, remaining, retryMs, resetMs := m.local.take(prefix, limit, now, 1)
+	return allowed, remaining, retryMs, resetMs, nil
+}
+
+// redisUnavailable 是否为连接 redis 失败的错误，redis 返回的错误（例如脚本报错）不算
+func redisUnavailable(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, redis.ErrClosed) ||
+		errors.Is(err, redis.ErrPoolTimeout) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
+
+// keyPrefix 按配置的维度拼接令牌桶的 key，例如 limit:/api/v1/garb/garb:user:9f86d081884c7d65:
+func (m *LimitMiddleware) keyPrefix(ctx *gin.Context, route string, limit *routeLimit) string {
+	var b strings.Builder
+	b.WriteString("limit:")
+	if limit.byRoute {
+		if route == "" {
+			// 未注册路由使用统一前缀,避免浪费 redis 资源
+			route = "unregistered"
+		}
+		b.WriteString(strings.ReplaceAll(route, ":", "_"))
+		b.WriteString(":")
+	}
+	if limit.byUser {
+		if userKey := m.userKey(ctx); userKey != "" {
+			b.WriteString("user:" + userKey + ":")
+		} else if !limit.byIP {
+			// 未登录的请求按 IP 区分
+			b.WriteString("ip:" + ctx.ClientIP() + ":")
+		}
+	}
+	if limit.byIP {
+		b.WriteString("ip:" + ctx.ClientIP() + ":")
+	}
+	return b.String()
+}
+
+// userKey 从访问令牌中解析用户，限流在认证之前执行，令牌无效时返回空字符串
+// 只校验签名不查询凭据库，避免未认证的请求也要访问 redis
+// 同一用户每次登录的会话ID都不同，所以按令牌中学号的 HMAC 区分，而不是会话ID
+func (m *LimitMiddleware) userKey(ctx *gin.Context) string {
+	token, ok := strings.CutPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+	if !ok {
+		return ""
+	}
+	uc, err := m.jwtHandler.ParseToken(token)
+	if err != nil {
+		return ""
+	}
+	return uc.UserKey
+}
+
+// ceilSeconds 毫秒向上取整为秒
+func ceilSeconds(ms int64) int64 {
+	if ms <= 0 {
+		return 0
+	}
+	return (ms + 999) / 1000
+}
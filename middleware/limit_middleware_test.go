@@ -0,0 +1,187 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Serendipity565/GrabSeat/config"
+	"github.com/Serendipity565/GrabSeat/internal/data"
+	"github.com/Serendipity565/GrabSeat/pkg/ijwt"
+	"github.com/Serendipity565/GrabSeat/pkg/logger"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+var testJWT = ijwt.NewJWT(&config.JWTConfig{JwtKey: "test", EncKey: "test", Timeout: 3600, AccessTimeout: 60})
+
+// newTestLimiter 使用 miniredis 的限流器，注册 /a 和 /b 两个路由
+func newTestLimiter(t *testing.T, conf *config.LimiterConfig) *gin.Engine {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	health := data.NewRedisHealth(rdb, &config.RedisConfig{Timeout: 100, CheckInterval: 1}, logger.NewZapLogger(zap.NewNop()))
+	return newLimiterEngine(NewLimitMiddleware(conf, rdb, testJWT, health))
+}
+
+func newLimiterEngine(lm *LimitMiddleware) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(lm.Middleware())
+	r.GET("/a", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/b", func(c *gin.Context) { c.Status(http.StatusOK) })
+	return r
+}
+
+// login 为用户签发一个新会话的访问令牌
+func login(t *testing.T, userID string) string {
+	token, err := testJWT.SetJWTToken(strconv.FormatInt(time.Now().UnixNano(), 36), userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func request(r *gin.Engine, path, token, ip string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	req.RemoteAddr = ip + ":12345"
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// assertLimited 检查请求被拒绝，并且带有 Retry-After
+func assertLimited(t *testing.T, w *httptest.ResponseRecorder) {
+	t.Helper()
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("期望 429，实际: %d", w.Code)
+	}
+	if retry, err := strconv.Atoi(w.Header().Get("Retry-After")); err != nil || retry < 1 {
+		t.Fatalf("期望 Retry-After 至少 1 秒，实际: %q", w.Header().Get("Retry-After"))
+	}
+}
+
+func TestLimitMiddleware_PerUser(t *testing.T) {
+	r := newTestLimiter(t, &config.LimiterConfig{Capacity: 2, FillInterval: 1, Quantum: 1, KeyBy: []string{"route", "user"}})
+	alice, bob := login(t, "2023000001"), login(t, "2023000002")
+
+	for i := 0; i < 2; i++ {
+		w := request(r, "/a", alice, "10.0.0.1")
+		if w.Code != http.StatusOK {
+			t.Fatalf("第 %d 次请求期望成功，实际: %d", i+1, w.Code)
+		}
+		if w.Header().Get("RateLimit-Limit") != "2" || w.Header().Get("RateLimit-Remaining") != strconv.Itoa(1-i) {
+			t.Fatalf("限流响应头错误: %v", w.Header())
+		}
+		if reset, _ := strconv.Atoi(w.Header().Get("RateLimit-Reset")); reset < 1 {
+			t.Fatalf("期望 RateLimit-Reset 至少 1 秒，实际: %q", w.Header().Get("RateLimit-Reset"))
+		}
+	}
+	assertLimited(t, request(r, "/a", alice, "10.0.0.1"))
+	// 重新登录得到新会话，仍然是同一个用户的令牌桶
+	assertLimited(t, request(r, "/a", login(t, "2023000001"), "10.0.0.2"))
+
+	// 其他用户和其他路由的令牌桶互不影响
+	if w := request(r, "/a", bob, "10.0.0.1"); w.Code != http.StatusOK {
+		t.Fatalf("期望其他用户不受影响，实际: %d", w.Code)
+	}
+	if w := request(r, "/b", alice, "10.0.0.1"); w.Code != http.StatusOK {
+		t.Fatalf("期望其他路由不受影响，实际: %d", w.Code)
+	}
+}
+
+func TestLimitMiddleware_KeyBy(t *testing.T) {
+	r := newTestLimiter(t, &config.LimiterConfig{
+		Capacity: 1, FillInterval: 1, Quantum: 1, KeyBy: []string{"user"},
+		Routes: []config.RouteLimiterConfig{{Path: "/b", Capacity: 1, FillInterval: 1, Quantum: 1, KeyBy: []string{"ip"}}},
+	})
+	alice, bob := login(t, "2023000001"), login(t, "2023000002")
+
+	// 只按用户区分时，换 IP 也是同一个令牌桶
+	if w := request(r, "/a", alice, "10.0.0.1"); w.Code != http.StatusOK {
+		t.Fatalf("期望成功，实际: %d", w.Code)
+	}
+	assertLimited(t, request(r, "/a", alice, "10.0.0.2"))
+
+	// 未登录或令牌无效的请求按 IP 区分
+	if w := request(r, "/a", "", "10.0.0.3"); w.Code != http.StatusOK {
+		t.Fatalf("期望成功，实际: %d", w.Code)
+	}
+	assertLimited(t, request(r, "/a", "invalid", "10.0.0.3"))
+	if w := request(r, "/a", "", "10.0.0.4"); w.Code != http.StatusOK {
+		t.Fatalf("期望其他 IP 不受影响，实际: %d", w.Code)
+	}
+
+	// /b 单独配置为按 IP 区分，同一 IP 的不同用户共用令牌桶
+	if w := request(r, "/b", bob, "10.0.0.5"); w.Code != http.StatusOK {
+		t.Fatalf("期望成功，实际: %d", w.Code)
+	}
+	assertLimited(t, request(r, "/b", login(t, "2023000003"), "10.0.0.5"))
+}
+
+func TestLimitMiddleware_RedisDown(t *testing.T) {
+	conf := &config.RedisConfig{Addr: "127.0.0.1:1", Timeout: 100, CheckInterval: 1}
+	rdb := redis.NewClient(&redis.Options{Addr: conf.Addr, DialTimeout: 100 * time.Millisecond, MaxRetries: -1})
+	health := data.NewRedisHealth(rdb, conf, logger.NewZapLogger(zap.NewNop()))
+	if health.Healthy() {
+		t.Fatal("期望 redis 不可用")
+	}
+	r := newLimiterEngine(NewLimitMiddleware(&config.LimiterConfig{Capacity: 1, FillInterval: 1, Quantum: 1, KeyBy: []string{"route", "user"}}, rdb, testJWT, health))
+	alice := login(t, "2023000001")
+
+	// redis 不可用时改用进程内的令牌桶，仍然限流
+	if w := request(r, "/a", alice, "10.0.0.1"); w.Code != http.StatusOK {
+		t.Fatalf("期望成功，实际: %d", w.Code)
+	}
+	assertLimited(t, request(r, "/a", alice, "10.0.0.1"))
+	if w := request(r, "/a", login(t, "2023000002"), "10.0.0.1"); w.Code != http.StatusOK {
+		t.Fatalf("期望其他用户不受影响，实际: %d", w.Code)
+	}
+}
+
+func TestLocalLimiter_Take(t *testing.T) {
+	l := newLocalLimiter()
+	limit := &routeLimit{capacity: 2, fillInterval: 2, quantum: 1} // 每 500ms 补充一个
+	now := time.Now().UnixMilli()
+
+	for i := 0; i < 2; i++ {
+		if ok, remaining, _, _ := l.take("k", limit, now, 1); !ok || remaining != int64(1-i) {
+			t.Fatalf("第 %d 次期望成功，实际: %v, %d", i+1, ok, remaining)
+		}
+	}
+	ok, _, retryMs, resetMs := l.take("k", limit, now+100, 1)
+	if ok || retryMs != 400 || resetMs != 900 {
+		t.Fatalf("期望 400ms 后重试、900ms 后补满，实际: %v, %d, %d", ok, retryMs, resetMs)
+	}
+	if ok, remaining, _, _ := l.take("k", limit, now+500, 1); !ok || remaining != 0 {
+		t.Fatalf("期望补充一个令牌后成功，实际: %v, %d", ok, remaining)
+	}
+	if ok, remaining, _, _ := l.take("k", limit, now+5000, 1); !ok || remaining != 1 {
+		t.Fatalf("期望补满后成功，实际: %v, %d", ok, remaining)
+	}
+}
+
+func TestLimitMiddleware_ScriptError(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	health := data.NewRedisHealth(rdb, &config.RedisConfig{Timeout: 100, CheckInterval: 1}, logger.NewZapLogger(zap.NewNop()))
+	r := newLimiterEngine(NewLimitMiddleware(&config.LimiterConfig{Capacity: 1, FillInterval: 1, Quantum: 1, KeyBy: []string{"route", "ip"}}, rdb, testJWT, health))
+
+	// 令牌桶的 key 类型不对，脚本执行出错
+	mr.HSet("limit:/a:ip:10.0.0.1:tokens", "f", "v")
+	if w := request(r, "/a", "", "10.0.0.1"); w.Code != http.StatusInternalServerError {
+		t.Fatalf("期望 500，实际: %d", w.Code)
+	}
+	if !health.Healthy() {
+		t.Fatal("期望脚本出错时不把 redis 标记为不可用")
+	}
+	if w := request(r, "/a", "", "10.0.0.2"); w.Code != http.StatusOK {
+		t.Fatalf("期望其他令牌桶仍然使用 redis，实际: %d", w.Code)
+	}
+}
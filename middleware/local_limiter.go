@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const localBucketTTL = time.Hour // 与 lua 脚本中 key 的过期时间一致
+
+// localBucket 进程内的令牌桶
+type localBucket struct {
+	available int
+	latest    int64 // 上一次补充令牌的时间(毫秒)
+	touched   time.Time
+}
+
+// localLimiter redis 不可用时使用的进程内令牌桶，算法与 scripts/limiter.lua 一致
+// 多实例部署时每个实例单独计数，降级期间的实际限额会放大
+type localLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*localBucket
+	pruned  time.Time
+}
+
+func newLocalLimiter() *localLimiter {
+	return &localLimiter{buckets: make(map[string]*localBucket), pruned: time.Now()}
+}
+
+// take 取 count 个令牌，返回值与 lua 脚本相同：是否成功、剩余令牌、重试等待毫秒、补满等待毫秒
+func (l *localLimiter) take(key string, limit *routeLimit, now int64, count int) (bool, int64, int64, int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.prune()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &localBucket{available: limit.capacity, latest: now}
+		l.buckets[key] = b
+	}
+	b.touched = time.Now()
+	if b.latest > now {
+		b.latest = now
+	}
+
+	intervalMs := 1000.0 / float64(limit.fillInterval)
+	elapsed := float64(now - b.latest)
+	if fills := math.Floor(elapsed / intervalMs); fills > 0 {
+		b.available = min(limit.capacity, b.available+int(fills)*limit.quantum)
+		b.latest += int64(fills * intervalMs)
+	}
+
+	allowed := b.available >= count
+	if allowed {
+		b.available -= count
+	}
+
+	sinceFill := float64(now - b.latest)
+	var resetMs, retryMs float64
+	if b.available < limit.capacity {
+		resetMs = math.Ceil(float64(limit.capacity-b.available)/float64(limit.quantum))*intervalMs - sinceFill
+	}
+	if !allowed {
+		retryMs = math.Ceil(float64(count-b.available)/float64(limit.quantum))*intervalMs - sinceFill
+	}
+	return allowed, int64(b.available), int64(math.Ceil(retryMs)), int64(math.Ceil(resetMs))
+}
+
+// prune 清理长时间没有使用的令牌桶
+func (l *localLimiter) prune() {
+	if time.Since(l.pruned) < time.Minute {
+		return
+	}
+	for key, b := range l.buckets {
+		if time.Since(b.touched) > localBucketTTL {
+			delete(l.buckets, key)
+		}
+	}
+	l.pruned = time.Now()
+}
@@ -7,6 +7,8 @@
 -- ARGV[4] = now (milliseconds)
 -- ARGV[5] = count (取多少 token)
 
+-- 返回 {allowed, remaining, retry_after_ms, reset_ms}
+
 local availableKey = KEYS[1]
 local latestKey    = KEYS[2]
 
@@ -54,17 +56,32 @@ if elapsed >= interval_ms then
 end
 
 -- ==== Step 3: 尝试取 token ====
+local allowed = 0
 if available >= count then
     available = available - count
-    redis.call("SET", availableKey, available)
-    redis.call("EXPIRE", availableKey, 3600)  -- 1 hour TTL
-    redis.call("SET", latestKey, math.floor(latestTime))
-    redis.call("EXPIRE", latestKey, 3600)
-    return 1   -- success
-else
-    redis.call("SET", availableKey, available)
-    redis.call("EXPIRE", availableKey, 3600)  -- 1 hour TTL
-    redis.call("SET", latestKey, math.floor(latestTime))
-    redis.call("EXPIRE", latestKey, 3600)
-    return 0   -- not enough tokens
+    allowed = 1
 end
+
+redis.call("SET", availableKey, available)
+redis.call("EXPIRE", availableKey, 3600)  -- 1 hour TTL
+redis.call("SET", latestKey, math.floor(latestTime))
+redis.call("EXPIRE", latestKey, 3600)
+
+-- ==== Step 4: 计算等待时间 ====
+-- 距离上一次补充已经过去的时间，下一次补充在 interval_ms - sinceFill 之后
+local sinceFill = now - latestTime
+
+-- 令牌桶补满需要的毫秒数
+local resetMs = 0
+if available < capacity then
+    resetMs = math.ceil((capacity - available) / quantum) * interval_ms - sinceFill
+end
+
+-- 被拒绝时，攒够 count 个令牌需要的毫秒数
+local retryMs = 0
+if allowed == 0 then
+    retryMs = math.ceil((count - available) / quantum) * interval_ms - sinceFill
+end
+
+-- 返回 {是否成功, 剩余令牌, 重试等待毫秒, 补满等待毫秒}
+return {allowed, available, math.ceil(retryMs), math.ceil(resetMs)}
@@ -3,10 +3,13 @@ package ijwt
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"io"
 	"time"
 
@@ -15,64 +18,118 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// token 类型，访问令牌不能当作刷新令牌使用，反之亦然
+const (
+	TokenAccess  = "access"
+	TokenRefresh = "refresh"
+)
+
 type JWT struct {
-	signingMethod jwt.SigningMethod // JWT 签名方法
-	rcExpiration  time.Duration     // 刷新令牌的过期时间，防止缓存过大
-	jwtKey        []byte            // 用于签署 JWT 的密钥
-	encKey        []byte            // 用于加密敏感信息的密钥
+	keys         *keySet       // 用于签署和校验 JWT 的密钥集合
+	atExpiration time.Duration // 访问令牌的过期时间
+	rcExpiration time.Duration // 刷新令牌的过期时间，防止缓存过大
+	encKey       []byte        // 用于加密敏感信息的密钥
 }
 
 func NewJWT(conf *config.JWTConfig) *JWT {
+	keys, err := newKeySet(conf)
+	if err != nil {
+		panic(fmt.Sprintf("JWT 密钥配置无效: %v", err))
+	}
 	return &JWT{
-		signingMethod: jwt.SigningMethodHS256, //签名的加密方式
-		rcExpiration:  time.Duration(conf.Timeout) * time.Second,
-		jwtKey:        []byte(conf.JwtKey),
-		encKey:        []byte(conf.EncKey),
+		keys:         keys,
+		atExpiration: time.Duration(conf.AccessTimeout) * time.Second,
+		rcExpiration: time.Duration(conf.Timeout) * time.Second,
+		encKey:       []byte(conf.EncKey),
 	}
 }
 
+// UserClaims token 中只保存会话ID（jti），用户的凭据保存在服务端的凭据库中
 type UserClaims struct {
-	jwt.RegisteredClaims        // 内嵌标准的声明
-	UserId               string // 用户学号
-	Password             string //用户名称
+	jwt.RegisteredClaims        // 内嵌标准的声明，ID 为会话ID
+	TokenType            string `json:"typ"`           // 访问令牌或刷新令牌
+	RefreshID            string `json:"rid,omitempty"` // 刷新令牌的ID，每次刷新都会更换
+	UserKey              string `json:"uk,omitempty"`  // 学号的 HMAC，同一用户的所有会话相同，限流时用来区分用户
+	UserId               string `json:"-"`             // 用户学号，由会话ID解析得到，不写入 token
 }
 
-func (j *JWT) SetJWTToken(userid string, password string) (string, error) {
-	enPassword, err := j.encryptString(password)
-	if err != nil {
-		return "", err
-	}
-	uc := UserClaims{
+// AccessExpiration 访问令牌的有效期
+func (j *JWT) AccessExpiration() time.Duration {
+	return j.atExpiration
+}
+
+// SetJWTToken 签发访问令牌，token 中只写入学号的 HMAC
+func (j *JWT) SetJWTToken(sessionID, userID string) (string, error) {
+	return j.sign(UserClaims{
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        sessionID,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(j.atExpiration)),
+		},
+		TokenType: TokenAccess,
+		UserKey:   j.userKey(userID),
+	})
+}
+
+// userKey 用 encKey 计算学号的 HMAC，不知道 encKey 无法由 token 反推学号
+func (j *JWT) userKey(userID string) string {
+	mac := hmac.New(sha256.New, deriveKey(j.encKey))
+	mac.Write([]byte(userID))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// SetRefreshToken 签发刷新令牌，refreshID 用于识别被重复使用的旧令牌
+func (j *JWT) SetRefreshToken(sessionID, refreshID string) (string, error) {
+	return j.sign(UserClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        sessionID,
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(j.rcExpiration)),
 		},
-		UserId:   userid,
-		Password: enPassword,
-	}
+		TokenType: TokenRefresh,
+		RefreshID: refreshID,
+	})
+}
 
-	token := jwt.NewWithClaims(j.signingMethod, uc)
-	// 使用指定的secret签名并获得完整的编码后的字符串token
-	tokenStr, err := token.SignedString(j.jwtKey)
+// sign 使用最新的密钥签名，kid 头中写入密钥ID
+func (j *JWT) sign(uc UserClaims) (string, error) {
+	key, err := j.keys.signing(time.Now())
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(key.method, uc)
+	token.Header["kid"] = key.kid
+	// 使用指定的密钥签名并获得完整的编码后的字符串token
+	tokenStr, err := token.SignedString(key.signKey)
 	if err != nil {
 		return "", err
 	}
 	return tokenStr, nil
 }
 
-// ParseToken 从请求中提取并返回解析完成的结构体
+// ParseToken 从请求中提取并返回解析完成的访问令牌
 func (j *JWT) ParseToken(tokenStr string) (UserClaims, error) {
-	if j.jwtKey == nil {
-		return UserClaims{}, errors.New("jwtKey 为空，无法解析 token")
-	}
+	return j.parse(tokenStr, TokenAccess)
+}
+
+// ParseRefreshToken 解析刷新令牌
+func (j *JWT) ParseRefreshToken(tokenStr string) (UserClaims, error) {
+	return j.parse(tokenStr, TokenRefresh)
+}
 
+func (j *JWT) parse(tokenStr, tokenType string) (UserClaims, error) {
 	//解析token
 	uc := UserClaims{}
 	token, err := jwt.ParseWithClaims(tokenStr, &uc, func(token *jwt.Token) (interface{}, error) {
-		// 校验签名算法
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		// 按 kid 选择密钥，密钥已过宽限期时拒绝
+		kid, _ := token.Header["kid"].(string)
+		key, err := j.keys.verifying(kid, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		// 校验签名算法，防止用公钥当作 HMAC 密钥伪造 token
+		if token.Method.Alg() != key.method.Alg() {
 			return nil, errors.New("签名检验算法错误")
 		}
-		return j.jwtKey, nil
+		return key.verifyKey, nil
 	})
 	if err != nil {
 		return UserClaims{}, err
@@ -85,15 +142,12 @@ func (j *JWT) ParseToken(tokenStr string) (UserClaims, error) {
 
 	// 确保 Claims 解析成功
 	claims, ok := token.Claims.(*UserClaims)
-	if !ok {
+	if !ok || claims.ID == "" {
 		return UserClaims{}, errors.New("无法解析 token claims")
 	}
-
-	realPassword, err := j.decryptString(claims.Password)
-	if err != nil {
-		return UserClaims{}, err
+	if claims.TokenType != tokenType {
+		return UserClaims{}, errors.New("token 类型错误")
 	}
-	claims.Password = realPassword
 
 	return *claims, nil
 }
@@ -151,10 +205,12 @@ func (j *JWT) decryptString(b64 string) (string, error) {
 	return string(pt), nil
 }
 
-// DecryptPasswordFromClaims 对外：根据 UserClaims 解密出明文 password（供后续业务使用，先留一个钩子）
-func (j *JWT) DecryptPasswordFromClaims(uc *UserClaims) (string, error) {
-	if uc == nil || uc.Password == "" {
-		return "", nil
-	}
-	return j.decryptString(uc.Password)
+// EncryptPassword 对外：加密密码，供需要持久化密码的业务使用（例如凭据库）
+func (j *JWT) EncryptPassword(plain string) (string, error) {
+	return j.encryptString(plain)
+}
+
+// DecryptPassword 对外：解密 EncryptPassword 加密的密码
+func (j *JWT) DecryptPassword(enc string) (string, error) {
+	return j.decryptString(enc)
 }
@@ -0,0 +1,177 @@
+package ijwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Serendipity565/GrabSeat/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestJWT_KeyRotation(t *testing.T) {
+	conf := &config.JWTConfig{
+		Timeout:       3600,
+		AccessTimeout: 60,
+		KeyGrace:      3600,
+		Keys:          []config.JWTKeyConfig{{Kid: "old", Secret: "old-secret"}},
+	}
+	old := NewJWT(conf)
+	oldToken, err := old.SetJWTToken("s1", "2023000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 新密钥生效后使用新密钥签名，旧密钥在宽限期内仍然可以校验
+	conf.Keys = append(conf.Keys, config.JWTKeyConfig{Kid: "new", Secret: "new-secret", ActiveFrom: time.Now().Add(-time.Minute).Format(time.DateTime)})
+	rotated := NewJWT(conf)
+	newToken, err := rotated.SetJWTToken("s2", "2023000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kid := tokenKid(t, newToken); kid != "new" {
+		t.Fatalf("期望使用新密钥签名，实际: %s", kid)
+	}
+	for _, tok := range []string{oldToken, newToken} {
+		if _, err = rotated.ParseToken(tok); err != nil {
+			t.Fatalf("期望宽限期内的 token 有效，实际: %v", err)
+		}
+	}
+
+	// 超过宽限期后旧密钥签发的 token 失效
+	conf.KeyGrace = 1
+	expired := NewJWT(conf)
+	if _, err = expired.ParseToken(oldToken); err == nil {
+		t.Fatalf("期望旧密钥过期后 token 无效")
+	}
+	if _, err = expired.ParseToken(newToken); err != nil {
+		t.Fatalf("期望新密钥签发的 token 有效，实际: %v", err)
+	}
+}
+
+func TestKeySet_RotationWithoutActiveFrom(t *testing.T) {
+	// 多个签名密钥都没有 activeFrom 时无法确定哪个是新密钥
+	for _, keys := range [][]config.JWTKeyConfig{
+		{{Kid: "old", Secret: "old-secret"}, {Kid: "new", Secret: "new-secret"}},
+		{{Kid: "old", Secret: "old-secret", ActiveFrom: "2025-11-01 00:00:00"}, {Kid: "new", Secret: "new-secret", ActiveFrom: "2025-11-01 00:00:00"}},
+	} {
+		if _, err := newKeySet(&config.JWTConfig{Keys: keys}); err == nil {
+			t.Fatalf("期望签名密钥的 activeFrom 缺失或重复时返回错误: %+v", keys)
+		}
+	}
+
+	// 只用于校验的密钥不会替换旧密钥，宽限期从新的签名密钥生效时开始计算
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "ed25519.pub.pem")
+	if err = os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	ks, err := newKeySet(&config.JWTConfig{KeyGrace: 3600, Keys: []config.JWTKeyConfig{
+		{Kid: "old", Secret: "old-secret"},
+		{Kid: "external", Alg: "EdDSA", PublicKey: path, ActiveFrom: now.Add(-2 * time.Hour).Format(time.DateTime)},
+		{Kid: "new", Secret: "new-secret", ActiveFrom: now.Add(-time.Minute).Format(time.DateTime)},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k, err := ks.signing(now); err != nil || k.kid != "new" {
+		t.Fatalf("期望使用新密钥签名，实际: %+v, %v", k, err)
+	}
+	for _, kid := range []string{"old", "external", "new"} {
+		if _, err = ks.verifying(kid, now); err != nil {
+			t.Fatalf("期望密钥 %s 在宽限期内有效，实际: %v", kid, err)
+		}
+	}
+}
+
+func TestJWT_EdDSA(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "ed25519.pem")
+	if err = os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	j := NewJWT(&config.JWTConfig{
+		Timeout:       3600,
+		AccessTimeout: 60,
+		KeyGrace:      3600,
+		Keys:          []config.JWTKeyConfig{{Kid: "ed", Alg: "EdDSA", PrivateKey: path}},
+	})
+	token, err := j.SetJWTToken("s1", "2023000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uc, err := j.ParseToken(token); err != nil || uc.ID != "s1" {
+		t.Fatalf("期望 token 有效，实际: %+v, %v", uc, err)
+	}
+
+	// 只有公钥的服务也能校验
+	jwks := j.JWKS()["keys"]
+	if len(jwks) != 1 || jwks[0].Kid != "ed" || jwks[0].Crv != "Ed25519" {
+		t.Fatalf("期望公开 Ed25519 公钥，实际: %+v", jwks)
+	}
+	if _, err = jwt.Parse(token, func(*jwt.Token) (interface{}, error) {
+		return priv.Public(), nil
+	}); err != nil {
+		t.Fatalf("期望使用公钥校验成功，实际: %v", err)
+	}
+}
+
+func tokenKid(t *testing.T, token string) string {
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &UserClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid, _ := parsed.Header["kid"].(string)
+	return kid
+}
+
+func TestJWT_UserKey(t *testing.T) {
+	j := NewJWT(&config.JWTConfig{JwtKey: "test", EncKey: "test", Timeout: 3600, AccessTimeout: 60})
+	keyOf := func(sessionID, userID string) string {
+		token, err := j.SetJWTToken(sessionID, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		claims := jwt.MapClaims{}
+		if _, _, err = jwt.NewParser().ParseUnverified(token, claims); err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range claims {
+			if v == userID {
+				t.Fatalf("期望 token 中不出现学号，实际 %s: %v", k, v)
+			}
+		}
+		uc, err := j.ParseToken(token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return uc.UserKey
+	}
+
+	// 同一用户的不同会话共用一个 key，不同用户的 key 不同
+	a1, a2, b := keyOf("s1", "2023000001"), keyOf("s2", "2023000001"), keyOf("s3", "2023000002")
+	if a1 == "" || a1 != a2 || a1 == b {
+		t.Fatalf("期望按用户区分，实际: %q %q %q", a1, a2, b)
+	}
+}
@@ -0,0 +1,244 @@
+package ijwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/Serendipity565/GrabSeat/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// defaultKid 只配置了 jwtKey 时使用的密钥ID，没有 kid 头的旧 token 也按这个密钥校验
+const defaultKid = "default"
+
+// signingKey 密钥集合中的一个密钥
+type signingKey struct {
+	kid        string
+	method     jwt.SigningMethod
+	signKey    interface{} // []byte、ed25519.PrivateKey 或 *rsa.PrivateKey，为 nil 时只用于校验
+	verifyKey  interface{} // []byte、ed25519.PublicKey 或 *rsa.PublicKey
+	activeFrom time.Time   // 开始用于签名的时间
+}
+
+// keySet 按 activeFrom 排序的密钥集合
+// 签名使用已经生效的最新密钥；旧密钥被新密钥替换后，在 grace 时间内仍然可以用于校验
+type keySet struct {
+	keys  []*signingKey
+	grace time.Duration
+}
+
+func newKeySet(conf *config.JWTConfig) (*keySet, error) {
+	ks := &keySet{grace: time.Duration(conf.KeyGrace) * time.Second}
+	if len(conf.Keys) == 0 {
+		// 兼容只配置了 jwtKey 的情况
+		ks.keys = []*signingKey{{
+			kid:       defaultKid,
+			method:    jwt.SigningMethodHS256,
+			signKey:   []byte(conf.JwtKey),
+			verifyKey: []byte(conf.JwtKey),
+		}}
+		return ks, nil
+	}
+
+	seen := make(map[string]bool, len(conf.Keys))
+	for _, kc := range conf.Keys {
+		if kc.Kid == "" || seen[kc.Kid] {
+			return nil, fmt.Errorf("密钥ID %q 为空或重复", kc.Kid)
+		}
+		seen[kc.Kid] = true
+		key, err := loadKey(kc)
+		if err != nil {
+			return nil, fmt.Errorf("加载密钥 %s 失败: %w", kc.Kid, err)
+		}
+		ks.keys = append(ks.keys, key)
+	}
+	sort.SliceStable(ks.keys, func(i, j int) bool {
+		return ks.keys[i].activeFrom.Before(ks.keys[j].activeFrom)
+	})
+
+	// 旧密钥的宽限期从替换它的签名密钥生效时开始计算，所以签名密钥的生效时间需要各不相同，
+	// 只有最早的一个签名密钥可以不配置 activeFrom
+	var prev *signingKey
+	for _, k := range ks.keys {
+		if k.signKey == nil {
+			continue
+		}
+		if prev != nil && !prev.activeFrom.Before(k.activeFrom) {
+			if k.activeFrom.IsZero() {
+				return nil, fmt.Errorf("配置了多个签名密钥时需要为密钥 %s 配置 activeFrom", k.kid)
+			}
+			return nil, fmt.Errorf("签名密钥 %s 和 %s 的 activeFrom 相同", prev.kid, k.kid)
+		}
+		prev = k
+	}
+	return ks, nil
+}
+
+func loadKey(kc config.JWTKeyConfig) (*signingKey, error) {
+	key := &signingKey{kid: kc.Kid}
+	if kc.ActiveFrom != "" {
+		t, err := time.ParseInLocation(time.DateTime, kc.ActiveFrom, time.Local)
+		if err != nil {
+			return nil, err
+		}
+		key.activeFrom = t
+	}
+
+	switch kc.Alg {
+	case "", "HS256":
+		if kc.Secret == "" {
+			return nil, errors.New("HS256 需要配置 secret")
+		}
+		key.method = jwt.SigningMethodHS256
+		key.signKey = []byte(kc.Secret)
+		key.verifyKey = []byte(kc.Secret)
+	case "EdDSA":
+		key.method = jwt.SigningMethodEdDSA
+		if kc.PrivateKey != "" {
+			pem, err := os.ReadFile(kc.PrivateKey)
+			if err != nil {
+				return nil, err
+			}
+			priv, err := jwt.ParseEdPrivateKeyFromPEM(pem)
+			if err != nil {
+				return nil, err
+			}
+			key.signKey = priv
+			key.verifyKey = priv.(crypto.Signer).Public()
+		}
+		if kc.PublicKey != "" {
+			pem, err := os.ReadFile(kc.PublicKey)
+			if err != nil {
+				return nil, err
+			}
+			if key.verifyKey, err = jwt.ParseEdPublicKeyFromPEM(pem); err != nil {
+				return nil, err
+			}
+		}
+	case "RS256":
+		key.method = jwt.SigningMethodRS256
+		if kc.PrivateKey != "" {
+			pem, err := os.ReadFile(kc.PrivateKey)
+			if err != nil {
+				return nil, err
+			}
+			priv, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+			if err != nil {
+				return nil, err
+			}
+			key.signKey = priv
+			key.verifyKey = &priv.PublicKey
+		}
+		if kc.PublicKey != "" {
+			pem, err := os.ReadFile(kc.PublicKey)
+			if err != nil {
+				return nil, err
+			}
+			if key.verifyKey, err = jwt.ParseRSAPublicKeyFromPEM(pem); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("不支持的签名算法 %s", kc.Alg)
+	}
+	if key.verifyKey == nil {
+		return nil, errors.New("需要配置 privateKey 或 publicKey")
+	}
+	return key, nil
+}
+
+// signing 返回 now 时刻用于签名的密钥
+func (ks *keySet) signing(now time.Time) (*signingKey, error) {
+	for i := len(ks.keys) - 1; i >= 0; i-- {
+		k := ks.keys[i]
+		if k.signKey != nil && !k.activeFrom.After(now) {
+			return k, nil
+		}
+	}
+	return nil, errors.New("没有可用于签名的密钥")
+}
+
+// verifying 返回 kid 对应的校验密钥，被替换超过 grace 的密钥不再接受
+func (ks *keySet) verifying(kid string, now time.Time) (*signingKey, error) {
+	if kid == "" {
+		kid = defaultKid
+	}
+	current, err := ks.signing(now)
+	if err != nil {
+		current = nil
+	}
+	for i, k := range ks.keys {
+		if k.kid != kid {
+			continue
+		}
+		if k == current || current == nil || i > indexOf(ks.keys, current) {
+			// 当前密钥，或者提前发布、尚未用于签名的密钥
+			return k, nil
+		}
+		// 旧密钥从替换它的签名密钥生效时开始计算宽限期，只用于校验的密钥不会替换其他密钥
+		if now.Before(ks.replacedAt(i).Add(ks.grace)) {
+			return k, nil
+		}
+		return nil, fmt.Errorf("密钥 %s 已过期", kid)
+	}
+	return nil, fmt.Errorf("未知的密钥 %s", kid)
+}
+
+// replacedAt 第 i 个密钥之后第一个签名密钥的生效时间，调用方保证 i 在当前签名密钥之前
+func (ks *keySet) replacedAt(i int) time.Time {
+	for _, k := range ks.keys[i+1:] {
+		if k.signKey != nil {
+			return k.activeFrom
+		}
+	}
+	return time.Time{}
+}
+
+func indexOf(keys []*signingKey, key *signingKey) int {
+	for i, k := range keys {
+		if k == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// JWK 公钥的 JSON Web Key 表示，供其他服务校验 token
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv,omitempty"` // OKP
+	X   string `json:"x,omitempty"`   // OKP
+	N   string `json:"n,omitempty"`   // RSA
+	E   string `json:"e,omitempty"`   // RSA
+}
+
+// JWKS 返回所有仍然有效的非对称密钥的公钥，对称密钥不会公开
+func (j *JWT) JWKS() map[string][]JWK {
+	now := time.Now()
+	keys := make([]JWK, 0, len(j.keys.keys))
+	for _, k := range j.keys.keys {
+		if _, err := j.keys.verifying(k.kid, now); err != nil {
+			continue
+		}
+		enc := base64.RawURLEncoding
+		switch pub := k.verifyKey.(type) {
+		case ed25519.PublicKey:
+			keys = append(keys, JWK{Kty: "OKP", Kid: k.kid, Alg: k.method.Alg(), Use: "sig", Crv: "Ed25519", X: enc.EncodeToString(pub)})
+		case *rsa.PublicKey:
+			keys = append(keys, JWK{Kty: "RSA", Kid: k.kid, Alg: k.method.Alg(), Use: "sig",
+				N: enc.EncodeToString(pub.N.Bytes()), E: enc.EncodeToString(big.NewInt(int64(pub.E)).Bytes())})
+		}
+	}
+	return map[string][]JWK{"keys": keys}
+}
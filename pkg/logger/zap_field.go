@@ -54,4 +54,5 @@ var (
 	Object      = zap.Object
 	Inline      = zap.Inline
 	Any         = zap.Any
+	Error       = zap.Error
 )
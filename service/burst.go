@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Serendipity565/GrabSeat/api/response"
+	"github.com/Serendipity565/GrabSeat/errs"
+	"github.com/Serendipity565/GrabSeat/pkg/logger"
+	"github.com/Serendipity565/GrabSeat/service/crawler"
+)
+
+// clockSync 服务器时钟校准结果
+type clockSync struct {
+	offset      time.Duration // 服务器时钟 - 本地时钟
+	uncertainty time.Duration // 偏差的误差范围（±）
+}
+
+// syncClock 根据响应头中的 Date 估计服务器与本地的时钟偏差
+//
+// Date 只精确到秒：服务器在本地的 [sent, recv] 之间生成响应，此时服务器时钟位于 [Date, Date+1s)，
+// 所以每次采样都能得到偏差的一个区间 (Date-recv, Date+1s-sent)。
+// 采样间隔取 1s + 1s/n，让各次采样落在一秒内不同的相位上，区间求交后误差可以缩小到 1s/n 左右。
+func (g *grabberService) syncClock(ctx context.Context, client *http.Client) (*clockSync, error) {
+	n := g.burst.SyncSamples
+	step := time.Second + time.Second/time.Duration(n)
+
+	var (
+		lo, hi  time.Duration
+		lastLo  time.Duration
+		lastHi  time.Duration
+		samples int
+		lastErr error
+	)
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			if err := crawler.Sleep(ctx, step); err != nil {
+				return nil, err
+			}
+		}
+		sent := time.Now()
+		date, err := g.backend.ServerTime(ctx, client)
+		recv := time.Now()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		lastLo, lastHi = date.Sub(recv), date.Add(time.Second).Sub(sent)
+		if samples == 0 {
+			lo, hi = lastLo, lastHi
+		} else {
+			lo, hi = max(lo, lastLo), min(hi, lastHi)
+		}
+		samples++
+	}
+	if samples == 0 {
+		return nil, lastErr
+	}
+	if lo > hi {
+		// 采样期间某一端的时钟发生了跳变，只相信最后一次采样
+		lo, hi = lastLo, lastHi
+	}
+	return &clockSync{
+		offset:      (lo + hi) / 2,
+		uncertainty: (hi - lo) / 2,
+	}, nil
+}
+
+// BurstGrab 在放座时刻附近连续发起预约请求，直到有一次成功
+// releaseAt 为服务器时间下的放座时刻，预约的是放座时刻第二天的座位
+func (g *grabberService) BurstGrab(ctx context.Context, client *http.Client, seatID, startTime, endTime string, releaseAt time.Time) (*response.BurstResult, error) {
+	date := releaseAt.AddDate(0, 0, 1)
+	if err := g.topo.CheckDate(date); err != nil {
+		return nil, err
+	}
+	wait := time.Until(releaseAt)
+	if wait > time.Duration(g.burst.MaxWait)*time.Second {
+		return nil, errs.BurstWindowError(fmt.Errorf("距离放座时刻还有 %s", wait.Round(time.Second)))
+	}
+	if wait < -time.Minute {
+		return nil, errs.BurstWindowError(errors.New("放座时刻已过，请直接预约"))
+	}
+
+	cs, err := g.syncClock(ctx, client)
+	if err != nil {
+		return nil, crawlerError(err)
+	}
+	// 服务器到达 releaseAt 时对应的本地时间
+	localRelease := releaseAt.Add(-cs.offset)
+
+	// 放座前再访问一次，保证连接处于活跃状态，避免第一次请求时重新建立连接
+	if warm := localRelease.Add(-2 * time.Second); time.Until(warm) > 0 {
+		if err = crawler.Sleep(ctx, time.Until(warm)); err != nil {
+			return nil, crawlerError(err)
+		}
+		if _, err = g.backend.ServerTime(ctx, client); err != nil {
+			g.log.Warn("抢座预热失败", logger.Error(err))
+		}
+	}
+
+	res := &response.BurstResult{
+		SeatID:             seatID,
+		ReleaseAt:          releaseAt.Format(time.DateTime),
+		ClockOffsetMs:      cs.offset.Milliseconds(),
+		ClockUncertaintyMs: cs.uncertainty.Milliseconds(),
+		Attempts:           make([]response.BurstAttempt, 0, g.burst.Attempts),
+	}
+
+	// 有一次请求成功后取消其他还在进行中的请求
+	attemptCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		once sync.Once
+		done = make(chan struct{})
+	)
+	interval := time.Duration(g.burst.IntervalMs) * time.Millisecond
+	lead := time.Duration(g.burst.LeadMs) * time.Millisecond
+
+fire:
+	for i := 0; i < g.burst.Attempts; i++ {
+		planned := -lead + time.Duration(i)*interval
+		select {
+		case <-done:
+			// 已经有请求成功，不再发出后续请求
+			break fire
+		case <-ctx.Done():
+			break fire
+		case <-time.After(time.Until(localRelease.Add(planned))):
+		}
+
+		wg.Add(1)
+		go func(i int, planned time.Duration) {
+			defer wg.Done()
+			ctx, cancel := withDeadline(attemptCtx, g.deadline.Grab)
+			defer cancel()
+			sent := time.Now()
+			ar, err := g.backend.Reserve(ctx, client, seatID, date, startTime, endTime)
+			at := response.BurstAttempt{
+				Index:     i,
+				PlannedMs: planned.Milliseconds(),
+				SentMs:    sent.Add(cs.offset).Sub(releaseAt).Milliseconds(),
+				LatencyMs: time.Since(sent).Milliseconds(),
+			}
+			if err != nil {
+				at.Msg = err.Error()
+			} else {
+				at.Msg = ar.Msg
+				at.Success = strings.Contains(ar.Msg, "操作成功")
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			res.Attempts = append(res.Attempts, at)
+			if at.Success {
+				res.Success = true
+				once.Do(func() {
+					close(done)
+					stop()
+				})
+			}
+		}(i, planned)
+	}
+	wg.Wait()
+	if len(res.Attempts) == 0 {
+		// 放座前就被取消了，一次请求都没有发出
+		return nil, crawlerError(ctx.Err())
+	}
+
+	sort.Slice(res.Attempts, func(i, j int) bool {
+		return res.Attempts[i].Index < res.Attempts[j].Index
+	})
+	if res.Success {
+		g.snapshots.Invalidate(date)
+		// 上游返回成功不代表一定预约到了，以个人预约记录为准
+		if _, err = g.GrabSuccess(ctx, client, seatID, startTime, endTime, date); err != nil {
+			g.log.Warn("放座抢座返回成功，但没有查询到预约记录",
+				logger.String("seat", seatID),
+				logger.Int("attempts", len(res.Attempts)),
+				logger.Error(err),
+			)
+			return nil, err
+		}
+	}
+	g.log.Info("放座抢座结束",
+		logger.String("seat", seatID),
+		logger.Bool("success", res.Success),
+		logger.Int("attempts", len(res.Attempts)),
+		logger.Int64("clock_offset_ms", res.ClockOffsetMs),
+		logger.Int64("clock_uncertainty_ms", res.ClockUncertaintyMs),
+	)
+	return res, nil
+}
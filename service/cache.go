@@ -1,8 +1,35 @@
 package service
 
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Serendipity565/GrabSeat/api/response"
+)
+
 // Cache 缓存管理结构体
+// 保存座位名称 -> 设备ID -> 区域的索引，由 device.aspx 的查询结果建立，每个区域单独更新
 type Cache struct {
-	deviceCache map[string]string
+	mu          sync.RWMutex
+	deviceCache map[string]response.SeatDevice // 座位名称 -> 座位信息
+	idCache     map[string]string              // 设备ID -> 座位名称
+	rooms       map[string]*roomIndex          // 区域ID -> 区域中的座位
+}
+
+// roomIndex 一个区域在索引中的座位和最后一次更新的时间
+type roomIndex struct {
+	titles    []string
+	updatedAt time.Time
+}
+
+func NewCache() *Cache {
+	return &Cache{
+		deviceCache: make(map[string]response.SeatDevice),
+		idCache:     make(map[string]string),
+		rooms:       make(map[string]*roomIndex),
+	}
 }
 
 // Device 设备信息结构体
@@ -19,8 +46,70 @@ type ApiResponse struct {
 	} `json:"data"`
 }
 
-// GetDevid 设置座位名称与ID对应的缓存
-// TODO 暂时用不到，之后在实现具体功能
-func GetDevid() {
+// Update 用某个区域的座位状态替换这个区域在索引中的座位，上游已经没有的座位会被移除
+func (c *Cache) Update(room string, seats []response.Seat) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if old, ok := c.rooms[room]; ok {
+		for _, title := range old.titles {
+			// 座位可能已经被其他区域的查询结果覆盖
+			if dev, ok := c.deviceCache[title]; ok && dev.Room == room {
+				delete(c.deviceCache, title)
+				delete(c.idCache, dev.DevId)
+			}
+		}
+	}
+	titles := make([]string, 0, len(seats))
+	for _, seat := range seats {
+		title := strings.ToUpper(seat.Title)
+		c.deviceCache[title] = response.SeatDevice{
+			Title: seat.Title,
+			DevId: seat.DevId,
+			Room:  room,
+		}
+		c.idCache[seat.DevId] = title
+		titles = append(titles, title)
+	}
+	c.rooms[room] = &roomIndex{titles: titles, updatedAt: time.Now()}
+}
+
+// GetDevid 按座位名称（不区分大小写）或设备ID查找座位
+func (c *Cache) GetDevid(seat string) (response.SeatDevice, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if dev, ok := c.deviceCache[strings.ToUpper(seat)]; ok {
+		return dev, true
+	}
+	if title, ok := c.idCache[seat]; ok {
+		return c.deviceCache[title], true
+	}
+	return response.SeatDevice{}, false
+}
+
+// Search 模糊查找座位名称，keyWord 为空则返回所有座位，结果按座位名称排序
+func (c *Cache) Search(keyWord string) []response.SeatDevice {
+	keyWord = strings.ToUpper(keyWord)
+	c.mu.RLock()
+	devices := make([]response.SeatDevice, 0, len(c.deviceCache))
+	for title, dev := range c.deviceCache {
+		if strings.Contains(title, keyWord) {
+			devices = append(devices, dev)
+		}
+	}
+	c.mu.RUnlock()
+
+	sort.Slice(devices, func(i, j int) bool {
+		return devices[i].Title < devices[j].Title
+	})
+	return devices
+}
 
+// UpdatedAt 区域在索引中最后一次更新的时间，没有更新过时返回零值
+func (c *Cache) UpdatedAt(room string) time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if idx, ok := c.rooms[room]; ok {
+		return idx.updatedAt
+	}
+	return time.Time{}
 }
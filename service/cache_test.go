@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/Serendipity565/GrabSeat/api/response"
+	"github.com/golang/mock/gomock"
+)
+
+func TestCache_UpdateRoom(t *testing.T) {
+	c := NewCache()
+	c.Update("1", []response.Seat{{Title: "N1224", DevId: "101"}, {Title: "N1225", DevId: "102"}})
+	c.Update("2", []response.Seat{{Title: "S2001", DevId: "201"}})
+
+	// 每个区域单独记录更新时间
+	if c.UpdatedAt("1").IsZero() || c.UpdatedAt("2").IsZero() || !c.UpdatedAt("3").IsZero() {
+		t.Fatalf("期望只有更新过的区域有更新时间")
+	}
+
+	// 重新查询后上游已经没有的座位从索引中移除，其他区域不受影响
+	c.Update("1", []response.Seat{{Title: "N1224", DevId: "101"}})
+	if _, ok := c.GetDevid("N1225"); ok {
+		t.Fatalf("期望 N1225 已被移除")
+	}
+	if _, ok := c.GetDevid("102"); ok {
+		t.Fatalf("期望设备ID 102 已被移除")
+	}
+	if dev, ok := c.GetDevid("201"); !ok || dev.Title != "S2001" {
+		t.Fatalf("期望 S2001 仍然在索引中，实际: %+v", dev)
+	}
+	if devs := c.Search(""); len(devs) != 2 {
+		t.Fatalf("期望索引中有 2 个座位，实际: %+v", devs)
+	}
+}
+
+func TestGrabberService_ResolveSeatID(t *testing.T) {
+	backend, gs := newTestGrabber(t)
+
+	// 索引中没有的座位ID直接使用，不会查询所有区域
+	dev, err := gs.ResolveSeat(context.Background(), &http.Client{}, "101699999")
+	if err != nil || dev.DevId != "101699999" {
+		t.Fatalf("期望座位ID原样返回，实际: %+v, %v", dev, err)
+	}
+
+	// cookie 池为空时不刷新索引，有会话时刷新所有区域，刷新后索引在 DeviceRefresh 内不会重复刷新
+	gs.RefreshDevices(context.Background())
+	gs.SeedSession("2023000001", &http.Client{})
+	backend.EXPECT().SearchRoomStatus(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), "8:00", "22:00").
+		Return([]response.Seat{{Title: "N1224", DevId: "101"}}, nil).Times(len(testRooms))
+	gs.RefreshDevices(context.Background())
+	gs.RefreshDevices(context.Background())
+	if dev, err = gs.ResolveSeat(context.Background(), &http.Client{}, "n1224"); err != nil || dev.DevId != "101" {
+		t.Fatalf("期望刷新后可以按名称解析座位，实际: %+v, %v", dev, err)
+	}
+}
@@ -0,0 +1,79 @@
+package crawler
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/Serendipity565/GrabSeat/config"
+)
+
+// ClientFactory 按 LibraryConfig 创建访问图书馆的 http.Client
+type ClientFactory struct {
+	timeout             time.Duration
+	proxy               func(*http.Request) (*url.URL, error)
+	tls                 *tls.Config
+	dialer              *net.Dialer
+	tlsHandshakeTimeout time.Duration
+	idleConnTimeout     time.Duration
+}
+
+// NewClientFactory 读取代理和 CA 证书的设置，conf 中的超时时间为 0 时表示不限制
+func NewClientFactory(conf *config.LibraryConfig) (*ClientFactory, error) {
+	f := &ClientFactory{
+		timeout: time.Duration(conf.Timeout) * time.Second,
+		proxy:   http.ProxyFromEnvironment,
+		dialer: &net.Dialer{
+			Timeout:   time.Duration(conf.DialTimeout) * time.Second,
+			KeepAlive: time.Duration(conf.KeepAlive) * time.Second,
+		},
+		tlsHandshakeTimeout: time.Duration(conf.TLSHandshakeTimeout) * time.Second,
+		idleConnTimeout:     time.Duration(conf.IdleConnTimeout) * time.Second,
+	}
+	if conf.Proxy != "" {
+		u, err := url.Parse(conf.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("解析代理地址失败: %w", err)
+		}
+		f.proxy = http.ProxyURL(u)
+	}
+	if conf.CAFile != "" {
+		pem, err := os.ReadFile(conf.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取 CA 证书失败: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("CA 证书中没有有效的 PEM 证书: " + conf.CAFile)
+		}
+		f.tls = &tls.Config{RootCAs: pool}
+	}
+	return f, nil
+}
+
+// New 创建一个新的 client，每个 client 有独立的 cookie 和连接池，关闭一个用户的空闲连接不影响其他用户
+func (f *ClientFactory) New() *http.Client {
+	jar, _ := cookiejar.New(nil) // cookiejar.New 在没有 options 时不会返回错误
+	return &http.Client{
+		Jar:     jar,
+		Timeout: f.timeout,
+		Transport: &http.Transport{
+			Proxy:               f.proxy,
+			DialContext:         f.dialer.DialContext,
+			TLSClientConfig:     f.tls,
+			TLSHandshakeTimeout: f.tlsHandshakeTimeout,
+			IdleConnTimeout:     f.idleConnTimeout,
+			ForceAttemptHTTP2:   true,
+		},
+	}
+}
@@ -0,0 +1,71 @@
+package crawler_test
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Serendipity565/GrabSeat/config"
+	"github.com/Serendipity565/GrabSeat/service/crawler"
+)
+
+func TestClientFactory_CAFile(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	// 没有配置 CA 证书时不信任自签名证书
+	clients, err := crawler.NewClientFactory(&config.LibraryConfig{Timeout: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = clients.New().Get(srv.URL); err == nil {
+		t.Fatalf("期望自签名证书校验失败")
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err = os.WriteFile(caFile, cert, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	clients, err = crawler.NewClientFactory(&config.LibraryConfig{Timeout: 5, CAFile: caFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := clients.New().Get(srv.URL)
+	if err != nil {
+		t.Fatalf("期望信任配置的 CA 证书，实际: %v", err)
+	}
+	resp.Body.Close()
+
+	if _, err = crawler.NewClientFactory(&config.LibraryConfig{CAFile: filepath.Join(t.TempDir(), "missing.pem")}); err == nil {
+		t.Fatalf("期望 CA 证书不存在时返回错误")
+	}
+}
+
+func TestClientFactory_Proxy(t *testing.T) {
+	var proxied atomic.Int32
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 经过代理的请求使用完整的 URL
+		if r.URL.Host == "kjyy.example" {
+			proxied.Add(1)
+		}
+	}))
+	defer proxy.Close()
+
+	clients, err := crawler.NewClientFactory(&config.LibraryConfig{Timeout: 5, Proxy: proxy.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := clients.New().Get("http://kjyy.example/ClientWeb/pro/ajax/device.aspx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if proxied.Load() != 1 {
+		t.Fatalf("期望请求经过代理")
+	}
+}
@@ -1,33 +1,144 @@
 package crawler
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
+	"github.com/Serendipity565/GrabSeat/api/response"
+	"github.com/Serendipity565/GrabSeat/config"
+	"github.com/google/wire"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
-var (
-	CASUrl          = "https://account.ccnu.edu.cn/cas/login"
-	LibraryLoginUrl = "https://account.ccnu.edu.cn/cas/login?service=http://kjyy.ccnu.edu.cn/loginall.aspx?page="
-	SearchUrl       = "http://kjyy.ccnu.edu.cn/ClientWeb/pro/ajax/device.aspx"
-	GrabUrl         = "http://kjyy.ccnu.edu.cn/ClientWeb/pro/ajax/reserve.aspx"
-	PersonUrl       = "http://kjyy.ccnu.edu.cn/ClientWeb/pro/ajax/center.aspx"
+var ProviderSet = wire.NewSet(NewKjyyBackend)
+
+// Endpoints 图书馆相关的上游地址
+type Endpoints struct {
+	CASUrl          string // 统一身份认证登录地址
+	LibraryLoginUrl string // 通过 CAS 登录空间预约系统的地址
+	SearchUrl       string // 座位状态查询 device.aspx
+	GrabUrl         string // 预约 reserve.aspx
+	PersonUrl       string // 个人中心 center.aspx
+	RoomUrl         string // 房间列表 room.aspx
+}
+
+// NewEndpoints 根据 CAS 和 kjyy 的根地址生成各个接口的地址，例如 https://account.ccnu.edu.cn 和 http://kjyy.ccnu.edu.cn
+func NewEndpoints(casURL, kjyyURL string) Endpoints {
+	return Endpoints{
+		CASUrl:          casURL + "/cas/login",
+		LibraryLoginUrl: casURL + "/cas/login?service=" + kjyyURL + "/loginall.aspx?page=",
+		SearchUrl:       kjyyURL + "/ClientWeb/pro/ajax/device.aspx",
+		GrabUrl:         kjyyURL + "/ClientWeb/pro/ajax/reserve.aspx",
+		PersonUrl:       kjyyURL + "/ClientWeb/pro/ajax/center.aspx",
+		RoomUrl:         kjyyURL + "/ClientWeb/pro/ajax/room.aspx",
+	}
+}
+
+// referer kjyy ajax 接口的 Referer，即预约系统的首页
+func (ep Endpoints) referer() string {
+	return originOf(ep.SearchUrl) + "/clientweb/xcus/ic2/Default.aspx"
+}
+
+// 可达性检查的目标
+const (
+	ProbeCAS  = "cas"  // 统一身份认证
+	ProbeKjyy = "kjyy" // 空间预约系统
 )
 
-func FetchCASUrl(client *http.Client, username, password string) ([]byte, error) {
-	resp, err := client.Get(CASUrl)
+// LoginResult CAS 登录后返回页面中的提示信息
+type LoginResult struct {
+	ErrMsg     string // div#msg.errors 中的错误提示
+	SuccessMsg string // #msg.success 中的成功提示
+}
+
+// ActResp kjyy ajax 接口的通用响应
+// 例如 {"ret":1,"act":"set_resv","msg":"操作成功！","data":null,"ext":null}
+type ActResp struct {
+	Ret int    `json:"ret"`
+	Act string `json:"act"`
+	Msg string `json:"msg"`
+}
+
+// Room kjyy 房间列表中的一个房间
+type Room struct {
+	ID     string `json:"roomId"`
+	Name   string `json:"roomName"`
+	Branch string `json:"labName"` // 所在分馆
+}
+
+// roomsResp room.aspx 的响应
+type roomsResp struct {
+	Ret  int    `json:"ret"`
+	Msg  string `json:"msg"`
+	Data []Room `json:"data"`
+}
+
+// LibraryBackend 图书馆预约系统的抽象，便于替换实现以及在测试中 mock
+//
+//go:generate mockgen -destination=../mocks/mock_library_backend.go -package=mocks github.com/Serendipity565/GrabSeat/service/crawler LibraryBackend
+type LibraryBackend interface {
+	// LoginCAS 登录统一身份认证
+	LoginCAS(ctx context.Context, client *http.Client, username, password string) (*LoginResult, error)
+	// LoginLibrary 通过 CAS 登录空间预约系统，成功后 client 的 cookie 中带有预约系统的 session
+	LoginLibrary(ctx context.Context, client *http.Client, username, password string) (*LoginResult, error)
+	// ExchangeTicket 用 client 中已有的 CAS 会话换取空间预约系统的 session，不需要再次提交密码
+	ExchangeTicket(ctx context.Context, client *http.Client) error
+	// SearchRoomStatus 查询某个房间某天的座位预约情况
+	SearchRoomStatus(ctx context.Context, client *http.Client, roomID string, date time.Time, openTime, closeTime string) ([]response.Seat, error)
+	// ListRooms 获取可以预约座位的房间列表
+	ListRooms(ctx context.Context, client *http.Client) ([]Room, error)
+	// Reserve 预约座位
+	Reserve(ctx context.Context, client *http.Client, seatID string, date time.Time, startTime, endTime string) (*ActResp, error)
+	// CancelReservation 取消预约，rsvID 为个人预约记录中的 rsvId
+	CancelReservation(ctx context.Context, client *http.Client, rsvID string) (*ActResp, error)
+	// History 获取个人预约记录
+	History(ctx context.Context, client *http.Client) (*ActResp, error)
+	// ServerTime 读取 kjyy 服务器响应头中的 Date，精度为秒
+	ServerTime(ctx context.Context, client *http.Client) (time.Time, error)
+	// Probe 检查上游是否可达，target 为 ProbeCAS 或 ProbeKjyy，上游返回 5xx 时也视为不可达
+	// Probe 不经过熔断器，熔断期间也会实际发出请求
+	Probe(ctx context.Context, client *http.Client, target string) error
+	// CircuitState 返回 target 所在域名的熔断器状态，target 为 ProbeCAS 或 ProbeKjyy
+	CircuitState(target string) string
+}
+
+type kjyyBackend struct {
+	ep Endpoints
+	ua string
+	up *upstream
+}
+
+func NewKjyyBackend(reg *prometheus.Registry, conf *config.UpstreamConfig, lib *config.LibraryConfig) LibraryBackend {
+	RegisterMetrics(reg)
+	ep := NewEndpoints(lib.CASUrl, lib.KjyyUrl)
+	return &kjyyBackend{ep: ep, ua: lib.UserAgent, up: newUpstream(ep, conf)}
+}
+
+// NewKjyyBackendWithEndpoints 使用指定的上游地址创建 kjyy 后端，主要用于测试
+// conf 为 nil 时不重试也不熔断
+func NewKjyyBackendWithEndpoints(ep Endpoints, conf *config.UpstreamConfig) LibraryBackend {
+	return &kjyyBackend{ep: ep, ua: "Mozilla/5.0", up: newUpstream(ep, conf)}
+}
+
+func (k *kjyyBackend) LoginCAS(ctx context.Context, client *http.Client, username, password string) (*LoginResult, error) {
+	req, _ := http.NewRequestWithContext(ctx, "GET", k.ep.CASUrl, nil)
+	resp, err := k.up.send(client, endpointCAS, req, false)
 	if err != nil {
-		return nil, errors.New("获取CAS登录页面失败: " + err.Error())
+		return nil, fmt.Errorf("获取CAS登录页面失败: %w", err)
 	}
 	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, errors.New("读取CAS登录页面失败: " + err.Error())
+		return nil, fmt.Errorf("读取CAS登录页面失败: %w", err)
 	}
 
 	doc, _ := goquery.NewDocumentFromReader(strings.NewReader(string(body)))
@@ -43,38 +154,41 @@ func FetchCASUrl(client *http.Client, username, password string) ([]byte, error)
 	data.Set("_eventId", "submit")
 	data.Set("submit", "登录")
 
-	req, _ := http.NewRequest("POST", CASUrl, strings.NewReader(data.Encode()))
+	req, _ = http.NewRequestWithContext(ctx, "POST", k.ep.CASUrl, strings.NewReader(data.Encode()))
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Set("User-Agent", "Mozilla/5.0")
-	req.Header.Set("Origin", "https://account.ccnu.edu.cn")
-	req.Header.Set("Referer", CASUrl)
+	req.Header.Set("User-Agent", k.ua)
+	req.Header.Set("Origin", originOf(k.ep.CASUrl))
+	req.Header.Set("Referer", k.ep.CASUrl)
 
 	// 提交登录
-	resp, err = client.Do(req)
+	resp, err = k.up.send(client, endpointCAS, req, false)
 	if err != nil {
-		return nil, errors.New("CAS登录请求失败: " + err.Error())
+		return nil, fmt.Errorf("CAS登录请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, errors.New("读取CAS登录响应失败: " + err.Error())
+		return nil, fmt.Errorf("读取CAS登录响应失败: %w", err)
 	}
-	return bodyBytes, nil
+	return parseLoginResult(bodyBytes)
 }
 
-func FetchLibraryLoginUrl(client *http.Client, username, password string) ([]byte, error) {
-	resp, err := client.Get(LibraryLoginUrl)
+func (k *kjyyBackend) LoginLibrary(ctx context.Context, client *http.Client, username, password string) (*LoginResult, error) {
+	req, _ := http.NewRequestWithContext(ctx, "GET", k.ep.LibraryLoginUrl, nil)
+	resp, err := k.up.send(client, endpointCAS, req, false)
 	if err != nil {
-		return nil, errors.New("获取图书馆登录页面失败: " + err.Error())
+		return nil, fmt.Errorf("获取图书馆登录页面失败: %w", err)
 	}
 	defer resp.Body.Close()
 
 	doc, err := goquery.NewDocumentFromReader(resp.Body)
 	if err != nil {
+		parseFailures.WithLabelValues(endpointCAS).Inc()
 		return nil, err
 	}
 	lt, ok := doc.Find(`input[name="lt"]`).Attr("value")
 	if !ok {
+		parseFailures.WithLabelValues(endpointCAS).Inc()
 		return nil, errors.New("login token lt not found")
 	}
 	exec, _ := doc.Find(`input[name="execution"]`).Attr("value")
@@ -88,64 +202,136 @@ func FetchLibraryLoginUrl(client *http.Client, username, password string) ([]byt
 	form.Set("_eventId", "submit")
 	form.Set("submit", "登录")
 
-	req, _ := http.NewRequest("POST", LibraryLoginUrl, strings.NewReader(form.Encode()))
+	req, _ = http.NewRequestWithContext(ctx, "POST", k.ep.LibraryLoginUrl, strings.NewReader(form.Encode()))
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Set("User-Agent", "Mozilla/5.0")
-	req.Header.Set("Origin", "https://account.ccnu.edu.cn")
-	req.Header.Set("Referer", LibraryLoginUrl)
+	req.Header.Set("User-Agent", k.ua)
+	req.Header.Set("Origin", originOf(k.ep.LibraryLoginUrl))
+	req.Header.Set("Referer", k.ep.LibraryLoginUrl)
 
-	resp, err = client.Do(req)
+	resp, err = k.up.send(client, endpointCAS, req, false)
 	if err != nil {
-		return nil, errors.New("图书馆登录请求失败" + err.Error())
+		return nil, fmt.Errorf("图书馆登录请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 	// 解析返回页面判断是否登录成功
 	bodyByte, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, errors.New("读取图书馆登录响应失败: " + err.Error())
+		return nil, fmt.Errorf("读取图书馆登录响应失败: %w", err)
 	}
-	return bodyByte, nil
+	return parseLoginResult(bodyByte)
 }
 
-func FetchSearchUrl(client *http.Client, Area string, year, month, day int, startTime, endTime string) ([]byte, error) {
+func (k *kjyyBackend) ExchangeTicket(ctx context.Context, client *http.Client) error {
+	// 已经登录过 CAS 时，CAS 直接签发 ticket 并跳转到 loginall.aspx，由它设置预约系统的 session
+	req, _ := http.NewRequestWithContext(ctx, "GET", k.ep.LibraryLoginUrl, nil)
+	req.Header.Set("User-Agent", k.ua)
+	resp, err := k.up.send(client, endpointCAS, req, false)
+	if err != nil {
+		return fmt.Errorf("换取图书馆会话失败: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("换取图书馆会话状态码异常: " + resp.Status)
+	}
+	cas, _ := url.Parse(k.ep.CASUrl)
+	if final := resp.Request.URL; final.Host == cas.Host && final.Path == cas.Path {
+		// 停留在 CAS 登录页，说明 client 中没有有效的 CAS 会话
+		return errors.New("CAS 会话无效，没有跳转到图书馆")
+	}
+	return nil
+}
+
+func (k *kjyyBackend) SearchRoomStatus(ctx context.Context, client *http.Client, roomID string, date time.Time, openTime, closeTime string) ([]response.Seat, error) {
 	params := url.Values{}
 	params.Set("byType", "devcls")
 	params.Set("classkind", "8")
 	params.Set("display", "fp")
 	params.Set("md", "d")
-	params.Set("room_id", Area)
+	params.Set("room_id", roomID)
 	params.Set("purpose", "")
 	params.Set("selectOpenAty", "")
 	params.Set("cld_name", "default")
-	params.Set("date", fmt.Sprintf("%d-%02d-%02d", year, month, day))
-	params.Set("fr_start", startTime)
-	params.Set("fr_end", endTime)
+	params.Set("date", date.Format("2006-01-02"))
+	params.Set("fr_start", openTime)
+	params.Set("fr_end", closeTime)
 	params.Set("act", "get_rsv_sta")
-	params.Set("_", "16698463729090")
-	requestURL := SearchUrl + "?" + params.Encode()
+	params.Set("_", cacheBuster())
+	requestURL := k.ep.SearchUrl + "?" + params.Encode()
 
-	req, _ := http.NewRequest("GET", requestURL, nil)
+	req, _ := http.NewRequestWithContext(ctx, "GET", requestURL, nil)
 	req.Header.Set("Accept", "application/json, text/javascript, */*; q=0.01")
 	req.Header.Set("Accept-Language", "zh-CN,zh;q=0.9")
 	req.Header.Set("Cache-Control", "no-cache")
 	req.Header.Set("Pragma", "no-cache")
 	req.Header.Set("Proxy-Connection", "keep-alive")
-	req.Header.Set("Referer", "http://kjyy.ccnu.edu.cn/clientweb/xcus/ic2/Default.aspx")
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/142.0.0.0 Safari/537.36")
+	req.Header.Set("Referer", k.ep.referer())
+	req.Header.Set("User-Agent", k.ua)
+	req.Header.Set("X-Requested-With", "XMLHttpRequest")
+	resp, err := k.up.send(client, endpointSearch, req, true)
+	if err != nil {
+		return nil, fmt.Errorf("SearchUrl请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取SearchUrl响应失败: %w", err)
+	}
+
+	var bodyData response.SearchResp
+	if err = json.Unmarshal(bodyBytes, &bodyData); err != nil {
+		parseFailures.WithLabelValues(endpointSearch).Inc()
+		return nil, fmt.Errorf("解析SearchUrl响应失败: %w", err)
+	}
+	return bodyData.Data, nil
+}
+
+func (k *kjyyBackend) ListRooms(ctx context.Context, client *http.Client) ([]Room, error) {
+	params := url.Values{}
+	params.Set("classkind", "8")
+	params.Set("act", "get_rm_sta")
+	requestURL := k.ep.RoomUrl + "?" + params.Encode()
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", requestURL, nil)
+	req.Header.Set("Accept", "application/json, text/javascript, */*; q=0.01")
+	req.Header.Set("Referer", k.ep.referer())
+	req.Header.Set("User-Agent", k.ua)
 	req.Header.Set("X-Requested-With", "XMLHttpRequest")
-	resp, err := client.Do(req)
+	resp, err := k.up.send(client, endpointRoom, req, true)
 	if err != nil {
-		return nil, errors.New("SearchUrl请求失败: " + err.Error())
+		return nil, fmt.Errorf("RoomUrl请求失败: %w", err)
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("RoomUrl请求状态码异常: " + resp.Status)
+	}
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, errors.New("读取SearchUrl响应失败: " + err.Error())
+		return nil, fmt.Errorf("读取RoomUrl响应失败: %w", err)
+	}
+
+	var rr roomsResp
+	if err = json.Unmarshal(bodyBytes, &rr); err != nil {
+		parseFailures.WithLabelValues(endpointRoom).Inc()
+		return nil, fmt.Errorf("解析RoomUrl响应失败: %w", err)
+	}
+	if rr.Ret != 1 {
+		if IsSessionExpired(rr.Msg) {
+			sessionExpired.WithLabelValues(endpointRoom).Inc()
+		}
+		return nil, errors.New("获取房间列表失败: " + rr.Msg)
 	}
-	return bodyBytes, nil
+	return rr.Data, nil
+}
+
+func (k *kjyyBackend) Reserve(ctx context.Context, client *http.Client, seatID string, date time.Time, startTime, endTime string) (*ActResp, error) {
+	ar, err := k.reserve(ctx, client, seatID, date, startTime, endTime)
+	observeReserve(ar, err)
+	return ar, err
 }
 
-func FetchGrabUrl(client *http.Client, seatID string, year, month, day int, startTime, endTime string) ([]byte, error) {
+func (k *kjyyBackend) reserve(ctx context.Context, client *http.Client, seatID string, date time.Time, startTime, endTime string) (*ActResp, error) {
+	day := date.Format("2006-01-02")
 	params := url.Values{}
 	params.Set("dialogid", "")
 	params.Set("dev_id", seatID)
@@ -159,28 +345,28 @@ func FetchGrabUrl(client *http.Client, seatID string, year, month, day int, star
 	params.Set("Vnumber", "")
 	params.Set("classkind", "")
 	params.Set("test_name", "")
-	params.Set("start", fmt.Sprintf("%d-%02d-%02d %s", year, month, day, startTime))
-	params.Set("end", fmt.Sprintf("%d-%02d-%02d %s", year, month, day, endTime))
+	params.Set("start", fmt.Sprintf("%s %s", day, startTime))
+	params.Set("end", fmt.Sprintf("%s %s", day, endTime))
 	params.Set("start_time", "1000")
 	params.Set("end_time", "2200")
 	params.Set("up_file", "")
 	params.Set("memo", "")
 	params.Set("act", "set_resv")
-	params.Set("_", "170481145010")
-	requestURL := GrabUrl + "?" + params.Encode()
+	params.Set("_", cacheBuster())
+	requestURL := k.ep.GrabUrl + "?" + params.Encode()
 
-	req, _ := http.NewRequest("POST", requestURL, nil)
+	req, _ := http.NewRequestWithContext(ctx, "POST", requestURL, nil)
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Set("Accept", "application/json, text/javascript, */*; q=0.01")
 	req.Header.Set("Accept-Language", "zh-CN,zh;q=0.9,en;q=0.8,en-GB;q=0.7,en-US;q=0.6")
 	req.Header.Set("Connection", "keep-alive")
-	req.Header.Set("Referer", "http://kjyy.ccnu.edu.cn/clientweb/xcus/ic2/Default.aspx")
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.0.0")
+	req.Header.Set("Referer", k.ep.referer())
+	req.Header.Set("User-Agent", k.ua)
 	req.Header.Set("X-Requested-With", "XMLHttpRequest")
 
-	resp, err := client.Do(req)
+	resp, err := k.up.send(client, endpointReserve, req, k.up.retry.Reserve)
 	if err != nil {
-		return nil, errors.New("GrabUrl请求失败: " + err.Error())
+		return nil, fmt.Errorf("GrabUrl请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
@@ -188,30 +374,62 @@ func FetchGrabUrl(client *http.Client, seatID string, year, month, day int, star
 	}
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, errors.New("读取GrabUrl响应失败: " + err.Error())
+		return nil, fmt.Errorf("读取GrabUrl响应失败: %w", err)
 	}
-	return bodyBytes, nil
+
+	return decodeAct(endpointReserve, "GrabUrl", bodyBytes)
 }
 
-func FetchPersonUrl(client *http.Client) ([]byte, error) {
+func (k *kjyyBackend) CancelReservation(ctx context.Context, client *http.Client, rsvID string) (*ActResp, error) {
+	params := url.Values{}
+	params.Set("act", "del_resv")
+	params.Set("id", rsvID)
+	params.Set("_", cacheBuster())
+	requestURL := k.ep.GrabUrl + "?" + params.Encode()
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", requestURL, nil)
+	req.Header.Set("Accept", "application/json, text/javascript, */*; q=0.01")
+	req.Header.Set("Accept-Language", "zh-CN,zh;q=0.9")
+	req.Header.Set("Cache-Control", "no-cache")
+	req.Header.Set("Pragma", "no-cache")
+	req.Header.Set("Referer", k.ep.referer())
+	req.Header.Set("User-Agent", k.ua)
+	req.Header.Set("X-Requested-With", "XMLHttpRequest")
+	resp, err := k.up.send(client, endpointReserve, req, false)
+	if err != nil {
+		return nil, fmt.Errorf("GrabUrl请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("GrabUrl请求状态码异常: " + resp.Status)
+	}
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取GrabUrl响应失败: %w", err)
+	}
+
+	return decodeAct(endpointReserve, "GrabUrl", bodyBytes)
+}
+
+func (k *kjyyBackend) History(ctx context.Context, client *http.Client) (*ActResp, error) {
 	params := url.Values{}
 	params.Set("act", "get_History_resv")
 	params.Set("strat", "90")
 	params.Set("StatFlag", "New")
-	params.Set("_", "1704815632495")
-	requestURL := PersonUrl + "?" + params.Encode()
+	params.Set("_", cacheBuster())
+	requestURL := k.ep.PersonUrl + "?" + params.Encode()
 
-	req, _ := http.NewRequest("GET", requestURL, nil)
+	req, _ := http.NewRequestWithContext(ctx, "GET", requestURL, nil)
 	req.Header.Set("Accept", "application/json, text/javascript, */*; q=0.01")
 	req.Header.Set("Accept-Language", "zh-CN,zh;q=0.9")
 	req.Header.Set("Cache-Control", "no-cache")
 	req.Header.Set("Pragma", "no-cache")
-	req.Header.Set("Referer", "http://kjyy.ccnu.edu.cn/clientweb/xcus/ic2/Default.aspx")
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/142.0.0.0 Safari/537.36")
+	req.Header.Set("Referer", k.ep.referer())
+	req.Header.Set("User-Agent", k.ua)
 	req.Header.Set("X-Requested-With", "XMLHttpRequest")
-	resp, err := client.Do(req)
+	resp, err := k.up.send(client, endpointCenter, req, true)
 	if err != nil {
-		return nil, errors.New("PersonUrl请求失败: " + err.Error())
+		return nil, fmt.Errorf("PersonUrl请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
@@ -220,7 +438,114 @@ func FetchPersonUrl(client *http.Client) ([]byte, error) {
 	// 进一步检查返回体，未登录情况会返回 JSON 提示
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, errors.New("读取PersonUrl响应失败: " + err.Error())
+		return nil, fmt.Errorf("读取PersonUrl响应失败: %w", err)
+	}
+
+	return decodeAct(endpointCenter, "PersonUrl", bodyBytes)
+}
+
+func (k *kjyyBackend) ServerTime(ctx context.Context, client *http.Client) (time.Time, error) {
+	req, _ := http.NewRequestWithContext(ctx, "HEAD", k.ep.SearchUrl, nil)
+	req.Header.Set("Cache-Control", "no-cache")
+	req.Header.Set("User-Agent", k.ua)
+	resp, err := k.up.send(client, endpointSearch, req, false)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("获取服务器时间失败: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	t, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("解析服务器时间失败: %w", err)
+	}
+	return t, nil
+}
+
+func (k *kjyyBackend) Probe(ctx context.Context, client *http.Client, target string) error {
+	var (
+		endpoint string
+		req      *http.Request
+	)
+	switch target {
+	case ProbeCAS:
+		endpoint = endpointCAS
+		req, _ = http.NewRequestWithContext(ctx, "GET", k.ep.CASUrl, nil)
+	case ProbeKjyy:
+		endpoint = endpointSearch
+		req, _ = http.NewRequestWithContext(ctx, "HEAD", k.ep.SearchUrl, nil)
+	default:
+		return fmt.Errorf("未知的检查目标 %s", target)
+	}
+	req.Header.Set("Cache-Control", "no-cache")
+	req.Header.Set("User-Agent", k.ua)
+	resp, err := do(client, endpoint, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return errors.New("上游状态码异常: " + resp.Status)
+	}
+	return nil
+}
+
+func (k *kjyyBackend) CircuitState(target string) string {
+	switch target {
+	case ProbeCAS:
+		return k.up.state(hostOf(k.ep.CASUrl))
+	case ProbeKjyy:
+		return k.up.state(hostOf(k.ep.SearchUrl))
+	}
+	return CircuitClosed
+}
+
+// decodeAct 解析 kjyy ajax 接口的响应，并记录解析失败和登录失效
+func decodeAct(endpoint, name string, body []byte) (*ActResp, error) {
+	var ar ActResp
+	if err := json.Unmarshal(body, &ar); err != nil {
+		parseFailures.WithLabelValues(endpoint).Inc()
+		return nil, errors.New("解析" + name + "响应失败: " + err.Error())
+	}
+	if IsSessionExpired(ar.Msg) {
+		sessionExpired.WithLabelValues(endpoint).Inc()
+	}
+	return &ar, nil
+}
+
+// parseLoginResult 从 CAS 返回的页面中提取登录提示信息
+func parseLoginResult(body []byte) (*LoginResult, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(body)))
+	if err != nil {
+		parseFailures.WithLabelValues(endpointCAS).Inc()
+		return nil, fmt.Errorf("解析登录响应失败: %w", err)
+	}
+	return &LoginResult{
+		ErrMsg:     strings.TrimSpace(doc.Find("div#msg.errors").Text()),
+		SuccessMsg: strings.TrimSpace(doc.Find("#msg.success").Text()),
+	}, nil
+}
+
+// cacheBuster 与浏览器中 jQuery 的做法一样，用当前的毫秒时间戳避免 ajax 请求被缓存
+func cacheBuster() string {
+	return strconv.FormatInt(time.Now().UnixMilli(), 10)
+}
+
+// hostOf 返回 url 的 host 部分，熔断器按 host 区分上游
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return u.Host
+}
+
+// originOf 返回 url 的 scheme://host 部分，用作 Origin 请求头
+func originOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
 	}
-	return bodyBytes, nil
+	return u.Scheme + "://" + u.Host
 }
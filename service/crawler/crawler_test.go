@@ -0,0 +1,281 @@
+package crawler_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Serendipity565/GrabSeat/config"
+	"github.com/Serendipity565/GrabSeat/internal/fakekjyy"
+	"github.com/Serendipity565/GrabSeat/service/crawler"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func newFakeBackend(t *testing.T) (*fakekjyy.Server, crawler.LibraryBackend) {
+	fake := fakekjyy.New()
+	t.Cleanup(fake.Close)
+
+	fake.AddUser("2023000001", "123456", "张三")
+	fake.AddRoom("101699191", "南湖分馆一楼")
+	fake.AddSeat("101699191", "101", "N1224")
+	fake.AddSeat("101699191", "102", "N1225")
+
+	return fake, crawler.NewKjyyBackendWithEndpoints(fake.Endpoints(), nil)
+}
+
+func newJarClient(t *testing.T) *http.Client {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &http.Client{Jar: jar, Timeout: 5 * time.Second}
+}
+
+func TestKjyyBackend_LoginCAS(t *testing.T) {
+	_, backend := newFakeBackend(t)
+	ctx := context.Background()
+
+	lr, err := backend.LoginCAS(ctx, newJarClient(t), "2023000001", "123456")
+	if err != nil {
+		t.Fatalf("期望没有错误，实际: %v", err)
+	}
+	if !strings.Contains(lr.SuccessMsg, "登录成功") {
+		t.Fatalf("期望登录成功，实际: %+v", lr)
+	}
+
+	lr, err = backend.LoginCAS(ctx, newJarClient(t), "2023000001", "wrong")
+	if err != nil {
+		t.Fatalf("期望没有错误，实际: %v", err)
+	}
+	if !strings.Contains(lr.ErrMsg, "您输入的用户名或密码有误") {
+		t.Fatalf("期望返回密码错误提示，实际: %+v", lr)
+	}
+}
+
+func TestKjyyBackend_ReserveAndHistory(t *testing.T) {
+	fake, backend := newFakeBackend(t)
+	client := newJarClient(t)
+	ctx := context.Background()
+
+	// 未登录时 center.aspx 返回 session 超时
+	ar, err := backend.History(ctx, client)
+	if err != nil {
+		t.Fatalf("期望没有错误，实际: %v", err)
+	}
+	if !strings.Contains(ar.Msg, "session =null") {
+		t.Fatalf("期望返回未登录提示，实际: %+v", ar)
+	}
+
+	lr, err := backend.LoginLibrary(ctx, client, "2023000001", "123456")
+	if err != nil || lr.ErrMsg != "" {
+		t.Fatalf("期望登录成功，实际: %+v, %v", lr, err)
+	}
+
+	date := time.Now().AddDate(0, 0, 1)
+	ar, err = backend.Reserve(ctx, client, "101", date, "08:00", "12:00")
+	if err != nil {
+		t.Fatalf("期望没有错误，实际: %v", err)
+	}
+	if !strings.Contains(ar.Msg, "操作成功") {
+		t.Fatalf("期望预约成功，实际: %+v", ar)
+	}
+
+	// 同一座位同一时间段不能重复预约
+	ar, err = backend.Reserve(ctx, client, "101", date, "10:00", "11:00")
+	if err != nil {
+		t.Fatalf("期望没有错误，实际: %v", err)
+	}
+	if strings.Contains(ar.Msg, "操作成功") {
+		t.Fatalf("期望预约冲突，实际: %+v", ar)
+	}
+
+	seats, err := backend.SearchRoomStatus(ctx, client, "101699191", date, "8:00", "22:00")
+	if err != nil {
+		t.Fatalf("期望没有错误，实际: %v", err)
+	}
+	if len(seats) != 2 || len(seats[0].Ts) != 1 || seats[0].Ts[0].Owner != "张三" {
+		t.Fatalf("期望 N1224 被张三预约，实际: %+v", seats)
+	}
+
+	rooms, err := backend.ListRooms(ctx, client)
+	if err != nil || len(rooms) != 1 || rooms[0].ID != "101699191" || rooms[0].Name != "南湖分馆一楼" {
+		t.Fatalf("期望返回南湖分馆一楼，实际: %+v, %v", rooms, err)
+	}
+
+	ar, err = backend.History(ctx, client)
+	if err != nil {
+		t.Fatalf("期望没有错误，实际: %v", err)
+	}
+	rsvs := fake.Reservations()
+	if len(rsvs) != 1 || !strings.Contains(ar.Msg, "rsvId='"+rsvs[0].ID+"'") || !strings.Contains(ar.Msg, "N1224") {
+		t.Fatalf("期望历史记录中包含 N1224 的预约，实际: %s", ar.Msg)
+	}
+
+	// 取消预约后历史记录中不再有这条预约
+	ar, err = backend.CancelReservation(ctx, client, rsvs[0].ID)
+	if err != nil || !strings.Contains(ar.Msg, "操作成功") {
+		t.Fatalf("期望取消成功，实际: %+v, %v", ar, err)
+	}
+	if len(fake.Reservations()) != 0 {
+		t.Fatalf("期望预约已被删除，实际: %+v", fake.Reservations())
+	}
+	ar, err = backend.CancelReservation(ctx, client, rsvs[0].ID)
+	if err != nil || strings.Contains(ar.Msg, "操作成功") {
+		t.Fatalf("期望重复取消失败，实际: %+v, %v", ar, err)
+	}
+
+	// 会话失效后需要重新登录
+	fake.ExpireSessions()
+	ar, err = backend.History(ctx, client)
+	if err != nil || !strings.Contains(ar.Msg, "未登录") {
+		t.Fatalf("期望返回未登录提示，实际: %+v, %v", ar, err)
+	}
+}
+
+func TestReserveCategory(t *testing.T) {
+	cases := map[string]string{
+		"操作成功！":                  crawler.OutcomeSuccess,
+		"[N1224]该时间段已被预约":        crawler.OutcomeSeatTaken,
+		"您在该时间段已有预约":             "duplicate",
+		"预约时间不合法":                "time",
+		"未登录或登录超时，session =null": "session_expired",
+		"设备不存在":                  "not_found",
+		"系统维护中":                  "other",
+	}
+	for msg, want := range cases {
+		if got := crawler.ReserveCategory(msg); got != want {
+			t.Errorf("%s: 期望 %s，实际 %s", msg, want, got)
+		}
+	}
+}
+
+func TestKjyyBackend_Metrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	crawler.RegisterMetrics(reg)
+	_, backend := newFakeBackend(t)
+	client := newJarClient(t)
+	ctx := context.Background()
+
+	before := counterValue(t, reg, "crawler_grab_outcomes_total", map[string]string{"outcome": crawler.OutcomeSeatTaken})
+	if _, err := backend.LoginLibrary(ctx, client, "2023000001", "123456"); err != nil {
+		t.Fatal(err)
+	}
+	date := time.Now().AddDate(0, 0, 1)
+	for i := 0; i < 2; i++ {
+		if _, err := backend.Reserve(ctx, client, "101", date, "08:00", "12:00"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 第二次预约同一座位被拒绝
+	if got := counterValue(t, reg, "crawler_grab_outcomes_total", map[string]string{"outcome": crawler.OutcomeSeatTaken}); got != before+1 {
+		t.Fatalf("期望座位已被预约计数加一，实际: %v -> %v", before, got)
+	}
+	if got := counterValue(t, reg, "crawler_upstream_requests_total", map[string]string{"endpoint": "reserve", "status": "200"}); got < 2 {
+		t.Fatalf("期望记录预约接口的请求，实际: %v", got)
+	}
+
+	// 调用方已经取消时不会发出请求
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := backend.History(canceled, client); !errors.Is(err, context.Canceled) {
+		t.Fatalf("期望 context.Canceled，实际: %v", err)
+	}
+	if got := counterValue(t, reg, "crawler_upstream_requests_total", map[string]string{"endpoint": "center", "status": "canceled"}); got != 1 {
+		t.Fatalf("期望记录一次取消的请求，实际: %v", got)
+	}
+}
+
+func TestKjyyBackend_RetryAndBreaker(t *testing.T) {
+	var (
+		hits    atomic.Int32
+		healthy atomic.Int32 // 第几个请求开始恢复正常，0 表示一直失败
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := hits.Add(1)
+		if h := healthy.Load(); h == 0 || n < h {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"ret":1,"act":"get_History_resv","msg":"ok"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	ep := crawler.Endpoints{
+		CASUrl:          srv.URL + "/cas/login",
+		LibraryLoginUrl: srv.URL + "/cas/login?service=",
+		SearchUrl:       srv.URL + "/device.aspx",
+		GrabUrl:         srv.URL + "/reserve.aspx",
+		PersonUrl:       srv.URL + "/center.aspx",
+	}
+	conf := &config.UpstreamConfig{
+		Retry:   config.RetryConfig{MaxAttempts: 3, BaseDelayMs: 1, MaxDelayMs: 5},
+		Breaker: config.BreakerConfig{Threshold: 4, OpenSeconds: 60},
+	}
+	backend := crawler.NewKjyyBackendWithEndpoints(ep, conf)
+	client := &http.Client{Timeout: 5 * time.Second}
+	ctx := context.Background()
+
+	// 读请求失败两次后第三次成功
+	healthy.Store(3)
+	if ar, err := backend.History(ctx, client); err != nil || ar.Msg != "ok" {
+		t.Fatalf("期望重试后成功，实际: %+v, %v", ar, err)
+	}
+	if hits.Load() != 3 {
+		t.Fatalf("期望请求 3 次，实际: %d", hits.Load())
+	}
+
+	// 预约请求默认不重试
+	hits.Store(0)
+	healthy.Store(0)
+	if _, err := backend.Reserve(ctx, client, "101", time.Now(), "08:00", "10:00"); err == nil {
+		t.Fatal("期望预约失败")
+	}
+	if hits.Load() != 1 {
+		t.Fatalf("期望预约只请求 1 次，实际: %d", hits.Load())
+	}
+
+	// 预约失败 1 次，加上这次的 3 次，连续失败 4 次后熔断，之后的请求不再发出
+	if _, err := backend.History(ctx, client); err == nil {
+		t.Fatal("期望请求失败")
+	}
+	if state := backend.CircuitState(crawler.ProbeKjyy); state != crawler.CircuitOpen {
+		t.Fatalf("期望熔断，实际: %s", state)
+	}
+	before := hits.Load()
+	if _, err := backend.History(ctx, client); !errors.Is(err, crawler.ErrCircuitOpen) {
+		t.Fatalf("期望 ErrCircuitOpen，实际: %v", err)
+	}
+	if hits.Load() != before {
+		t.Fatalf("期望熔断期间不发出请求，实际: %d -> %d", before, hits.Load())
+	}
+}
+
+// counterValue 返回 registry 中标签匹配的计数器之和
+func counterValue(t *testing.T, reg *prometheus.Registry, name string, labels map[string]string) float64 {
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sum float64
+	for _, mf := range families {
+		if mf.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, m := range mf.GetMetric() {
+			for _, lp := range m.GetLabel() {
+				if v, ok := labels[lp.GetName()]; ok && v != lp.GetValue() {
+					continue metrics
+				}
+			}
+			sum += m.GetCounter().GetValue()
+		}
+	}
+	return sum
+}
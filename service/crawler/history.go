@@ -0,0 +1,127 @@
+package crawler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// 预约记录中的状态
+const (
+	StateReserved    = "预约成功"
+	StateNotEffected = "未生效"
+	StateEffected    = "已生效"
+	StateApproved    = "审核通过"
+)
+
+// HistoryRecord 个人预约记录中的一条预约
+type HistoryRecord struct {
+	RsvID     string // 取消预约时使用的 rsvId，已结束的预约没有
+	Title     string // 座位名称，例如 N1245
+	Room      string // 区域名称，例如 南湖分馆一楼
+	Owner     string // 预约人
+	Start     time.Time
+	End       time.Time
+	CreatedAt time.Time
+	States    []string // 例如 预约成功、未生效、审核通过
+}
+
+// Has 预约是否带有某个状态
+func (r *HistoryRecord) Has(state string) bool {
+	for _, s := range r.States {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseHistory 解析 get_History_resv 返回的 HTML，每个 tbody 对应一条预约：
+//
+//	<tbody date='2025-11-24 14:00' state='4482' over='false'>
+//	  <tr class='head'>...</tr>
+//	  <tr class='content'>
+//	    <td><div class='box'><a>N1245</a><div class='grey'>南湖分馆一楼</div></div></td>
+//	    <td>姜高峰</td>
+//	    <td><span class='grey'>个人预约</span></td>
+//	    <td>... 开始: <span class='text-primary'>11-24 19:00</span> ... 结束: <span class='text-primary'>11-24 22:00</span></td>
+//	    <td><div><span class='uni_trans'>预约成功</span>,<span class='uni_trans'>未生效</span>,<span class='uni_trans'>审核通过</span></div></td>
+//	    <td><a class='click' rsvId='175901668' onclick='delRsv(this);'>取消</a></td>
+//	  </tr>
+//	</tbody>
+//
+// 开始和结束时间没有年份，取距离 now 最近的年份
+func ParseHistory(msg string, now time.Time) ([]HistoryRecord, error) {
+	// tbody 需要放在 table 中才能被正确解析
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader("<table>" + msg + "</table>"))
+	if err != nil {
+		return nil, errors.New("解析预约记录失败: " + err.Error())
+	}
+
+	records := make([]HistoryRecord, 0)
+	var parseErr error
+	doc.Find("tbody").EachWithBreak(func(_ int, body *goquery.Selection) bool {
+		row := body.Find("tr.content")
+		if row.Length() == 0 {
+			// 没有数据
+			return true
+		}
+		tds := row.Children()
+		if tds.Length() < 5 {
+			parseErr = fmt.Errorf("预约记录格式错误: 只有 %d 列", tds.Length())
+			return false
+		}
+
+		times := tds.Eq(3).Find(".text-primary")
+		start, err1 := parseHistoryTime(times.Eq(0).Text(), now)
+		end, err2 := parseHistoryTime(times.Eq(1).Text(), now)
+		if err := errors.Join(err1, err2); err != nil {
+			parseErr = errors.New("解析预约时间失败: " + err.Error())
+			return false
+		}
+		if end.Before(start) {
+			// 跨年的预约
+			end = end.AddDate(1, 0, 0)
+		}
+
+		record := HistoryRecord{
+			Title: strings.TrimSpace(tds.Eq(0).Find("a").First().Text()),
+			Room:  strings.TrimSpace(tds.Eq(0).Find(".grey").First().Text()),
+			Owner: strings.TrimSpace(tds.Eq(1).Text()),
+			Start: start,
+			End:   end,
+		}
+		// HTML 解析后属性名为小写
+		record.RsvID, _ = row.Find("a[rsvid]").Attr("rsvid")
+		if date, ok := body.Attr("date"); ok {
+			record.CreatedAt, _ = time.ParseInLocation("2006-01-02 15:04", date, now.Location())
+		}
+		tds.Eq(4).Find(".uni_trans").Each(func(_ int, s *goquery.Selection) {
+			record.States = append(record.States, strings.TrimSpace(s.Text()))
+		})
+		records = append(records, record)
+		return true
+	})
+	if parseErr != nil {
+		return nil, parseErr
+	}
+	return records, nil
+}
+
+// parseHistoryTime 解析 11-24 19:00 格式的时间，年份取距离 now 最近的一年
+func parseHistoryTime(s string, now time.Time) (time.Time, error) {
+	t, err := time.ParseInLocation("2006-01-02 15:04", fmt.Sprintf("%d-%s", now.Year(), strings.TrimSpace(s)), now.Location())
+	if err != nil {
+		return time.Time{}, err
+	}
+	// 12 月查询到的 01 月预约属于下一年，反之属于上一年
+	if t.Sub(now) < -180*24*time.Hour {
+		t = t.AddDate(1, 0, 0)
+	} else if t.Sub(now) > 180*24*time.Hour {
+		t = t.AddDate(-1, 0, 0)
+	}
+	return t, nil
+}
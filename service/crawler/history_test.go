@@ -0,0 +1,40 @@
+package crawler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseHistory(t *testing.T) {
+	msg := "<tbody date='2025-11-24 14:00' state='4482' over='false'><tr class='head'><td colspan='6'><h3></h3><span><span class='orange uni_trans'>预约成功</span></span><span class='pull-right'><span class='grey'>2025-11-24 14:00</span></span></td></tr><tr class='content'><td><div class='box'><a>N1245</a><div class='grey'>南湖分馆一楼</div</div></td><td>姜高峰</td><td style='max-width:300px'><span class='grey'>个人预约</span></td><td><div><div><span class='grey'>开始:</span> <span class='text-primary'>11-24 19:00</span></div><div><span class='grey'>结束:</span> <span class='text-primary'>11-24 22:00</span></div></div></td><td><div><span style='color:green' class='uni_trans'>预约成功</span>,<span style='color:orange' class='uni_trans'>未生效</span>,<span style='color:green' class='uni_trans'>审核通过</span></div><div style='font-size:12px;color:#777;'></div></td><td class='text-center' style='vertical-align: middle;'><a class='click' rsvId='175901668' onclick='delRsv(this);'>取消</a></td></tr></tbody>"
+	now := time.Date(2025, 11, 24, 14, 30, 0, 0, time.Local)
+
+	records, err := ParseHistory(msg, now)
+	if err != nil {
+		t.Fatalf("期望没有错误，实际: %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("期望解析出 1 条预约，实际: %+v", records)
+	}
+	r := records[0]
+	if r.RsvID != "175901668" || r.Title != "N1245" || r.Room != "南湖分馆一楼" || r.Owner != "姜高峰" {
+		t.Fatalf("预约信息解析错误: %+v", r)
+	}
+	if !r.Start.Equal(time.Date(2025, 11, 24, 19, 0, 0, 0, time.Local)) || !r.End.Equal(time.Date(2025, 11, 24, 22, 0, 0, 0, time.Local)) {
+		t.Fatalf("预约时间解析错误: %v - %v", r.Start, r.End)
+	}
+	if !r.Has(StateReserved) || !r.Has(StateNotEffected) || !r.Has(StateApproved) || r.Has(StateEffected) {
+		t.Fatalf("预约状态解析错误: %v", r.States)
+	}
+
+	// 跨年后查询到的 11 月的预约属于上一年
+	records, err = ParseHistory(msg, time.Date(2026, 1, 2, 0, 0, 0, 0, time.Local))
+	if err != nil || records[0].Start.Year() != 2025 {
+		t.Fatalf("期望年份为 2025，实际: %+v, %v", records, err)
+	}
+
+	records, err = ParseHistory("<tbody><tr><td colspan='6' class='text-center'>没有数据</td></tr></tbody>", now)
+	if err != nil || len(records) != 0 {
+		t.Fatalf("期望没有预约，实际: %+v, %v", records, err)
+	}
+}
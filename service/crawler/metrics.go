@@ -0,0 +1,162 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// 上游接口，作为监控指标的 endpoint 标签
+const (
+	endpointCAS     = "cas"     // 统一身份认证
+	endpointSearch  = "search"  // device.aspx
+	endpointReserve = "reserve" // reserve.aspx
+	endpointCenter  = "center"  // center.aspx
+	endpointRoom    = "room"    // room.aspx
+)
+
+// 预约结果，作为 crawler_grab_outcomes_total 的 outcome 标签
+const (
+	OutcomeSuccess   = "success"
+	OutcomeSeatTaken = "seat_taken"
+	OutcomeRejected  = "rejected"
+	OutcomeError     = "error"
+)
+
+var (
+	upstreamDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "crawler_upstream_request_duration_seconds",
+			Help:    "Histogram of library upstream request durations",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"endpoint"},
+	)
+
+	upstreamRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "crawler_upstream_requests_total",
+			Help: "Total number of library upstream requests by status code",
+		},
+		[]string{"endpoint", "status"},
+	)
+
+	parseFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "crawler_parse_failures_total",
+			Help: "Total number of library upstream responses that could not be parsed",
+		},
+		[]string{"endpoint"},
+	)
+
+	sessionExpired = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "crawler_session_expired_total",
+			Help: "Total number of library upstream responses reporting an expired session",
+		},
+		[]string{"endpoint"},
+	)
+
+	upstreamRetries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "crawler_upstream_retries_total",
+			Help: "Total number of retried library upstream requests",
+		},
+		[]string{"endpoint"},
+	)
+
+	circuitState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "crawler_circuit_breaker_state",
+			Help: "Circuit breaker state per library upstream host (0 closed, 1 half-open, 2 open)",
+		},
+		[]string{"host"},
+	)
+
+	circuitRejections = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "crawler_circuit_breaker_rejections_total",
+			Help: "Total number of library upstream requests rejected by an open circuit breaker",
+		},
+		[]string{"host"},
+	)
+
+	grabOutcomes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "crawler_grab_outcomes_total",
+			Help: "Total number of seat reservation attempts by outcome",
+		},
+		[]string{"outcome", "category"},
+	)
+)
+
+// RegisterMetrics 注册上游请求相关的监控指标
+func RegisterMetrics(reg prometheus.Registerer) {
+	reg.MustRegister(upstreamDuration, upstreamRequests, parseFailures, sessionExpired, upstreamRetries, circuitState, circuitRejections, grabOutcomes)
+}
+
+// do 发送请求并记录耗时和状态码，请求失败时状态码记为 error，
+// 因为调用方取消或超时而失败时分别记为 canceled 和 timeout
+func do(client *http.Client, endpoint string, req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := client.Do(req)
+	upstreamDuration.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
+	if err != nil {
+		status := "error"
+		switch {
+		case errors.Is(err, context.Canceled):
+			status = "canceled"
+		case errors.Is(err, context.DeadlineExceeded):
+			status = "timeout"
+		}
+		upstreamRequests.WithLabelValues(endpoint, status).Inc()
+		return nil, err
+	}
+	upstreamRequests.WithLabelValues(endpoint, strconv.Itoa(resp.StatusCode)).Inc()
+	return resp, nil
+}
+
+// IsSessionExpired 上游返回的提示是否表示登录已经失效
+func IsSessionExpired(msg string) bool {
+	return strings.Contains(msg, "未登录") || strings.Contains(msg, "登录超时") || strings.Contains(msg, "session =null")
+}
+
+// ReserveCategory 按预约接口返回的 msg 对结果分类
+func ReserveCategory(msg string) string {
+	switch {
+	case strings.Contains(msg, "操作成功"):
+		return OutcomeSuccess
+	case strings.Contains(msg, "已被预约"):
+		return OutcomeSeatTaken
+	case IsSessionExpired(msg):
+		return "session_expired"
+	case strings.Contains(msg, "已有预约"):
+		return "duplicate"
+	case strings.Contains(msg, "时间"):
+		return "time"
+	case strings.Contains(msg, "不存在"):
+		return "not_found"
+	default:
+		return "other"
+	}
+}
+
+// observeReserve 记录一次预约的结果，err 不为空表示请求本身失败
+func observeReserve(ar *ActResp, err error) {
+	if err != nil {
+		grabOutcomes.WithLabelValues(OutcomeError, OutcomeError).Inc()
+		return
+	}
+	category := ReserveCategory(ar.Msg)
+	switch category {
+	case OutcomeSuccess, OutcomeSeatTaken:
+		grabOutcomes.WithLabelValues(category, category).Inc()
+	default:
+		grabOutcomes.WithLabelValues(OutcomeRejected, category).Inc()
+	}
+}
@@ -0,0 +1,207 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Serendipity565/GrabSeat/config"
+)
+
+// ErrCircuitOpen 上游连续失败后熔断，请求直接失败而不是等到超时
+var ErrCircuitOpen = errors.New("crawler: circuit breaker open")
+
+// 熔断器状态
+const (
+	CircuitClosed   = "closed"    // 正常放行
+	CircuitOpen     = "open"      // 熔断中，请求直接失败
+	CircuitHalfOpen = "half_open" // 熔断时间已过，放行一个探测请求
+)
+
+// circuitValue 熔断器状态在 crawler_circuit_breaker_state 中的取值
+var circuitValue = map[string]float64{
+	CircuitClosed:   0,
+	CircuitHalfOpen: 1,
+	CircuitOpen:     2,
+}
+
+// breaker 单个上游域名的熔断器
+// 连续失败 threshold 次后熔断，openTimeout 之后放行一个探测请求，探测成功则恢复，失败则继续熔断
+type breaker struct {
+	mu          sync.Mutex
+	host        string
+	threshold   int
+	openTimeout time.Duration
+	state       string
+	failures    int // 连续失败次数
+	openedAt    time.Time
+	probing     bool // 半开状态下是否已经放行了探测请求
+}
+
+func newBreaker(host string, conf config.BreakerConfig) *breaker {
+	b := &breaker{
+		host:        host,
+		threshold:   conf.Threshold,
+		openTimeout: time.Duration(conf.OpenSeconds) * time.Second,
+		state:       CircuitClosed,
+	}
+	circuitState.WithLabelValues(host).Set(circuitValue[CircuitClosed])
+	return b
+}
+
+// allow 判断是否放行请求，熔断中返回 ErrCircuitOpen
+func (b *breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case CircuitOpen:
+		if time.Since(b.openedAt) < b.openTimeout {
+			circuitRejections.WithLabelValues(b.host).Inc()
+			return ErrCircuitOpen
+		}
+		b.setState(CircuitHalfOpen)
+		fallthrough
+	case CircuitHalfOpen:
+		if b.probing {
+			circuitRejections.WithLabelValues(b.host).Inc()
+			return ErrCircuitOpen
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// record 记录请求结果，failed 表示上游不可用
+func (b *breaker) record(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	if !failed {
+		b.failures = 0
+		b.setState(CircuitClosed)
+		return
+	}
+	b.failures++
+	if b.state == CircuitHalfOpen || b.failures >= b.threshold {
+		b.openedAt = time.Now()
+		b.setState(CircuitOpen)
+	}
+}
+
+// cancel 请求因为调用方取消而没有结果，半开状态下允许再放行一个探测请求
+func (b *breaker) cancel() {
+	b.mu.Lock()
+	b.probing = false
+	b.mu.Unlock()
+}
+
+func (b *breaker) current() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == CircuitOpen && time.Since(b.openedAt) >= b.openTimeout {
+		// 下一个请求会作为探测请求放行
+		return CircuitHalfOpen
+	}
+	return b.state
+}
+
+func (b *breaker) setState(state string) {
+	b.state = state
+	circuitState.WithLabelValues(b.host).Set(circuitValue[state])
+}
+
+// upstream 访问上游的重试和熔断策略，conf 为 nil 时不重试也不熔断
+type upstream struct {
+	retry    config.RetryConfig
+	breakers map[string]*breaker // 上游域名 -> 熔断器
+}
+
+func newUpstream(ep Endpoints, conf *config.UpstreamConfig) *upstream {
+	u := &upstream{retry: config.RetryConfig{MaxAttempts: 1}}
+	if conf == nil {
+		return u
+	}
+	u.retry = conf.Retry
+	u.breakers = make(map[string]*breaker)
+	for _, raw := range []string{ep.CASUrl, ep.LibraryLoginUrl, ep.SearchUrl, ep.GrabUrl, ep.PersonUrl, ep.RoomUrl} {
+		host := hostOf(raw)
+		if _, ok := u.breakers[host]; !ok {
+			u.breakers[host] = newBreaker(host, conf.Breaker)
+		}
+	}
+	return u
+}
+
+// send 经过熔断器发送请求，retry 为 true 时对网络错误和 5xx 按指数退避加随机抖动重试
+// 只有没有请求体的幂等请求才能重试
+func (u *upstream) send(client *http.Client, endpoint string, req *http.Request, retry bool) (*http.Response, error) {
+	b := u.breakers[req.URL.Host]
+	attempts := 1
+	if retry {
+		attempts = max(u.retry.MaxAttempts, 1)
+	}
+	for i := 0; ; i++ {
+		if b != nil {
+			if err := b.allow(); err != nil {
+				return nil, err
+			}
+		}
+		resp, err := do(client, endpoint, req)
+		failed := err != nil || resp.StatusCode >= http.StatusInternalServerError
+		if b != nil {
+			if errors.Is(err, context.Canceled) {
+				b.cancel()
+			} else {
+				b.record(failed)
+			}
+		}
+		if !failed || i+1 >= attempts || req.Context().Err() != nil {
+			return resp, err
+		}
+
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		upstreamRetries.WithLabelValues(endpoint).Inc()
+		if err = Sleep(req.Context(), u.backoff(i)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// backoff 第 i 次重试前的等待时间，在 [d/2, d) 之间随机，d 从 baseDelay 开始每次翻倍
+func (u *upstream) backoff(i int) time.Duration {
+	d := time.Duration(u.retry.BaseDelayMs) * time.Millisecond << i
+	if limit := time.Duration(u.retry.MaxDelayMs) * time.Millisecond; d > limit || d <= 0 {
+		d = limit
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// state 返回某个上游域名的熔断器状态，没有启用熔断时总是 closed
+func (u *upstream) state(host string) string {
+	if b, ok := u.breakers[host]; ok {
+		return b.current()
+	}
+	return CircuitClosed
+}
+
+// Sleep 等待 d，ctx 取消时提前返回 ctx 的错误
+func Sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -1,23 +1,28 @@
 package service
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
+	"fmt"
 	"net/http"
-	"net/http/cookiejar"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/PuerkitoBio/goquery"
 	"github.com/Serendipity565/GrabSeat/api/response"
+	"github.com/Serendipity565/GrabSeat/config"
 	"github.com/Serendipity565/GrabSeat/errs"
+	"github.com/Serendipity565/GrabSeat/internal/data"
 	"github.com/Serendipity565/GrabSeat/pkg/logger"
 	"github.com/Serendipity565/GrabSeat/service/crawler"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
+// autoGrabMaxAttempts 自动选座时最多尝试的座位数
+const autoGrabMaxAttempts = 5
+
 var (
-	Areas = []string{"101699191", "101699189", "101699187", "101699179"}
 	State = map[string]string{
 		"doing":    "使用中",
 		"undo":     "未使用",
@@ -25,13 +30,41 @@ var (
 	}
 )
 
+// GrabberService 座位查询和预约
+// 访问图书馆的方法都接收 ctx，调用方取消或超时后正在进行的上游请求随之取消；
+// 每类操作还会按 DeadlineConfig 设置各自的超时时间
 type GrabberService interface {
-	GetClient(username, password string) (*http.Client, error)
-	FindVacantSeats(client *http.Client, startTime, endTime, keyWord string, isTomorrow bool) ([]response.Seat, error)
-	IsInLibrary(client *http.Client, name string) (*response.Occupant, error)
-	SeatToName(client *http.Client, seatName string, isTomorrow bool) ([]response.Ts, error)
-	Grab(client *http.Client, seatID, startTime, endTime string, isTomorrow bool) (bool, error)
-	GrabSuccess(client *http.Client) (bool, error)
+	// GetClient 返回用户已登录图书馆的 client，密码从凭据库中读取
+	GetClient(ctx context.Context, userID string) (*http.Client, error)
+	// Rooms 列出可以预约座位的房间
+	Rooms(ctx context.Context, client *http.Client) ([]response.Room, error)
+	FindVacantSeats(ctx context.Context, client *http.Client, startTime, endTime, keyWord string, date time.Time, filter RoomFilter) ([]response.Seat, *response.Meta, error)
+	IsInLibrary(ctx context.Context, client *http.Client, name string, filter RoomFilter) (*response.Occupant, *response.Meta, error)
+	SeatToName(ctx context.Context, client *http.Client, seatName string, date time.Time, filter RoomFilter) ([]response.Ts, *response.Meta, error)
+	Grab(ctx context.Context, client *http.Client, seatID, startTime, endTime string, date time.Time) (bool, error)
+	AutoGrab(ctx context.Context, client *http.Client, startTime, endTime, keyWord string, date time.Time, filter RoomFilter) (*response.GrabResult, error)
+	BurstGrab(ctx context.Context, client *http.Client, seatID, startTime, endTime string, releaseAt time.Time) (*response.BurstResult, error)
+	// ResolveSeat 把座位名称（例如 N1224）或设备ID解析为座位信息
+	ResolveSeat(ctx context.Context, client *http.Client, seat string) (*response.SeatDevice, error)
+	// SearchSeats 按关键词查找座位名称索引
+	SearchSeats(ctx context.Context, client *http.Client, keyWord string, filter RoomFilter) ([]response.SeatDevice, error)
+	// RefreshDevices 座位名称索引过期时在后台刷新，由 Ticker 定期调用
+	RefreshDevices(ctx context.Context)
+	GrabSuccess(ctx context.Context, client *http.Client, seatID, startTime, endTime string, date time.Time) (bool, error)
+	// MyReservations 获取个人预约记录
+	MyReservations(ctx context.Context, client *http.Client) ([]response.Reservation, error)
+	// CancelReservation 取消预约：指定 rsvID 时只取消这一条，否则取消 date 当天的所有预约，返回被取消的 rsvId
+	CancelReservation(ctx context.Context, client *http.Client, rsvID string, date time.Time) ([]string, error)
+	// PoolSessions 列出 cookie 池中缓存的会话
+	PoolSessions() []response.PoolSession
+	// EvictSession 从 cookie 池中移除某个用户
+	EvictSession(userID string) error
+	// SeedSession 把已经登录图书馆的 client 放入 cookie 池，之后的请求不需要重新登录
+	SeedSession(userID string, client *http.Client)
+	// FlushSessions 清空 cookie 池，返回被移除的会话数
+	FlushSessions() int
+	// CleanupExpired 移除过期的会话并关闭它们的空闲连接
+	CleanupExpired()
 }
 
 type clientEntry struct {
@@ -44,13 +77,32 @@ type grabberService struct {
 	cookiePool map[string]*clientEntry
 	ttl        time.Duration
 	log        logger.Logger
+	backend    crawler.LibraryBackend
+	burst      *config.BurstConfig
+	search     *config.SearchConfig
+	deadline   *config.DeadlineConfig
+	snapshots  SnapshotCache
+	topo       *Topology
+	clients    *crawler.ClientFactory
+	devices    *Cache
+	vault      data.CredentialVault
 }
 
-func NewGrabberService(log logger.Logger) GrabberService {
+func NewGrabberService(log logger.Logger, backend crawler.LibraryBackend, vault data.CredentialVault, burst *config.BurstConfig, search *config.SearchConfig, deadline *config.DeadlineConfig, snapshots SnapshotCache, topo *Topology, clients *crawler.ClientFactory, reg *prometheus.Registry) GrabberService {
+	RegisterMetrics(reg)
 	gs := &grabberService{
 		cookiePool: make(map[string]*clientEntry),
 		ttl:        25 * time.Minute, // 比 CAS session TTL 略短一些，防止临界时间产生一些问题
 		log:        log,
+		backend:    backend,
+		burst:      burst,
+		search:     search,
+		deadline:   deadline,
+		snapshots:  snapshots,
+		topo:       topo,
+		clients:    clients,
+		devices:    NewCache(),
+		vault:      vault,
 	}
 	go func() {
 		ticker := time.NewTicker(60 * time.Minute)
@@ -63,27 +115,93 @@ func NewGrabberService(log logger.Logger) GrabberService {
 	return gs
 }
 
+// areaResult 单个区域的查询结果
+type areaResult struct {
+	area  response.Room
+	seats []response.Seat
+	age   time.Duration // 快照的时间
+	err   error
+}
+
+// scanAreas 并发查询符合过滤条件的区域的座位情况，并发数由 SearchConfig.Parallelism 限制
+// 结果按房间列表的顺序返回；全部区域都失败时返回错误，否则返回的 Meta 中带有快照时间和部分失败的警告
+func (g *grabberService) scanAreas(ctx context.Context, client *http.Client, date time.Time, filter RoomFilter) ([]areaResult, *response.Meta, error) {
+	areas, err := g.topo.Select(ctx, client, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	results := make([]areaResult, len(areas))
+	sem := make(chan struct{}, g.search.Parallelism)
+	var wg sync.WaitGroup
+	for i, area := range areas {
+		wg.Add(1)
+		go func(i int, area response.Room) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			snap, err := g.snapshots.Get(ctx, area.ID, date, func(ctx context.Context) ([]response.Seat, error) {
+				ctx, cancel := withDeadline(ctx, g.deadline.Search)
+				defer cancel()
+				return g.backend.SearchRoomStatus(ctx, client, area.ID, date, area.OpenTime, area.CloseTime)
+			})
+			if err != nil {
+				results[i] = areaResult{area: area, err: err}
+				return
+			}
+			g.devices.Update(area.ID, snap.Seats)
+			results[i] = areaResult{area: area, seats: snap.Seats, age: snap.Age()}
+		}(i, area)
+	}
+	wg.Wait()
+
+	var (
+		meta     = &response.Meta{}
+		failures []error
+	)
+	for _, r := range results {
+		if r.err == nil {
+			// 多个区域的快照时间不同时，以最旧的为准
+			meta.SnapshotAgeMs = max(meta.SnapshotAgeMs, r.age.Milliseconds())
+			continue
+		}
+		failures = append(failures, r.err)
+		name := r.area.Name
+		if name == "" {
+			name = r.area.ID
+		}
+		meta.Warnings = append(meta.Warnings, fmt.Sprintf("区域 %s 查询失败，结果可能不完整", name))
+		g.log.Warn("区域查询失败", logger.String("area", r.area.ID), logger.Error(r.err))
+	}
+	if len(failures) == len(results) {
+		return nil, nil, crawlerError(errors.Join(failures...))
+	}
+	return results, meta, nil
+}
+
+// Rooms 列出可以预约座位的房间
+func (g *grabberService) Rooms(ctx context.Context, client *http.Client) ([]response.Room, error) {
+	ctx, cancel := withDeadline(ctx, g.deadline.Search)
+	defer cancel()
+	return g.topo.Rooms(ctx, client)
+}
+
 // FindVacantSeats 寻找空闲座位
 // keyword 模糊匹配关键字，为空则返回所有空闲座位
-func (g *grabberService) FindVacantSeats(client *http.Client, startTime, endTime, keyWord string, isTomorrow bool) ([]response.Seat, error) {
+func (g *grabberService) FindVacantSeats(ctx context.Context, client *http.Client, startTime, endTime, keyWord string, date time.Time, filter RoomFilter) ([]response.Seat, *response.Meta, error) {
+	if err := g.topo.CheckDate(date); err != nil {
+		return nil, nil, err
+	}
 	vacantSeats := make([]response.Seat, 0)
-	dateTime := time.Now()
-	if isTomorrow {
-		dateTime = dateTime.Add(time.Hour * 24)
+	ctx, cancel := withDeadline(ctx, g.deadline.Search)
+	defer cancel()
+	results, meta, err := g.scanAreas(ctx, client, date, filter)
+	if err != nil {
+		return nil, nil, err
 	}
-	year, month, day := dateTime.Date()
-	for _, area := range Areas {
-		bodyBytes, err := crawler.FetchSearchUrl(client, area, year, int(month), day, "8:00", "22:00")
-		if err != nil {
-			return nil, errs.CrawlerServerError(err)
-		}
-		var bodyData response.SearchResp
-		if err = json.Unmarshal(bodyBytes, &bodyData); err != nil {
-			return nil, errs.InternalServerError(err)
-		}
 
-		keyWord = strings.ToUpper(keyWord)
-		for _, locationInfo := range bodyData.Data {
+	keyWord = strings.ToUpper(keyWord)
+	for _, r := range results {
+		for _, locationInfo := range r.seats {
 			if !strings.Contains(locationInfo.Title, keyWord) && keyWord != "" {
 				continue
 			}
@@ -107,24 +225,20 @@ func (g *grabberService) FindVacantSeats(client *http.Client, startTime, endTime
 			}
 		}
 	}
-	return vacantSeats, nil
+	return vacantSeats, meta, nil
 }
 
 // IsInLibrary 当前是否在图书馆
-func (g *grabberService) IsInLibrary(client *http.Client, name string) (*response.Occupant, error) {
-	dateTime := time.Now()
-	year, month, day := dateTime.Date()
-	for _, area := range Areas {
-		bodyBytes, err := crawler.FetchSearchUrl(client, area, year, int(month), day, "8:00", "22:00")
-		if err != nil {
-			return nil, errs.CrawlerServerError(err)
-		}
-		var bodyData response.SearchResp
-		if err = json.Unmarshal(bodyBytes, &bodyData); err != nil {
-			return nil, errs.InternalServerError(err)
-		}
+func (g *grabberService) IsInLibrary(ctx context.Context, client *http.Client, name string, filter RoomFilter) (*response.Occupant, *response.Meta, error) {
+	ctx, cancel := withDeadline(ctx, g.deadline.Search)
+	defer cancel()
+	results, meta, err := g.scanAreas(ctx, client, time.Now(), filter)
+	if err != nil {
+		return nil, nil, err
+	}
 
-		for _, locationInfo := range bodyData.Data {
+	for _, r := range results {
+		for _, locationInfo := range r.seats {
 			for _, t := range locationInfo.Ts {
 				if t.Owner == name {
 					return &response.Occupant{
@@ -133,7 +247,7 @@ func (g *grabberService) IsInLibrary(client *http.Client, name string) (*respons
 						State: State[t.State],
 						Start: t.Start[len(t.Start)-5:],
 						End:   t.End[len(t.End)-5:],
-					}, nil
+					}, meta, nil
 				}
 			}
 		}
@@ -145,141 +259,408 @@ func (g *grabberService) IsInLibrary(client *http.Client, name string) (*respons
 		State: State["notfound"],
 		Start: "",
 		End:   "",
-	}, nil
+	}, meta, nil
 }
 
 // SeatToName 座位号转姓名: 查看该座位的预约信息，可以看到预约人是谁
-func (g *grabberService) SeatToName(client *http.Client, seatName string, isTomorrow bool) ([]response.Ts, error) {
-	dateTime := time.Now()
-	if isTomorrow {
-		dateTime = dateTime.Add(time.Hour * 24)
-	}
-	year, month, day := dateTime.Date()
-	for _, area := range Areas {
-		bodyBytes, err := crawler.FetchSearchUrl(client, area, year, int(month), day, "8:00", "22:00")
-		if err != nil {
-			return nil, errs.CrawlerServerError(err)
-		}
-		var bodyData response.SearchResp
-		if err = json.Unmarshal(bodyBytes, &bodyData); err != nil {
-			return nil, errs.InternalServerError(err)
-		}
-		for _, locationInfo := range bodyData.Data {
+func (g *grabberService) SeatToName(ctx context.Context, client *http.Client, seatName string, date time.Time, filter RoomFilter) ([]response.Ts, *response.Meta, error) {
+	if err := g.topo.CheckDate(date); err != nil {
+		return nil, nil, err
+	}
+	ctx, cancel := withDeadline(ctx, g.deadline.Search)
+	defer cancel()
+	results, meta, err := g.scanAreas(ctx, client, date, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, r := range results {
+		for _, locationInfo := range r.seats {
 			if locationInfo.Title == seatName {
-				return locationInfo.Ts, nil
+				return locationInfo.Ts, meta, nil
 			}
 		}
 	}
-	return nil, nil
+	return nil, meta, nil
 }
 
-// Grab 预约座位
-func (g *grabberService) Grab(client *http.Client, seatID, startTime, endTime string, isTomorrow bool) (bool, error) {
-	dateTime := time.Now()
-	if isTomorrow {
-		dateTime = dateTime.Add(time.Hour * 24)
+// ResolveSeat 把座位名称或设备ID解析为座位信息
+// 座位名称在索引过期或找不到时先重新查询一次所有区域，全部是数字的设备ID原样返回
+func (g *grabberService) ResolveSeat(ctx context.Context, client *http.Client, seat string) (*response.SeatDevice, error) {
+	// 座位ID不会变化，索引过期时也可以直接使用
+	if isDevID(seat) || !g.devicesStale() {
+		if dev, ok := g.devices.GetDevid(seat); ok {
+			return &dev, nil
+		}
 	}
-	year, month, day := dateTime.Date()
+	if isDevID(seat) {
+		// 索引中还没有这个座位ID时直接交给 kjyy 校验，不需要查询所有区域
+		return &response.SeatDevice{DevId: seat}, nil
+	}
+	ctx, cancel := withDeadline(ctx, g.deadline.Search)
+	defer cancel()
+	if _, _, err := g.scanAreas(ctx, client, time.Now(), RoomFilter{}); err != nil {
+		return nil, err
+	}
+	dev, ok := g.devices.GetDevid(seat)
+	if !ok {
+		return nil, errs.SeatNotFoundError(fmt.Errorf("seat %s not found", seat))
+	}
+	return &dev, nil
+}
 
-	bodyBytes, err := crawler.FetchGrabUrl(client, seatID, year, int(month), day, startTime, endTime)
+// isDevID 座位ID全部是数字，座位名称以字母开头，例如 N1224
+func isDevID(seat string) bool {
+	if seat == "" {
+		return false
+	}
+	for _, c := range seat {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// SearchSeats 按关键词查找座位名称索引，索引过期时先重新查询所有区域
+// 指定过滤条件时只返回符合条件的房间中的座位
+func (g *grabberService) SearchSeats(ctx context.Context, client *http.Client, keyWord string, filter RoomFilter) ([]response.SeatDevice, error) {
+	ctx, cancel := withDeadline(ctx, g.deadline.Search)
+	defer cancel()
+	if g.devicesStale() {
+		if _, _, err := g.scanAreas(ctx, client, time.Now(), RoomFilter{}); err != nil {
+			return nil, err
+		}
+	}
+	devices := g.devices.Search(keyWord)
+	if filter == (RoomFilter{}) {
+		return devices, nil
+	}
+	rooms, err := g.topo.Select(ctx, client, filter)
 	if err != nil {
-		return false, errs.CrawlerServerError(err)
+		return nil, err
+	}
+	selected := make(map[string]bool, len(rooms))
+	for _, room := range rooms {
+		selected[room.ID] = true
+	}
+	res := make([]response.SeatDevice, 0, len(devices))
+	for _, dev := range devices {
+		if selected[dev.Room] {
+			res = append(res, dev)
+		}
+	}
+	return res, nil
+}
+
+// devicesStale 座位名称索引中是否有区域需要刷新
+func (g *grabberService) devicesStale() bool {
+	for _, room := range g.topo.RoomIDs() {
+		if time.Since(g.devices.UpdatedAt(room)) > time.Duration(g.search.DeviceRefresh)*time.Second {
+			return true
+		}
+	}
+	return false
+}
+
+// RefreshDevices 使用 cookie 池中任意一个有效的会话重新查询所有区域，刷新座位名称索引
+// cookie 池为空时跳过，等下一次查询座位时再刷新
+func (g *grabberService) RefreshDevices(ctx context.Context) {
+	if !g.devicesStale() {
+		return
+	}
+	var client *http.Client
+	now := time.Now()
+	g.mu.RLock()
+	for _, e := range g.cookiePool {
+		if now.Before(e.expire) {
+			client = e.client
+			break
+		}
+	}
+	g.mu.RUnlock()
+	if client == nil {
+		return
+	}
+
+	ctx, cancel := withDeadline(ctx, g.deadline.Search)
+	defer cancel()
+	if _, _, err := g.scanAreas(ctx, client, now, RoomFilter{}); err != nil {
+		g.log.Warn("刷新座位名称索引失败", logger.Error(err))
+	}
+}
+
+// Grab 预约座位
+func (g *grabberService) Grab(ctx context.Context, client *http.Client, seatID, startTime, endTime string, date time.Time) (bool, error) {
+	if err := g.topo.CheckDate(date); err != nil {
+		return false, err
 	}
-	var respMap map[string]interface{}
-	err = json.Unmarshal(bodyBytes, &respMap)
+
+	ctx, cancel := withDeadline(ctx, g.deadline.Grab)
+	defer cancel()
+	ar, err := g.backend.Reserve(ctx, client, seatID, date, startTime, endTime)
 	if err != nil {
-		return false, errs.InternalServerError(err)
+		return false, crawlerError(err)
 	}
 	// success {"ret":1,"act":"set_resv","msg":"操作成功！","data":null,"ext":null}
-	if msg, ok := respMap["msg"].(string); ok && strings.Contains(msg, "操作成功") {
+	if strings.Contains(ar.Msg, "操作成功") {
+		g.snapshots.Invalidate(date)
+		// 上游返回成功不代表一定预约到了，以个人预约记录为准
+		if _, err = g.GrabSuccess(ctx, client, seatID, startTime, endTime, date); err != nil {
+			return false, err
+		}
 		return true, nil
 	} else {
-		return false, errs.GrabSeatError(errors.New(msg))
+		return false, errs.GrabSeatError(errors.New(ar.Msg))
 	}
 }
 
-// GrabSuccess 预约是否成功
-func (g *grabberService) GrabSuccess(client *http.Client) (bool, error) {
-	bodyBytes, err := crawler.FetchPersonUrl(client)
+// AutoGrab 自动选座：查找符合条件的空座位，按优先级依次尝试预约，直到有一个成功
+func (g *grabberService) AutoGrab(ctx context.Context, client *http.Client, startTime, endTime, keyWord string, date time.Time, filter RoomFilter) (*response.GrabResult, error) {
+	// 整个自动选座过程共用一个超时时间，超时后不再尝试后面的座位
+	ctx, cancel := withDeadline(ctx, g.deadline.Grab)
+	defer cancel()
+	seats, _, err := g.FindVacantSeats(ctx, client, startTime, endTime, keyWord, date, filter)
 	if err != nil {
-		return false, errs.CrawlerServerError(err)
+		return nil, err
+	}
+	if len(seats) == 0 {
+		return nil, errs.GrabSeatError(errors.New("没有符合条件的空座位"))
 	}
+	rankSeats(seats)
+
+	attempts := 0
+	for _, seat := range seats {
+		if attempts >= autoGrabMaxAttempts || ctx.Err() != nil {
+			break
+		}
+		attempts++
+		ok, gErr := g.Grab(ctx, client, seat.DevId, startTime, endTime, date)
+		if ok {
+			return &response.GrabResult{
+				SeatID:   seat.DevId,
+				Title:    seat.Title,
+				Attempts: attempts,
+			}, nil
+		}
+		// 座位可能在查询之后被别人抢走，继续尝试下一个
+		err = gErr
+		g.log.Info("自动选座预约失败，尝试下一个座位",
+			logger.String("seat", seat.Title),
+			logger.Int("attempt", attempts),
+			logger.Error(gErr),
+		)
+	}
+	return nil, err
+}
+
+// rankSeats 对候选座位排序：当天已有预约越少的座位越安静、越不容易冲突，优先尝试；
+// 相同时按座位名称排序，保证结果稳定
+func rankSeats(seats []response.Seat) {
+	sort.SliceStable(seats, func(i, j int) bool {
+		if len(seats[i].Ts) != len(seats[j].Ts) {
+			return len(seats[i].Ts) < len(seats[j].Ts)
+		}
+		return seats[i].Title < seats[j].Title
+	})
+}
+
+// GrabSuccess 预约是否成功：个人预约记录中有这个座位和时间段的预约，没有时返回 GrabUnconfirmedError
+// seatID 可以是座位名称或座位ID，座位索引中没有这个座位ID时只比较时间段，同一时间段每人只能有一个预约
+func (g *grabberService) GrabSuccess(ctx context.Context, client *http.Client, seatID, startTime, endTime string, date time.Time) (bool, error) {
+	title := seatID
+	if dev, ok := g.devices.GetDevid(seatID); ok {
+		title = dev.Title
+	} else if isDevID(seatID) {
+		title = ""
+	}
+	day := date.Format("2006-01-02")
+	start, err1 := time.ParseInLocation("2006-01-02 15:04", day+" "+startTime, time.Local)
+	end, err2 := time.ParseInLocation("2006-01-02 15:04", day+" "+endTime, time.Local)
+	if err := errors.Join(err1, err2); err != nil {
+		return false, errs.GetHistoryError(err)
+	}
+
+	ctx, cancel := withDeadline(ctx, g.deadline.Search)
+	defer cancel()
+	records, err := g.history(ctx, client)
+	if err != nil {
+		return false, err
+	}
+	for _, r := range records {
+		if (title == "" || r.Title == title) && r.Start.Equal(start) && r.End.Equal(end) && r.Has(crawler.StateReserved) {
+			return true, nil
+		}
+	}
+	return false, errs.GrabUnconfirmedError(fmt.Errorf("no reservation of %s %s-%s", seatID, startTime, endTime))
+}
 
-	var respMap map[string]interface{}
-	if err = json.Unmarshal(bodyBytes, &respMap); err != nil {
-		return false, errs.InternalServerError(err)
+// MyReservations 获取个人预约记录
+func (g *grabberService) MyReservations(ctx context.Context, client *http.Client) ([]response.Reservation, error) {
+	ctx, cancel := withDeadline(ctx, g.deadline.Search)
+	defer cancel()
+	records, err := g.history(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]response.Reservation, 0, len(records))
+	for _, r := range records {
+		res = append(res, response.Reservation{
+			RsvID:     r.RsvID,
+			Title:     r.Title,
+			Room:      r.Room,
+			Owner:     r.Owner,
+			Start:     r.Start.Format("2006-01-02 15:04"),
+			End:       r.End.Format("2006-01-02 15:04"),
+			CreatedAt: r.CreatedAt.Format("2006-01-02 15:04"),
+			Reserved:  r.Has(crawler.StateReserved),
+			Effected:  r.Has(crawler.StateEffected),
+			Approved:  r.Has(crawler.StateApproved),
+			States:    r.States,
+		})
 	}
+	return res, nil
+}
 
+// history 获取并解析个人预约记录
+func (g *grabberService) history(ctx context.Context, client *http.Client) ([]crawler.HistoryRecord, error) {
 	// success {
 	//    "ret": 1,
 	//    "act": "get_History_resv",
-	//    "msg": "<tbody date='2025-11-24 14:00' state='4482' over='false'><tr class='head'><td colspan='6'><h3></h3><span><span class='orange uni_trans'>预约成功</span></span><span class='pull-right'><span class='grey'>2025-11-24 14:00</span></span></td></tr><tr class='content'><td><div class='box'><a>N1245</a><div class='grey'>南湖分馆一楼</div</div></td><td>姜高峰</td><td style='max-width:300px'><span class='grey'>个人预约</span></td><td><div><div><span class='grey'>开始:</span> <span class='text-primary'>11-24 19:00</span></div><div><span class='grey'>结束:</span> <span class='text-primary'>11-24 22:00</span></div></div></td><td><div><span style='color:green' class='uni_trans'>预约成功</span>,<span style='color:orange' class='uni_trans'>未生效</span>,<span style='color:green' class='uni_trans'>审核通过</span></div><div style='font-size:12px;color:#777;'></div></td><td class='text-center' style='vertical-align: middle;'><a class='click' rsvId='175901668' onclick='delRsv(this);'>取消</a></td></tr></tbody>",
+	//    "msg": "<tbody date='2025-11-24 14:00' state='4482' over='false'><tr class='head'>...</tr><tr class='content'>...<a class='click' rsvId='175901668' onclick='delRsv(this);'>取消</a></td></tr></tbody>",
 	//    "data": null,
 	//    "ext": null
 	//}
-	// failure {"ret":1,"act":"get_History_resv","msg":"<tbody><tr><td colspan='6' class='text-center'>没有数据</td></tr></tbody>","data":null,"ext":null}
-	if msg, ok := respMap["msg"].(string); ok && strings.Contains(msg, "<tbody") {
-		return true, nil
-	} else {
-		return false, errs.GetHistoryError(errors.New(msg))
+	// 没有预约 {"ret":1,"act":"get_History_resv","msg":"<tbody><tr><td colspan='6' class='text-center'>没有数据</td></tr></tbody>","data":null,"ext":null}
+	ar, err := g.backend.History(ctx, client)
+	if err != nil {
+		return nil, crawlerError(err)
+	}
+	if ar.Ret != 1 {
+		return nil, errs.GetHistoryError(errors.New(ar.Msg))
+	}
+	records, err := crawler.ParseHistory(ar.Msg, time.Now())
+	if err != nil {
+		return nil, errs.GetHistoryError(err)
+	}
+	return records, nil
+}
+
+// CancelReservation 取消预约，取消后重新读取个人预约记录确认已经删除
+func (g *grabberService) CancelReservation(ctx context.Context, client *http.Client, rsvID string, date time.Time) ([]string, error) {
+	ctx, cancel := withDeadline(ctx, g.deadline.Grab)
+	defer cancel()
+	rsvs, err := g.history(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	targets := make([]crawler.HistoryRecord, 0, 1)
+	for _, rsv := range rsvs {
+		if rsv.RsvID == "" {
+			// 已结束的预约不能取消
+			continue
+		}
+		if (rsvID != "" && rsv.RsvID == rsvID) || (rsvID == "" && today(rsv.Start).Equal(today(date))) {
+			targets = append(targets, rsv)
+		}
+	}
+	if len(targets) == 0 {
+		return nil, errs.ReservationNotFoundError(errors.New("no matching reservation"))
+	}
+
+	for _, rsv := range targets {
+		ar, err := g.backend.CancelReservation(ctx, client, rsv.RsvID)
+		if err != nil {
+			return nil, crawlerError(err)
+		}
+		if !strings.Contains(ar.Msg, "操作成功") {
+			return nil, errs.CancelReservationError(fmt.Errorf("rsv %s: %s", rsv.RsvID, ar.Msg))
+		}
+		g.snapshots.Invalidate(rsv.Start)
+	}
+
+	// 重新读取预约记录，确认已经取消
+	rsvs, err = g.history(ctx, client)
+	if err != nil {
+		return nil, err
 	}
+	remaining := make(map[string]bool, len(rsvs))
+	for _, rsv := range rsvs {
+		remaining[rsv.RsvID] = true
+	}
+	canceled := make([]string, 0, len(targets))
+	for _, rsv := range targets {
+		if remaining[rsv.RsvID] {
+			return nil, errs.CancelReservationError(fmt.Errorf("rsv %s still exists after cancel", rsv.RsvID))
+		}
+		canceled = append(canceled, rsv.RsvID)
+	}
+	return canceled, nil
 }
 
 // GetClient 获取或创建带有有效 cookie 的 http.Client
-func (g *grabberService) GetClient(username, password string) (*http.Client, error) {
-	// 先用读锁快速检查
+func (g *grabberService) GetClient(ctx context.Context, username string) (*http.Client, error) {
 	g.mu.RLock()
 	entry, ok := g.cookiePool[username]
 	g.mu.RUnlock()
 	if ok && entry != nil && time.Now().Before(entry.expire) {
-		validate, _ := g.validateClient(entry.client)
-		if validate {
+		if validate, _ := g.validateClient(ctx, entry.client); validate {
+			poolHits.Inc()
 			return entry.client, nil
-		} else {
-			// client 中的 cookie 无效，跳转到刷新流程
-			goto refresh
 		}
-	}
-
-	// 升级到写锁 double-check
-	g.mu.Lock()
-	entry, ok = g.cookiePool[username]
-	g.mu.Unlock()
-	if ok && entry != nil && time.Now().Before(entry.expire) {
-		validate, _ := g.validateClient(entry.client)
-		if validate {
-			return entry.client, nil
-		} else {
-			// client 中的 cookie 无效，跳转到刷新流程
-			goto refresh
+		// client 中的 cookie 无效，重新登录
+		poolValidateFailures.Inc()
+		poolEvictions.WithLabelValues(evictInvalid).Inc()
+	} else {
+		poolMisses.Inc()
+		if ok && entry != nil {
+			poolEvictions.WithLabelValues(evictExpired).Inc()
 		}
 	}
 
-refresh:
-	// 需要创建或刷新
-	newClient, err := g.getLibraryClient(username, password)
+	// 需要创建或刷新，从凭据库中读取密码重新登录
+	password, err := g.vault.Credential(ctx, username)
+	if errors.Is(err, data.ErrNotFound) {
+		return nil, errs.UnauthorizedError(err)
+	}
 	if err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	newClient, err := g.getLibraryClient(ctx, username, password)
+	if err != nil {
+		poolRefreshes.WithLabelValues("failure").Inc()
 		// 这里的错误已经是封装好的错误类型，直接返回
 		return nil, err
 	}
-
-	// 关闭旧 client 的 idle connections
-	if ok && entry != nil && entry.client != nil {
-		if tr, ok := entry.client.Transport.(*http.Transport); ok {
-			tr.CloseIdleConnections()
-		}
-	}
+	poolRefreshes.WithLabelValues("success").Inc()
 
 	g.mu.Lock()
+	old := g.cookiePool[username]
 	g.cookiePool[username] = &clientEntry{
 		client: newClient,
 		expire: time.Now().Add(g.ttl),
 	}
+	poolSize.Set(float64(len(g.cookiePool)))
 	g.mu.Unlock()
 
+	// 关闭旧 client 的 idle connections
+	closeIdle(old)
 	return newClient, nil
 }
 
+// SeedSession 把登录时得到的 client 放入 cookie 池，替换该用户原有的 client
+func (g *grabberService) SeedSession(userID string, client *http.Client) {
+	g.mu.Lock()
+	old := g.cookiePool[userID]
+	g.cookiePool[userID] = &clientEntry{
+		client: client,
+		expire: time.Now().Add(g.ttl),
+	}
+	poolSize.Set(float64(len(g.cookiePool)))
+	g.mu.Unlock()
+
+	closeIdle(old)
+}
+
 // CleanupExpired 用于优雅关闭：关闭所有 client 的空闲连接（不关闭正在使用的连接）
 func (g *grabberService) CleanupExpired() {
 	g.mu.Lock()
@@ -291,62 +672,122 @@ func (g *grabberService) CleanupExpired() {
 			continue
 		}
 		if e.expire.Before(now) {
-			if e.client != nil {
-				if tr, ok := e.client.Transport.(*http.Transport); ok {
-					tr.CloseIdleConnections()
-				}
-			}
+			closeIdle(e)
 			delete(g.cookiePool, k)
+			poolEvictions.WithLabelValues(evictExpired).Inc()
 		}
 	}
+	poolSize.Set(float64(len(g.cookiePool)))
 }
 
-// GetLibraryClient 登录图书馆
-func (g *grabberService) getLibraryClient(username, password string) (*http.Client, error) {
-	jar, err := cookiejar.New(nil)
-	if err != nil {
-		return nil, errs.InternalServerError(err)
+// PoolSessions 列出 cookie 池中缓存的会话，只返回学号和过期时间
+func (g *grabberService) PoolSessions() []response.PoolSession {
+	g.mu.RLock()
+	sessions := make([]response.PoolSession, 0, len(g.cookiePool))
+	for userID, e := range g.cookiePool {
+		if e == nil {
+			continue
+		}
+		sessions = append(sessions, response.PoolSession{
+			UserID:   userID,
+			ExpireAt: e.expire.Format(time.DateTime),
+			Expired:  time.Now().After(e.expire),
+		})
+	}
+	g.mu.RUnlock()
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].UserID < sessions[j].UserID
+	})
+	return sessions
+}
+
+// EvictSession 从 cookie 池中移除某个用户，下次请求时重新登录
+func (g *grabberService) EvictSession(userID string) error {
+	g.mu.Lock()
+	e, ok := g.cookiePool[userID]
+	delete(g.cookiePool, userID)
+	poolSize.Set(float64(len(g.cookiePool)))
+	g.mu.Unlock()
+	if !ok {
+		return errs.PoolSessionNotFoundError(fmt.Errorf("user %s not in cookie pool", userID))
 	}
-	client := &http.Client{
-		Jar:       jar,
-		Timeout:   15 * time.Second,
-		Transport: &http.Transport{},
+	closeIdle(e)
+	poolEvictions.WithLabelValues(evictAdmin).Inc()
+	return nil
+}
+
+// FlushSessions 清空 cookie 池，返回被移除的会话数
+func (g *grabberService) FlushSessions() int {
+	g.mu.Lock()
+	pool := g.cookiePool
+	g.cookiePool = make(map[string]*clientEntry)
+	poolSize.Set(0)
+	g.mu.Unlock()
+
+	for _, e := range pool {
+		closeIdle(e)
 	}
+	poolEvictions.WithLabelValues(evictFlush).Add(float64(len(pool)))
+	return len(pool)
+}
 
-	bodyBytes, err := crawler.FetchLibraryLoginUrl(client, username, password)
-	if err != nil {
-		return nil, errs.CrawlerServerError(err)
+// closeIdle 关闭 client 的空闲连接
+func closeIdle(e *clientEntry) {
+	if e == nil || e.client == nil {
+		return
 	}
-	doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(bodyBytes)))
+	if tr, ok := e.client.Transport.(*http.Transport); ok {
+		tr.CloseIdleConnections()
+	}
+}
+
+// GetLibraryClient 登录图书馆
+func (g *grabberService) getLibraryClient(ctx context.Context, username, password string) (*http.Client, error) {
+	client := g.clients.New()
+
+	ctx, cancel := withDeadline(ctx, g.deadline.Login)
+	defer cancel()
+	lr, err := g.backend.LoginLibrary(ctx, client, username, password)
 	if err != nil {
-		return nil, errs.InternalServerError(err)
+		return nil, crawlerError(err)
 	}
-	errMsg := strings.TrimSpace(doc.Find("div#msg.errors").Text())
-	if strings.Contains(errMsg, "您输入的用户名或密码有误") {
-		return nil, errs.UserIdOrPasswordError(errors.New(errMsg))
+	if strings.Contains(lr.ErrMsg, "您输入的用户名或密码有误") {
+		return nil, errs.UserIdOrPasswordError(errors.New(lr.ErrMsg))
 	}
 	// 返回已带 cookie 的 client，调用方可以直接使用 client.Jar.Cookies(...)
 	return client, nil
 }
 
 // validateClient 状态验证，判断 cookie 是否有效
-func (g *grabberService) validateClient(client *http.Client) (bool, error) {
-	bodyBytes, err := crawler.FetchPersonUrl(client)
+func (g *grabberService) validateClient(ctx context.Context, client *http.Client) (bool, error) {
+	ctx, cancel := withDeadline(ctx, g.deadline.Search)
+	defer cancel()
+	ar, err := g.backend.History(ctx, client)
 	if err != nil {
-		return false, errs.CrawlerServerError(err)
+		return false, crawlerError(err)
 	}
 
-	// 尝试解析为 JSON，检查 msg 字段
-	var m map[string]interface{}
-	err = json.Unmarshal(bodyBytes, &m)
-	if err != nil {
-		return false, errs.InternalServerError(err)
-	}
-	if msg, ok := m["msg"].(string); ok {
-		if strings.Contains(msg, "未登录") || strings.Contains(msg, "登录超时") || strings.Contains(msg, "session =null") {
-			return false, errs.UnauthorizedError(errors.New(msg))
-		}
+	// 检查 msg 字段
+	if crawler.IsSessionExpired(ar.Msg) {
+		return false, errs.UnauthorizedError(errors.New(ar.Msg))
 	}
 
 	return true, nil
 }
+
+// withDeadline 在 ctx 上设置 seconds 秒的超时时间，ctx 本身的截止时间更早时以 ctx 为准
+func withDeadline(ctx context.Context, seconds int) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, time.Duration(seconds)*time.Second)
+}
+
+// crawlerError 封装访问图书馆失败的错误，超时返回 504，熔断中返回 503
+func crawlerError(err error) error {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return errs.UpstreamTimeoutError(err)
+	case errors.Is(err, crawler.ErrCircuitOpen):
+		return errs.UpstreamUnavailableError(err)
+	}
+	return errs.CrawlerServerError(err)
+}
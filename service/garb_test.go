@@ -0,0 +1,436 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Serendipity565/GrabSeat/api/response"
+	"github.com/Serendipity565/GrabSeat/config"
+	"github.com/Serendipity565/GrabSeat/internal/data"
+	"github.com/Serendipity565/GrabSeat/internal/fakekjyy"
+	"github.com/Serendipity565/GrabSeat/pkg/errorx"
+	"github.com/Serendipity565/GrabSeat/pkg/logger"
+	"github.com/Serendipity565/GrabSeat/service/crawler"
+	mockservice "github.com/Serendipity565/GrabSeat/service/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// testBurstConfig 测试中只采样一次，避免校准时钟耗时过长
+var testBurstConfig = &config.BurstConfig{Attempts: 3, IntervalMs: 50, SyncSamples: 1, MaxWait: 10}
+
+var testSearchConfig = &config.SearchConfig{Parallelism: 2, SnapshotTTL: 10}
+
+var testDeadlineConfig = &config.DeadlineConfig{Search: 5, Grab: 5, Login: 5, Exchange: 5}
+
+var testClients, _ = crawler.NewClientFactory(&config.LibraryConfig{Timeout: 5})
+
+// testRooms 测试用的房间，前两个在一楼，后两个在二楼
+var testRooms = []string{"101699191", "101699189", "101699187", "101699179"}
+
+var testTopologyConfig = &config.TopologyConfig{
+	OpenTime:         "8:00",
+	CloseTime:        "22:00",
+	HorizonDays:      1,
+	QueueDays:        5,
+	DiscoverInterval: 3600,
+	Rooms: []config.RoomConfig{
+		{ID: testRooms[0], Floor: "1F", OpenTime: "8:00", CloseTime: "22:00"},
+		{ID: testRooms[1], Floor: "1F", OpenTime: "8:00", CloseTime: "22:00"},
+		{ID: testRooms[2], Floor: "2F", OpenTime: "8:00", CloseTime: "22:00"},
+		{ID: testRooms[3], Floor: "2F", OpenTime: "8:00", CloseTime: "22:00"},
+	},
+}
+
+// memVault 测试用的内存凭据库
+type memVault struct {
+	mu    sync.Mutex
+	creds map[string]string
+}
+
+func newMemVault() *memVault {
+	return &memVault{creds: make(map[string]string)}
+}
+
+func (v *memVault) CreateSession(_ context.Context, userID, password string) (string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.creds[userID] = password
+	return userID, nil
+}
+
+func (v *memVault) Session(_ context.Context, sessionID string) (string, error) {
+	return sessionID, nil
+}
+
+func (v *memVault) Credential(_ context.Context, userID string) (string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	password, ok := v.creds[userID]
+	if !ok {
+		return "", data.ErrNotFound
+	}
+	return password, nil
+}
+
+func (v *memVault) ExtendSession(context.Context, string) error {
+	return nil
+}
+
+func (v *memVault) DeleteSession(context.Context, string) error {
+	return nil
+}
+
+func newTestGrabber(t *testing.T) (*mockservice.MockLibraryBackend, GrabberService) {
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	log := logger.NewZapLogger(zap.NewNop())
+	backend := mockservice.NewMockLibraryBackend(ctrl)
+	return backend, newGrabber(log, backend, newMemVault())
+}
+
+func newGrabber(log logger.Logger, backend crawler.LibraryBackend, vault data.CredentialVault) GrabberService {
+	topo := NewTopology(testTopologyConfig, backend, log)
+	snapshots := NewSnapshotCache(testSearchConfig, topo, nil, nil, log)
+	return NewGrabberService(log, backend, vault, testBurstConfig, testSearchConfig, testDeadlineConfig, snapshots, topo, testClients, prometheus.NewRegistry())
+}
+
+func TestGrabberService_FindVacantSeats(t *testing.T) {
+	ctx := context.Background()
+	backend, gs := newTestGrabber(t)
+
+	seats := []response.Seat{
+		{Title: "N1224", DevId: "1", Ts: []response.Ts{{Start: "2025-11-24 08:00", End: "2025-11-24 12:00"}}},
+		{Title: "N1225", DevId: "2", Ts: []response.Ts{{Start: "2025-11-24 14:00", End: "2025-11-24 16:00"}}},
+		{Title: "S2001", DevId: "3"},
+	}
+	backend.EXPECT().SearchRoomStatus(gomock.Any(), gomock.Any(), testRooms[0], gomock.Any(), "8:00", "22:00").Return(seats, nil)
+	backend.EXPECT().SearchRoomStatus(gomock.Any(), gomock.Any(), gomock.Not(testRooms[0]), gomock.Any(), "8:00", "22:00").Return(nil, nil).Times(len(testRooms) - 1)
+
+	got, meta, err := gs.FindVacantSeats(ctx, &http.Client{}, "10:00", "13:00", "n12", time.Now(), RoomFilter{})
+	if err != nil {
+		t.Fatalf("期望没有错误，实际: %v", err)
+	}
+	if len(meta.Warnings) != 0 {
+		t.Fatalf("期望没有警告，实际: %+v", meta)
+	}
+	if len(got) != 1 || got[0].Title != "N1225" {
+		t.Fatalf("期望只返回 N1225，实际: %+v", got)
+	}
+}
+
+func TestGrabberService_FindVacantSeats_PartialFailure(t *testing.T) {
+	ctx := context.Background()
+	backend, gs := newTestGrabber(t)
+
+	seats := []response.Seat{{Title: "N1224", DevId: "1"}}
+	backend.EXPECT().SearchRoomStatus(gomock.Any(), gomock.Any(), testRooms[0], gomock.Any(), "8:00", "22:00").Return(nil, errors.New("timeout"))
+	backend.EXPECT().SearchRoomStatus(gomock.Any(), gomock.Any(), testRooms[1], gomock.Any(), "8:00", "22:00").Return(seats, nil)
+	backend.EXPECT().SearchRoomStatus(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), "8:00", "22:00").Return(nil, nil).Times(len(testRooms) - 2)
+
+	got, meta, err := gs.FindVacantSeats(ctx, &http.Client{}, "10:00", "13:00", "", time.Now(), RoomFilter{})
+	if err != nil {
+		t.Fatalf("期望部分区域失败时不返回错误，实际: %v", err)
+	}
+	if len(got) != 1 || meta == nil || len(meta.Warnings) != 1 {
+		t.Fatalf("期望返回 N1224 和一条警告，实际: %+v, %+v", got, meta)
+	}
+
+	// 所有区域都失败时返回错误
+	backend, gs = newTestGrabber(t)
+	backend.EXPECT().SearchRoomStatus(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), "8:00", "22:00").Return(nil, errors.New("timeout")).Times(len(testRooms))
+	if _, _, err = gs.FindVacantSeats(ctx, &http.Client{}, "10:00", "13:00", "", time.Now(), RoomFilter{}); err == nil {
+		t.Fatalf("期望所有区域失败时返回错误")
+	}
+}
+
+// historyOf 返回只有一条预约记录的 get_History_resv 响应
+func historyOf(title string, date time.Time, startTime, endTime string) *crawler.ActResp {
+	day := date.Format("01-02")
+	msg := "<tbody date='2025-11-24 14:00' state='4482' over='false'><tr class='content'>" +
+		"<td><div class='box'><a>" + title + "</a><div class='grey'>南湖分馆一楼</div></div></td><td>张三</td><td><span class='grey'>个人预约</span></td>" +
+		"<td><span class='text-primary'>" + day + " " + startTime + "</span><span class='text-primary'>" + day + " " + endTime + "</span></td>" +
+		"<td><div><span class='uni_trans'>预约成功</span>,<span class='uni_trans'>未生效</span></div></td>" +
+		"<td><a class='click' rsvId='1' onclick='delRsv(this);'>取消</a></td></tr></tbody>"
+	return &crawler.ActResp{Ret: 1, Act: "get_History_resv", Msg: msg}
+}
+
+func TestGrabberService_Grab(t *testing.T) {
+	ctx := context.Background()
+	backend, gs := newTestGrabber(t)
+	tomorrow := time.Now().AddDate(0, 0, 1)
+
+	gomock.InOrder(
+		backend.EXPECT().Reserve(gomock.Any(), gomock.Any(), "101", gomock.Any(), "08:00", "10:00").
+			Return(&crawler.ActResp{Ret: 1, Act: "set_resv", Msg: "操作成功！"}, nil),
+		backend.EXPECT().History(gomock.Any(), gomock.Any()).Return(historyOf("N1224", tomorrow, "08:00", "10:00"), nil),
+		backend.EXPECT().Reserve(gomock.Any(), gomock.Any(), "102", gomock.Any(), "08:00", "10:00").
+			Return(&crawler.ActResp{Ret: 0, Act: "set_resv", Msg: "该时间段已被预约"}, nil),
+	)
+
+	if ok, err := gs.Grab(ctx, &http.Client{}, "101", "08:00", "10:00", tomorrow); !ok || err != nil {
+		t.Fatalf("期望预约成功，实际: %v, %v", ok, err)
+	}
+	if ok, err := gs.Grab(ctx, &http.Client{}, "102", "08:00", "10:00", tomorrow); ok || err == nil {
+		t.Fatalf("期望预约失败，实际: %v, %v", ok, err)
+	}
+
+	// 上游返回成功，但预约记录中没有这条预约
+	gomock.InOrder(
+		backend.EXPECT().Reserve(gomock.Any(), gomock.Any(), "104", gomock.Any(), "08:00", "10:00").
+			Return(&crawler.ActResp{Ret: 1, Act: "set_resv", Msg: "操作成功！"}, nil),
+		backend.EXPECT().History(gomock.Any(), gomock.Any()).Return(historyOf("N1224", tomorrow, "14:00", "16:00"), nil),
+	)
+	ok, err := gs.Grab(ctx, &http.Client{}, "104", "08:00", "10:00", tomorrow)
+	if ok || errorx.ToCustomError(err).HttpCode != http.StatusBadGateway {
+		t.Fatalf("期望预约记录校验失败，实际: %v, %v", ok, err)
+	}
+
+	// 上游请求随 ctx 一起超时，返回 504
+	backend.EXPECT().Reserve(gomock.Any(), gomock.Any(), "103", gomock.Any(), "08:00", "10:00").
+		DoAndReturn(func(ctx context.Context, _ *http.Client, _ string, _ time.Time, _, _ string) (*crawler.ActResp, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+	timeout, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := gs.Grab(timeout, &http.Client{}, "103", "08:00", "10:00", time.Now().AddDate(0, 0, 1)); errorx.ToCustomError(err).HttpCode != http.StatusGatewayTimeout {
+		t.Fatalf("期望超时错误，实际: %v", err)
+	}
+}
+
+func TestGrabberService_AutoGrab(t *testing.T) {
+	ctx := context.Background()
+	backend, gs := newTestGrabber(t)
+
+	seats := []response.Seat{
+		{Title: "N1226", DevId: "3", Ts: []response.Ts{{Start: "2025-11-24 18:00", End: "2025-11-24 22:00"}}},
+		{Title: "N1225", DevId: "2"},
+		{Title: "N1224", DevId: "1"},
+	}
+	backend.EXPECT().SearchRoomStatus(gomock.Any(), gomock.Any(), testRooms[0], gomock.Any(), "8:00", "22:00").Return(seats, nil)
+	backend.EXPECT().SearchRoomStatus(gomock.Any(), gomock.Any(), gomock.Not(testRooms[0]), gomock.Any(), "8:00", "22:00").Return(nil, nil).Times(len(testRooms) - 1)
+	// 没有其他预约的座位优先，N1224 被抢走后继续尝试 N1225
+	gomock.InOrder(
+		backend.EXPECT().Reserve(gomock.Any(), gomock.Any(), "1", gomock.Any(), "08:00", "10:00").
+			Return(&crawler.ActResp{Ret: 0, Act: "set_resv", Msg: "[N1224]该时间段已被预约"}, nil),
+		backend.EXPECT().Reserve(gomock.Any(), gomock.Any(), "2", gomock.Any(), "08:00", "10:00").
+			Return(&crawler.ActResp{Ret: 1, Act: "set_resv", Msg: "操作成功！"}, nil),
+		backend.EXPECT().History(gomock.Any(), gomock.Any()).Return(historyOf("N1225", time.Now().AddDate(0, 0, 1), "08:00", "10:00"), nil),
+	)
+
+	res, err := gs.AutoGrab(ctx, &http.Client{}, "08:00", "10:00", "", time.Now().AddDate(0, 0, 1), RoomFilter{})
+	if err != nil {
+		t.Fatalf("期望没有错误，实际: %v", err)
+	}
+	if res.SeatID != "2" || res.Title != "N1225" || res.Attempts != 2 {
+		t.Fatalf("期望第 2 次尝试预约到 N1225，实际: %+v", res)
+	}
+}
+
+func TestGrabberService_BurstGrab(t *testing.T) {
+	ctx := context.Background()
+	backend, gs := newTestGrabber(t)
+
+	// 超出可预约范围的日期在校准时钟之前就被拒绝
+	_, err := gs.BurstGrab(ctx, &http.Client{}, "101", "08:00", "10:00", time.Now().AddDate(0, 0, 2))
+	if ce := errorx.ToCustomError(err); ce.HttpCode != http.StatusBadRequest || ce.Msg != "预约日期错误" {
+		t.Fatalf("期望日期错误，实际: %v", err)
+	}
+
+	// 第一次请求成功后，还在进行中的请求被取消
+	backend.EXPECT().ServerTime(gomock.Any(), gomock.Any()).DoAndReturn(func(context.Context, *http.Client) (time.Time, error) {
+		return time.Now(), nil
+	})
+	var calls atomic.Int32
+	backend.EXPECT().Reserve(gomock.Any(), gomock.Any(), "101", gomock.Any(), "08:00", "10:00").
+		DoAndReturn(func(ctx context.Context, _ *http.Client, _ string, _ time.Time, _, _ string) (*crawler.ActResp, error) {
+			if calls.Add(1) == 1 {
+				time.Sleep(120 * time.Millisecond)
+				return &crawler.ActResp{Ret: 1, Act: "set_resv", Msg: "操作成功！"}, nil
+			}
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}).MinTimes(2)
+	release := time.Now().Add(100 * time.Millisecond)
+	backend.EXPECT().History(gomock.Any(), gomock.Any()).Return(historyOf("N1224", release.AddDate(0, 0, 1), "08:00", "10:00"), nil)
+
+	started := time.Now()
+	res, err := gs.BurstGrab(ctx, &http.Client{}, "101", "08:00", "10:00", release)
+	if err != nil || !res.Success {
+		t.Fatalf("期望抢座成功，实际: %+v, %v", res, err)
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Fatalf("期望成功后立即结束，实际耗时 %s", elapsed)
+	}
+	for _, at := range res.Attempts {
+		if !at.Success && !strings.Contains(at.Msg, context.Canceled.Error()) {
+			t.Fatalf("期望其余请求被取消，实际: %+v", res.Attempts)
+		}
+	}
+}
+
+func TestGrabberService_FakeLibrary(t *testing.T) {
+	ctx := context.Background()
+	fake := fakekjyy.New()
+	defer fake.Close()
+	fake.AddUser("2023000001", "123456", "张三")
+	fake.AddUser("2023000002", "654321", "李四")
+	fake.AddRoom(testRooms[0], "南湖分馆一楼")
+	fake.AddSeat(testRooms[0], "101", "N1224")
+	fake.AddSeat(testRooms[0], "102", "N1225")
+
+	backend := crawler.NewKjyyBackendWithEndpoints(fake.Endpoints(), nil)
+	log := logger.NewZapLogger(zap.NewNop())
+	vault := newMemVault()
+	gs := newGrabber(log, backend, vault)
+
+	if _, err := gs.GetClient(ctx, "2023000001"); err == nil {
+		t.Fatalf("期望凭据库中没有凭据时返回错误")
+	}
+	_, _ = vault.CreateSession(context.Background(), "2023000001", "wrong")
+	if _, err := gs.GetClient(ctx, "2023000001"); err == nil {
+		t.Fatalf("期望密码错误时返回错误")
+	}
+	_, _ = vault.CreateSession(context.Background(), "2023000001", "123456")
+	client, err := gs.GetClient(ctx, "2023000001")
+	if err != nil {
+		t.Fatalf("期望没有错误，实际: %v", err)
+	}
+
+	// 李四已经预约了 N1224 明天上午的时间段
+	tomorrow := time.Now().AddDate(0, 0, 1)
+	day := time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), 0, 0, 0, 0, time.Local)
+	fake.Book("101", "2023000002", day.Add(8*time.Hour), day.Add(12*time.Hour))
+
+	seats, _, err := gs.FindVacantSeats(ctx, client, "09:00", "11:00", "", tomorrow, RoomFilter{})
+	if err != nil {
+		t.Fatalf("期望没有错误，实际: %v", err)
+	}
+	if len(seats) != 1 || seats[0].DevId != "102" {
+		t.Fatalf("期望只有 N1225 空闲，实际: %+v", seats)
+	}
+
+	ts, _, err := gs.SeatToName(ctx, client, "N1224", tomorrow, RoomFilter{})
+	if err != nil || len(ts) != 1 || ts[0].Owner != "李四" {
+		t.Fatalf("期望 N1224 的预约人是李四，实际: %+v, %v", ts, err)
+	}
+
+	// 座位名称不区分大小写，也可以直接使用座位ID
+	for _, name := range []string{"n1225", "102"} {
+		if dev, err := gs.ResolveSeat(ctx, client, name); err != nil || dev.DevId != "102" || dev.Room != testRooms[0] {
+			t.Fatalf("期望 %s 解析为 102，实际: %+v, %v", name, dev, err)
+		}
+	}
+	if _, err = gs.ResolveSeat(ctx, client, "N9999"); err == nil {
+		t.Fatalf("期望找不到座位时返回错误")
+	}
+	if devs, err := gs.SearchSeats(ctx, client, "n122", RoomFilter{}); err != nil || len(devs) != 2 || devs[0].Title != "N1224" {
+		t.Fatalf("期望找到 N1224 和 N1225，实际: %+v, %v", devs, err)
+	}
+
+	if ok, err := gs.Grab(ctx, client, "101", "09:00", "11:00", tomorrow); ok || err == nil {
+		t.Fatalf("期望预约冲突，实际: %v, %v", ok, err)
+	}
+	if ok, err := gs.Grab(ctx, client, "102", "09:00", "11:00", tomorrow); !ok || err != nil {
+		t.Fatalf("期望预约成功，实际: %v, %v", ok, err)
+	}
+	// 预约成功后快照失效，重新查询可以看到自己的预约
+	ts, meta, err := gs.SeatToName(ctx, client, "N1225", tomorrow, RoomFilter{})
+	if err != nil || len(ts) != 1 || ts[0].Owner != "张三" || meta.SnapshotAgeMs != 0 {
+		t.Fatalf("期望 N1225 的预约人是张三，实际: %+v, %+v, %v", ts, meta, err)
+	}
+	if ok, err := gs.GrabSuccess(ctx, client, "102", "09:00", "11:00", tomorrow); !ok || err != nil {
+		t.Fatalf("期望查询到预约记录，实际: %v, %v", ok, err)
+	}
+	// 时间段或座位不一致时不算预约成功
+	if ok, err := gs.GrabSuccess(ctx, client, "N1225", "09:00", "12:00", tomorrow); ok || err == nil {
+		t.Fatalf("期望时间段不一致时校验失败，实际: %v, %v", ok, err)
+	}
+	if ok, err := gs.GrabSuccess(ctx, client, "N1224", "09:00", "11:00", tomorrow); ok || err == nil {
+		t.Fatalf("期望座位不一致时校验失败，实际: %v, %v", ok, err)
+	}
+	rsvs, err := gs.MyReservations(ctx, client)
+	if err != nil || len(rsvs) != 1 || rsvs[0].Title != "N1225" || rsvs[0].Owner != "张三" || !rsvs[0].Reserved || rsvs[0].Effected {
+		t.Fatalf("期望张三有一条未生效的 N1225 预约，实际: %+v, %v", rsvs, err)
+	}
+
+	// 放座时刻连发：第一次请求就能预约到 N1224 明天下午的座位
+	release := time.Now().Add(500 * time.Millisecond)
+	burst, err := gs.BurstGrab(ctx, client, "101", "14:00", "16:00", release)
+	if err != nil {
+		t.Fatalf("期望没有错误，实际: %v", err)
+	}
+	if !burst.Success || len(burst.Attempts) == 0 || !burst.Attempts[0].Success {
+		t.Fatalf("期望连发抢座成功，实际: %+v", burst)
+	}
+	if burst.Attempts[0].SentMs < -1000 || burst.Attempts[0].SentMs > 1000 {
+		t.Fatalf("期望请求在放座时刻附近发出，实际: %+v", burst.Attempts[0])
+	}
+
+	// 取消明天的所有预约，李四的预约不受影响
+	canceled, err := gs.CancelReservation(ctx, client, "", tomorrow)
+	if err != nil || len(canceled) != 2 {
+		t.Fatalf("期望取消 2 条预约，实际: %v, %v", canceled, err)
+	}
+	if rsvs := fake.Reservations(); len(rsvs) != 1 || rsvs[0].UserID != "2023000002" {
+		t.Fatalf("期望只剩李四的预约，实际: %+v", rsvs)
+	}
+	if _, err = gs.CancelReservation(ctx, client, canceled[0], time.Time{}); err == nil {
+		t.Fatalf("期望取消不存在的预约时返回错误")
+	}
+
+	// session 过期后 GetClient 会重新登录
+	fake.ExpireSessions()
+	if _, err = gs.GetClient(ctx, "2023000001"); err != nil {
+		t.Fatalf("期望重新登录成功，实际: %v", err)
+	}
+}
+
+func TestGrabberService_CookiePool(t *testing.T) {
+	ctx := context.Background()
+	fake := fakekjyy.New()
+	defer fake.Close()
+	fake.AddUser("2023000001", "123456", "张三")
+
+	backend := crawler.NewKjyyBackendWithEndpoints(fake.Endpoints(), nil)
+	log := logger.NewZapLogger(zap.NewNop())
+	vault := newMemVault()
+	_, _ = vault.CreateSession(context.Background(), "2023000001", "123456")
+	gs := newGrabber(log, backend, vault)
+
+	first, err := gs.GetClient(ctx, "2023000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// cookie 仍然有效时复用同一个 client
+	if second, err := gs.GetClient(ctx, "2023000001"); err != nil || second != first {
+		t.Fatalf("期望命中 cookie 池，实际: %v", err)
+	}
+	sessions := gs.PoolSessions()
+	if len(sessions) != 1 || sessions[0].UserID != "2023000001" || sessions[0].Expired {
+		t.Fatalf("期望池中有一个会话，实际: %+v", sessions)
+	}
+
+	if err = gs.EvictSession("2023000001"); err != nil {
+		t.Fatal(err)
+	}
+	if err = gs.EvictSession("2023000001"); err == nil {
+		t.Fatalf("期望移除不存在的会话时返回错误")
+	}
+	// 移除后重新登录
+	if third, err := gs.GetClient(ctx, "2023000001"); err != nil || third == first {
+		t.Fatalf("期望重新登录，实际: %v", err)
+	}
+	if n := gs.FlushSessions(); n != 1 || len(gs.PoolSessions()) != 0 {
+		t.Fatalf("期望清空一个会话，实际: %d, %+v", n, gs.PoolSessions())
+	}
+}
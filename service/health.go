@@ -1,27 +1,111 @@
 package service
 
 import (
-	"fmt"
+	"context"
+	"net/http"
 	"os"
 	"runtime"
+	"sync"
 	"time"
 
 	"github.com/Serendipity565/GrabSeat/api/response"
+	"github.com/Serendipity565/GrabSeat/internal/data"
+	"github.com/Serendipity565/GrabSeat/service/crawler"
 	"github.com/shirou/gopsutil/v3/cpu"
 	"github.com/shirou/gopsutil/v3/disk"
 	"github.com/shirou/gopsutil/v3/mem"
 	"github.com/shirou/gopsutil/v3/process"
 )
 
+// probeTimeout 就绪检查中每个上游的超时时间
+const probeTimeout = 3 * time.Second
+
+// 服务状态
+const (
+	StatusOK          = "ok"
+	StatusDegraded    = "degraded"    // redis 不可用，以降级模式运行
+	StatusUnavailable = "unavailable" // 图书馆上游不可达，无法处理请求
+)
+
 type HealthCheckService interface {
+	// HealthCheck 返回资源使用情况和依赖的最近状态，不会实时检查依赖，图书馆上游的状态来自熔断器
 	HealthCheck() response.HealthCheckResponse
+	// Live 存活检查，只要进程能处理请求就返回 ok
+	Live() response.LiveResponse
+	// Ready 就绪检查，实时检查 redis 以及 CAS 和 kjyy 是否可达
+	Ready(ctx context.Context) response.ReadyResponse
 }
 
 type healthCheckService struct {
+	redis   *data.RedisHealth
+	backend crawler.LibraryBackend
+	client  *http.Client // 只用于检查上游是否可达，不会登录
+	started time.Time
 }
 
-func NewHealthCheckService() HealthCheckService {
-	return &healthCheckService{}
+func NewHealthCheckService(redis *data.RedisHealth, backend crawler.LibraryBackend, clients *crawler.ClientFactory) HealthCheckService {
+	return &healthCheckService{
+		redis:   redis,
+		backend: backend,
+		client:  clients.New(),
+		started: time.Now(),
+	}
+}
+
+func (h *healthCheckService) Live() response.LiveResponse {
+	return response.LiveResponse{
+		Status:        StatusOK,
+		UptimeSeconds: int64(time.Since(h.started).Seconds()),
+	}
+}
+
+func (h *healthCheckService) Ready(ctx context.Context) response.ReadyResponse {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	deps := make([]response.DependencyStatus, 3)
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		latency, err := h.redis.Ping(ctx)
+		deps[0] = dependencyStatus("redis", false, latency, err)
+	}()
+	for i, target := range []string{crawler.ProbeCAS, crawler.ProbeKjyy} {
+		go func(i int, target string) {
+			defer wg.Done()
+			start := time.Now()
+			err := h.backend.Probe(ctx, h.client, target)
+			deps[i+1] = dependencyStatus(target, true, time.Since(start), err)
+			deps[i+1].Circuit = h.backend.CircuitState(target)
+		}(i, target)
+	}
+	wg.Wait()
+
+	status := StatusOK
+	for _, dep := range deps {
+		if dep.Healthy {
+			continue
+		}
+		if dep.Required {
+			status = StatusUnavailable
+			break
+		}
+		status = StatusDegraded
+	}
+	return response.ReadyResponse{Status: status, Dependencies: deps}
+}
+
+func dependencyStatus(name string, required bool, latency time.Duration, err error) response.DependencyStatus {
+	dep := response.DependencyStatus{
+		Name:      name,
+		Healthy:   err == nil,
+		Required:  required,
+		LatencyMs: latency.Milliseconds(),
+	}
+	if err != nil {
+		dep.Error = err.Error()
+	}
+	return dep
 }
 
 func (h *healthCheckService) HealthCheck() response.HealthCheckResponse {
@@ -42,41 +126,69 @@ func (h *healthCheckService) HealthCheck() response.HealthCheckResponse {
 	}
 
 	// 当前进程资源
-	proc, err := process.NewProcess(int32(os.Getpid()))
 	var procCPU float64
-	var procMem *process.MemoryInfoStat
-	if err == nil {
+	procMem := &process.MemoryInfoStat{}
+	if proc, err := process.NewProcess(int32(os.Getpid())); err == nil {
 		procCPU, _ = proc.CPUPercent()
-		procMem, _ = proc.MemoryInfo()
-	} else {
-		procCPU = 0
-		procMem = &process.MemoryInfoStat{}
+		if info, err := proc.MemoryInfo(); err == nil {
+			procMem = info
+		}
 	}
 
 	// Go runtime 信息
 	var m runtime.MemStats
 	runtime.ReadMemStats(&m)
 
-	// 响应耗时
-	duration := time.Since(start).Milliseconds()
+	// 依赖状态，redis 不可用时服务以降级模式运行
+	status := StatusOK
+	healthy, redisErr, since := h.redis.Status()
+	redisStatus := response.DependencyStatus{
+		Name:    "redis",
+		Healthy: healthy,
+		Since:   since.Format(time.DateTime),
+	}
+	if !healthy {
+		status = StatusDegraded
+		if redisErr != nil {
+			redisStatus.Error = redisErr.Error()
+		}
+	}
+
+	deps := []response.DependencyStatus{redisStatus}
+	for _, target := range []string{crawler.ProbeCAS, crawler.ProbeKjyy} {
+		circuit := h.backend.CircuitState(target)
+		dep := response.DependencyStatus{
+			Name:     target,
+			Healthy:  circuit != crawler.CircuitOpen,
+			Required: true,
+			Circuit:  circuit,
+		}
+		if !dep.Healthy {
+			// 熔断说明上游最近连续失败，请求会直接失败
+			status = StatusUnavailable
+			dep.Error = crawler.ErrCircuitOpen.Error()
+		}
+		deps = append(deps, dep)
+	}
 
 	return response.HealthCheckResponse{
-		Status:     "ok",
-		ResponseMs: fmt.Sprintf("%d ms", duration),
+		Status:     status,
+		ResponseMs: time.Since(start).Milliseconds(),
 		System: response.SystemStats{
-			CPUPercent:    fmt.Sprintf("%.2f%%", cpuPercent[0]),
-			MemoryTotalMB: fmt.Sprintf("%d MB", vmStat.Total/1024/1024),
-			MemoryUsedMB:  fmt.Sprintf("%d MB", vmStat.Used/1024/1024),
-			MemoryPercent: fmt.Sprintf("%.2f%%", vmStat.UsedPercent),
-			DiskTotalGB:   fmt.Sprintf("%d GB", diskStat.Total/1024/1024/1024),
-			DiskUsedGB:    fmt.Sprintf("%d GB", diskStat.Used/1024/1024/1024),
-			DiskPercent:   fmt.Sprintf("%.2f%%", diskStat.UsedPercent),
+			CPUPercent:    cpuPercent[0],
+			MemoryTotalMB: vmStat.Total / 1024 / 1024,
+			MemoryUsedMB:  vmStat.Used / 1024 / 1024,
+			MemoryPercent: vmStat.UsedPercent,
+			DiskTotalGB:   diskStat.Total / 1024 / 1024 / 1024,
+			DiskUsedGB:    diskStat.Used / 1024 / 1024 / 1024,
+			DiskPercent:   diskStat.UsedPercent,
 		},
 		Process: response.ProcessStats{
-			CPUPercent:    fmt.Sprintf("%.2f%%", procCPU),
-			MemoryRSSMB:   fmt.Sprintf("%d MB", procMem.RSS/1024/1024),
-			Goroutines:    fmt.Sprintf("%d", runtime.NumGoroutine()),
-			GoHeapAllocMB: fmt.Sprintf("%d MB", m.HeapAlloc/1024/1024),
+			CPUPercent:    procCPU,
+			MemoryRSSMB:   procMem.RSS / 1024 / 1024,
+			Goroutines:    runtime.NumGoroutine(),
+			GoHeapAllocMB: m.HeapAlloc / 1024 / 1024,
 		},
+		Dependencies: deps,
 	}
 }
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Serendipity565/GrabSeat/config"
+	"github.com/Serendipity565/GrabSeat/internal/data"
+	"github.com/Serendipity565/GrabSeat/internal/fakekjyy"
+	"github.com/Serendipity565/GrabSeat/pkg/logger"
+	"github.com/Serendipity565/GrabSeat/service/crawler"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+func TestHealthCheckService_Ready(t *testing.T) {
+	fake := fakekjyy.New()
+	backend := crawler.NewKjyyBackendWithEndpoints(fake.Endpoints(), nil)
+
+	// 连不上的 redis
+	conf := &config.RedisConfig{Addr: "127.0.0.1:1", Timeout: 100, CheckInterval: 1}
+	rdb := redis.NewClient(&redis.Options{Addr: conf.Addr, DialTimeout: 100 * time.Millisecond, MaxRetries: -1})
+	hs := NewHealthCheckService(data.NewRedisHealth(rdb, conf, logger.NewZapLogger(zap.NewNop())), backend, testClients)
+
+	if live := hs.Live(); live.Status != StatusOK {
+		t.Fatalf("期望存活，实际: %+v", live)
+	}
+
+	// redis 不可用时降级，上游可达
+	ready := hs.Ready(context.Background())
+	if ready.Status != StatusDegraded || len(ready.Dependencies) != 3 {
+		t.Fatalf("期望降级，实际: %+v", ready)
+	}
+	for _, dep := range ready.Dependencies[1:] {
+		if !dep.Healthy || !dep.Required {
+			t.Fatalf("期望 %s 可达，实际: %+v", dep.Name, dep)
+		}
+	}
+
+	// 上游不可达时未就绪
+	fake.Close()
+	ready = hs.Ready(context.Background())
+	if ready.Status != StatusUnavailable || ready.Dependencies[1].Healthy || ready.Dependencies[1].Error == "" {
+		t.Fatalf("期望未就绪，实际: %+v", ready)
+	}
+}
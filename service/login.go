@@ -1,53 +1,167 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"net/http"
-	"net/http/cookiejar"
 	"strings"
-	"time"
 
-	"github.com/PuerkitoBio/goquery"
+	"github.com/Serendipity565/GrabSeat/api/response"
+	"github.com/Serendipity565/GrabSeat/config"
 	"github.com/Serendipity565/GrabSeat/errs"
+	"github.com/Serendipity565/GrabSeat/internal/data"
+	"github.com/Serendipity565/GrabSeat/pkg/ijwt"
+	"github.com/Serendipity565/GrabSeat/pkg/logger"
 	"github.com/Serendipity565/GrabSeat/service/crawler"
 )
 
 //go:generate mockgen -destination=./mocks/mock_login_service.go -package=mocks github.com/Serendipity565/GrabSeat/service LoginService
 type LoginService interface {
-	Login2CAS(username, password string) (*http.Client, error)
+	Login2CAS(ctx context.Context, username, password string) (*http.Client, error)
+	// Login 登录 CAS 成功后把凭据保存到凭据库，返回新会话的令牌
+	// 同时用 CAS 会话换取图书馆的 session 放入 cookie 池，第一次查询时不需要再次登录
+	Login(ctx context.Context, username, password string) (*response.TokenPair, error)
+	// Refresh 使用刷新令牌换取新的令牌，旧的刷新令牌随即失效
+	Refresh(ctx context.Context, refreshToken string) (*response.TokenPair, error)
+	// Logout 吊销会话
+	Logout(ctx context.Context, sessionID string) error
 }
 
 type loginService struct {
+	backend  crawler.LibraryBackend
+	vault    data.CredentialVault
+	tokens   data.TokenStore
+	jwt      *ijwt.JWT
+	deadline *config.DeadlineConfig
+	clients  *crawler.ClientFactory
+	gs       GrabberService
+	log      logger.Logger
 }
 
-func NewLoginService() LoginService {
-	return &loginService{}
+func NewLoginService(backend crawler.LibraryBackend, vault data.CredentialVault, tokens data.TokenStore, jwt *ijwt.JWT, deadline *config.DeadlineConfig, clients *crawler.ClientFactory, gs GrabberService, log logger.Logger) LoginService {
+	return &loginService{
+		backend:  backend,
+		clients:  clients,
+		gs:       gs,
+		log:      log,
+		vault:    vault,
+		tokens:   tokens,
+		jwt:      jwt,
+		deadline: deadline,
+	}
 }
 
-func (l *loginService) Login2CAS(username, password string) (*http.Client, error) {
-	jar, _ := cookiejar.New(nil)
-	client := &http.Client{
-		Jar:     jar,
-		Timeout: 10 * time.Second,
+func (l *loginService) Login(ctx context.Context, username, password string) (*response.TokenPair, error) {
+	client, err := l.Login2CAS(ctx, username, password)
+	if err != nil {
+		return nil, err
+	}
+	sessionID, err := l.vault.CreateSession(ctx, username, password)
+	if err != nil {
+		return nil, errs.InternalServerError(err)
 	}
+	l.seedLibrary(ctx, username, client)
+	return l.issue(ctx, sessionID, username, "")
+}
+
+// seedLibrary 用登录 CAS 得到的会话换取图书馆的 session 并放入 cookie 池
+// 换取失败不影响登录，之后访问图书馆时 GetClient 会重新登录
+func (l *loginService) seedLibrary(ctx context.Context, username string, client *http.Client) {
+	ctx, cancel := withDeadline(ctx, l.deadline.Exchange)
+	defer cancel()
+	if err := l.backend.ExchangeTicket(ctx, client); err != nil {
+		l.log.Warn("换取图书馆会话失败", logger.String("user", username), logger.Error(err))
+		return
+	}
+	l.gs.SeedSession(username, client)
+}
 
-	bodyBytes, err := crawler.FetchCASUrl(client, username, password)
+func (l *loginService) Refresh(ctx context.Context, refreshToken string) (*response.TokenPair, error) {
+	uc, err := l.jwt.ParseRefreshToken(refreshToken)
+	if err != nil {
+		return nil, errs.UnauthorizedError(err)
+	}
+	revoked, err := l.tokens.IsRevoked(ctx, uc.ID)
+	if err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	if revoked {
+		return nil, errs.UnauthorizedError(errors.New("session revoked"))
+	}
+	// 刷新令牌的有效期每次都会延长，会话和凭据也要一起延长，否则超过 Timeout 后访问令牌无法通过校验
+	if err = l.vault.ExtendSession(ctx, uc.ID); err != nil {
+		if errors.Is(err, data.ErrNotFound) {
+			return nil, errs.UnauthorizedError(err)
+		}
+		return nil, errs.InternalServerError(err)
+	}
+	userID, err := l.vault.Session(ctx, uc.ID)
 	if err != nil {
-		return nil, errs.CrawlerServerError(err)
+		if errors.Is(err, data.ErrNotFound) {
+			return nil, errs.UnauthorizedError(err)
+		}
+		return nil, errs.InternalServerError(err)
+	}
+	pair, err := l.issue(ctx, uc.ID, userID, uc.RefreshID)
+	if errors.Is(err, data.ErrRefreshReused) {
+		// 旧的刷新令牌被再次使用，说明令牌可能已经泄露，直接吊销整个会话
+		_ = l.Logout(ctx, uc.ID)
+		return nil, errs.UnauthorizedError(err)
 	}
+	return pair, err
+}
 
-	doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(bodyBytes)))
+func (l *loginService) Logout(ctx context.Context, sessionID string) error {
+	if err := l.tokens.Revoke(ctx, sessionID); err != nil {
+		return errs.InternalServerError(err)
+	}
+	if err := l.vault.DeleteSession(ctx, sessionID); err != nil {
+		return errs.InternalServerError(err)
+	}
+	return nil
+}
+
+// issue 签发一对新的令牌，oldRefreshID 为被替换的刷新令牌，首次签发时为空
+func (l *loginService) issue(ctx context.Context, sessionID, userID, oldRefreshID string) (*response.TokenPair, error) {
+	refreshID := newID()
+	if err := l.tokens.RotateRefresh(ctx, sessionID, oldRefreshID, refreshID); err != nil {
+		if errors.Is(err, data.ErrRefreshReused) {
+			return nil, err
+		}
+		return nil, errs.InternalServerError(err)
+	}
+	accessToken, err := l.jwt.SetJWTToken(sessionID, userID)
 	if err != nil {
 		return nil, errs.InternalServerError(err)
 	}
+	refreshToken, err := l.jwt.SetRefreshToken(sessionID, refreshID)
+	if err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	return &response.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(l.jwt.AccessExpiration().Seconds()),
+	}, nil
+}
+
+func (l *loginService) Login2CAS(ctx context.Context, username, password string) (*http.Client, error) {
+	client := l.clients.New()
+
+	ctx, cancel := withDeadline(ctx, l.deadline.Login)
+	defer cancel()
+	lr, err := l.backend.LoginCAS(ctx, client, username, password)
+	if err != nil {
+		return nil, crawlerError(err)
+	}
 
-	if errMsg := strings.TrimSpace(doc.Find("div#msg.errors").Text()); strings.Contains(errMsg, "您输入的用户名或密码有误") {
+	if strings.Contains(lr.ErrMsg, "您输入的用户名或密码有误") {
 		err = errors.New("用户名或密码错误")
 		return nil, errs.UserIdOrPasswordError(err)
-	} else if msg := strings.TrimSpace(doc.Find("#msg.success").Text()); strings.Contains(msg, "登录成功") {
+	} else if strings.Contains(lr.SuccessMsg, "登录成功") {
 		return client, nil
 	} else {
-		err = errors.New(msg)
+		err = errors.New(lr.SuccessMsg)
 		return nil, errs.UserIdOrPasswordError(err)
 	}
 }
@@ -1,19 +1,30 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"net/http"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/Serendipity565/GrabSeat/config"
+	"github.com/Serendipity565/GrabSeat/internal/data"
+	"github.com/Serendipity565/GrabSeat/internal/fakekjyy"
+	"github.com/Serendipity565/GrabSeat/pkg/ijwt"
+	"github.com/Serendipity565/GrabSeat/pkg/logger"
+	"github.com/Serendipity565/GrabSeat/service/crawler"
 	mockservice "github.com/Serendipity565/GrabSeat/service/mocks"
 	"github.com/golang/mock/gomock"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
 )
 
 var ErrLoginFailed = errors.New("登录失败，用户名或密码错误")
 
 // 辅助函数：调用 LoginService，便于测试
 func performLogin(ls LoginService, username, password string) error {
-	_, err := ls.Login2CAS(username, password)
+	_, err := ls.Login2CAS(context.Background(), username, password)
 	return err
 }
 
@@ -23,7 +34,7 @@ func TestLoginController_Login2CAS_Success(t *testing.T) {
 
 	mockLS := mockservice.NewMockLoginService(ctrl)
 	gomock.InOrder(
-		mockLS.EXPECT().Login2CAS("alice", "123456").Return(&http.Client{}, nil).Times(1),
+		mockLS.EXPECT().Login2CAS(gomock.Any(), "alice", "123456").Return(&http.Client{}, nil).Times(1),
 	)
 
 	if err := performLogin(mockLS, "alice", "123456"); err != nil {
@@ -37,10 +48,185 @@ func TestLoginController_Login2CAS_Failure(t *testing.T) {
 
 	mockLS := mockservice.NewMockLoginService(ctrl)
 	gomock.InOrder(
-		mockLS.EXPECT().Login2CAS("alice", gomock.Not("123456")).Return(nil, ErrLoginFailed).Times(1),
+		mockLS.EXPECT().Login2CAS(gomock.Any(), "alice", gomock.Not("123456")).Return(nil, ErrLoginFailed).Times(1),
 	)
 
 	if err := performLogin(mockLS, "alice", "wrongPassword"); !errors.Is(err, ErrLoginFailed) {
 		t.Fatalf("期望返回错误，实际为 nil")
 	}
 }
+
+// memTokenStore 测试用的内存令牌存储
+type memTokenStore struct {
+	mu      sync.Mutex
+	refresh map[string]string
+	revoked map[string]bool
+}
+
+func newMemTokenStore() *memTokenStore {
+	return &memTokenStore{refresh: make(map[string]string), revoked: make(map[string]bool)}
+}
+
+func (s *memTokenStore) RotateRefresh(_ context.Context, sessionID, oldID, newID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.refresh[sessionID] != oldID {
+		return data.ErrRefreshReused
+	}
+	s.refresh[sessionID] = newID
+	return nil
+}
+
+func (s *memTokenStore) Revoke(_ context.Context, sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revoked[sessionID] = true
+	delete(s.refresh, sessionID)
+	return nil
+}
+
+func (s *memTokenStore) IsRevoked(_ context.Context, sessionID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.revoked[sessionID], nil
+}
+
+func TestLoginService_Refresh(t *testing.T) {
+	ctx := context.Background()
+	fake := fakekjyy.New()
+	defer fake.Close()
+	fake.AddUser("2023000001", "123456", "张三")
+
+	tokens := newMemTokenStore()
+	jwt := ijwt.NewJWT(&config.JWTConfig{JwtKey: "test", EncKey: "test", Timeout: 3600, AccessTimeout: 60})
+	log := logger.NewZapLogger(zap.NewNop())
+	backend := crawler.NewKjyyBackendWithEndpoints(fake.Endpoints(), nil)
+	ls := NewLoginService(backend, newMemVault(), tokens, jwt, testDeadlineConfig, testClients, newGrabber(log, backend, newMemVault()), log)
+
+	pair, err := ls.Login(ctx, "2023000001", "123456")
+	if err != nil {
+		t.Fatalf("期望登录成功，实际: %v", err)
+	}
+	if _, err = jwt.ParseToken(pair.RefreshToken); err == nil {
+		t.Fatalf("期望刷新令牌不能当作访问令牌使用")
+	}
+
+	// 刷新后旧的刷新令牌失效
+	next, err := ls.Refresh(ctx, pair.RefreshToken)
+	if err != nil {
+		t.Fatalf("期望刷新成功，实际: %v", err)
+	}
+	uc, err := jwt.ParseToken(next.AccessToken)
+	if err != nil {
+		t.Fatalf("期望新的访问令牌有效，实际: %v", err)
+	}
+
+	// 重复使用旧的刷新令牌会吊销整个会话
+	if _, err = ls.Refresh(ctx, pair.RefreshToken); err == nil {
+		t.Fatalf("期望重复使用的刷新令牌被拒绝")
+	}
+	if revoked, _ := tokens.IsRevoked(context.Background(), uc.ID); !revoked {
+		t.Fatalf("期望会话被吊销")
+	}
+	if _, err = ls.Refresh(ctx, next.RefreshToken); err == nil {
+		t.Fatalf("期望会话吊销后新的刷新令牌也失效")
+	}
+}
+
+func TestLoginService_RefreshExtendsSession(t *testing.T) {
+	ctx := context.Background()
+	fake := fakekjyy.New()
+	defer fake.Close()
+	fake.AddUser("2023000001", "123456", "张三")
+
+	// redis 不可用，会话保存在本实例的内存中，有效期与 Timeout 一致
+	conf := &config.JWTConfig{JwtKey: "test", EncKey: "test", Timeout: 3, AccessTimeout: 60}
+	rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", DialTimeout: 100 * time.Millisecond, MaxRetries: -1})
+	log := logger.NewZapLogger(zap.NewNop())
+	health := data.NewRedisHealth(rdb, &config.RedisConfig{Timeout: 100, CheckInterval: 1}, log)
+	jwt := ijwt.NewJWT(conf)
+	vault := data.NewCredentialVault(rdb, jwt, conf, health)
+	backend := crawler.NewKjyyBackendWithEndpoints(fake.Endpoints(), nil)
+	ls := NewLoginService(backend, vault, newMemTokenStore(), jwt, testDeadlineConfig, testClients, newGrabber(log, backend, newMemVault()), log)
+
+	pair, err := ls.Login(ctx, "2023000001", "123456")
+	if err != nil {
+		t.Fatalf("期望登录成功，实际: %v", err)
+	}
+	// 两次刷新之间超过了会话最初的有效期
+	for range 2 {
+		time.Sleep(1600 * time.Millisecond)
+		if pair, err = ls.Refresh(ctx, pair.RefreshToken); err != nil {
+			t.Fatalf("期望刷新成功，实际: %v", err)
+		}
+	}
+	uc, err := jwt.ParseToken(pair.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userID, err := vault.Session(ctx, uc.ID); err != nil || userID != "2023000001" {
+		t.Fatalf("期望刷新后会话仍然有效，实际: %q, %v", userID, err)
+	}
+	if password, err := vault.Credential(ctx, "2023000001"); err != nil || password != "123456" {
+		t.Fatalf("期望刷新后凭据仍然有效，实际: %q, %v", password, err)
+	}
+}
+
+func TestLoginService_SeedsCookiePool(t *testing.T) {
+	ctx := context.Background()
+	fake := fakekjyy.New()
+	defer fake.Close()
+	fake.AddUser("2023000001", "123456", "张三")
+
+	log := logger.NewZapLogger(zap.NewNop())
+	backend := crawler.NewKjyyBackendWithEndpoints(fake.Endpoints(), nil)
+	// grabber 使用空的凭据库，cookie 池中没有会话时 GetClient 无法登录
+	gs := newGrabber(log, backend, newMemVault())
+	jwt := ijwt.NewJWT(&config.JWTConfig{JwtKey: "test", EncKey: "test", Timeout: 3600, AccessTimeout: 60})
+	ls := NewLoginService(backend, newMemVault(), newMemTokenStore(), jwt, testDeadlineConfig, testClients, gs, log)
+
+	if _, err := ls.Login(ctx, "2023000001", "123456"); err != nil {
+		t.Fatalf("期望登录成功，实际: %v", err)
+	}
+	if sessions := gs.PoolSessions(); len(sessions) != 1 || sessions[0].UserID != "2023000001" {
+		t.Fatalf("期望登录后 cookie 池中有会话，实际: %+v", sessions)
+	}
+	// 直接使用登录时换取的图书馆会话，不需要再次登录
+	client, err := gs.GetClient(ctx, "2023000001")
+	if err != nil {
+		t.Fatalf("期望命中 cookie 池，实际: %v", err)
+	}
+	if _, err = gs.MyReservations(ctx, client); err != nil {
+		t.Fatalf("期望图书馆会话有效，实际: %v", err)
+	}
+
+	// 没有 CAS 会话时换取失败
+	if err = backend.ExchangeTicket(ctx, testClients.New()); err == nil {
+		t.Fatalf("期望没有 CAS 会话时换取失败")
+	}
+}
+
+func TestLoginService_ExchangeDeadline(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	backend := mockservice.NewMockLibraryBackend(ctrl)
+	log := logger.NewZapLogger(zap.NewNop())
+	jwt := ijwt.NewJWT(&config.JWTConfig{JwtKey: "test", EncKey: "test", Timeout: 3600, AccessTimeout: 60})
+	deadline := &config.DeadlineConfig{Search: 5, Grab: 5, Login: 5, Exchange: 1}
+	ls := NewLoginService(backend, newMemVault(), newMemTokenStore(), jwt, deadline, testClients, newGrabber(log, backend, newMemVault()), log)
+
+	backend.EXPECT().LoginCAS(gomock.Any(), gomock.Any(), "2023000001", "123456").
+		Return(&crawler.LoginResult{SuccessMsg: "登录成功"}, nil)
+	// 换取图书馆会话使用单独的超时时间，超时后仍然返回令牌
+	backend.EXPECT().ExchangeTicket(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, _ *http.Client) error {
+		if d, ok := ctx.Deadline(); !ok || time.Until(d) > time.Second {
+			t.Errorf("期望换取图书馆会话的超时时间为 1 秒，实际: %v, %v", d, ok)
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	pair, err := ls.Login(context.Background(), "2023000001", "123456")
+	if err != nil || pair.AccessToken == "" {
+		t.Fatalf("期望换取失败时仍然登录成功，实际: %+v, %v", pair, err)
+	}
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// cookie 池被移除的原因，作为 cookie_pool_evictions_total 的 reason 标签
+const (
+	evictExpired = "expired" // 超过 ttl
+	evictInvalid = "invalid" // validateClient 检查到 cookie 已失效
+	evictAdmin   = "admin"   // 管理接口移除单个用户
+	evictFlush   = "flush"   // 管理接口清空整个池
+)
+
+var (
+	poolSize = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "cookie_pool_size",
+			Help: "Number of library sessions cached in the cookie pool",
+		},
+	)
+
+	poolHits = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "cookie_pool_hits_total",
+			Help: "Total number of GetClient calls served by a cached valid session",
+		},
+	)
+
+	poolMisses = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "cookie_pool_misses_total",
+			Help: "Total number of GetClient calls without a cached unexpired session",
+		},
+	)
+
+	poolValidateFailures = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "cookie_pool_validate_failures_total",
+			Help: "Total number of cached sessions rejected by validateClient",
+		},
+	)
+
+	poolRefreshes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cookie_pool_refreshes_total",
+			Help: "Total number of library re-logins by result",
+		},
+		[]string{"result"},
+	)
+
+	poolEvictions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cookie_pool_evictions_total",
+			Help: "Total number of sessions removed from the cookie pool by reason",
+		},
+		[]string{"reason"},
+	)
+)
+
+// RegisterMetrics 注册 cookie 池相关的监控指标
+func RegisterMetrics(reg prometheus.Registerer) {
+	reg.MustRegister(poolSize, poolHits, poolMisses, poolValidateFailures, poolRefreshes, poolEvictions)
+}
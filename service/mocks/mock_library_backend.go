@@ -0,0 +1,201 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/Serendipity565/GrabSeat/service/crawler (interfaces: LibraryBackend)
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	http "net/http"
+	reflect "reflect"
+	time "time"
+
+	response "github.com/Serendipity565/GrabSeat/api/response"
+	crawler "github.com/Serendipity565/GrabSeat/service/crawler"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockLibraryBackend is a mock of LibraryBackend interface.
+type MockLibraryBackend struct {
+	ctrl     *gomock.Controller
+	recorder *MockLibraryBackendMockRecorder
+}
+
+// MockLibraryBackendMockRecorder is the mock recorder for MockLibraryBackend.
+type MockLibraryBackendMockRecorder struct {
+	mock *MockLibraryBackend
+}
+
+// NewMockLibraryBackend creates a new mock instance.
+func NewMockLibraryBackend(ctrl *gomock.Controller) *MockLibraryBackend {
+	mock := &MockLibraryBackend{ctrl: ctrl}
+	mock.recorder = &MockLibraryBackendMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLibraryBackend) EXPECT() *MockLibraryBackendMockRecorder {
+	return m.recorder
+}
+
+// CancelReservation mocks base method.
+func (m *MockLibraryBackend) CancelReservation(arg0 context.Context, arg1 *http.Client, arg2 string) (*crawler.ActResp, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelReservation", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*crawler.ActResp)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CancelReservation indicates an expected call of CancelReservation.
+func (mr *MockLibraryBackendMockRecorder) CancelReservation(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelReservation", reflect.TypeOf((*MockLibraryBackend)(nil).CancelReservation), arg0, arg1, arg2)
+}
+
+// CircuitState mocks base method.
+func (m *MockLibraryBackend) CircuitState(arg0 string) string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CircuitState", arg0)
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// CircuitState indicates an expected call of CircuitState.
+func (mr *MockLibraryBackendMockRecorder) CircuitState(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CircuitState", reflect.TypeOf((*MockLibraryBackend)(nil).CircuitState), arg0)
+}
+
+// ExchangeTicket mocks base method.
+func (m *MockLibraryBackend) ExchangeTicket(arg0 context.Context, arg1 *http.Client) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExchangeTicket", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ExchangeTicket indicates an expected call of ExchangeTicket.
+func (mr *MockLibraryBackendMockRecorder) ExchangeTicket(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExchangeTicket", reflect.TypeOf((*MockLibraryBackend)(nil).ExchangeTicket), arg0, arg1)
+}
+
+// History mocks base method.
+func (m *MockLibraryBackend) History(arg0 context.Context, arg1 *http.Client) (*crawler.ActResp, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "History", arg0, arg1)
+	ret0, _ := ret[0].(*crawler.ActResp)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// History indicates an expected call of History.
+func (mr *MockLibraryBackendMockRecorder) History(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "History", reflect.TypeOf((*MockLibraryBackend)(nil).History), arg0, arg1)
+}
+
+// ListRooms mocks base method.
+func (m *MockLibraryBackend) ListRooms(arg0 context.Context, arg1 *http.Client) ([]crawler.Room, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListRooms", arg0, arg1)
+	ret0, _ := ret[0].([]crawler.Room)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListRooms indicates an expected call of ListRooms.
+func (mr *MockLibraryBackendMockRecorder) ListRooms(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListRooms", reflect.TypeOf((*MockLibraryBackend)(nil).ListRooms), arg0, arg1)
+}
+
+// LoginCAS mocks base method.
+func (m *MockLibraryBackend) LoginCAS(arg0 context.Context, arg1 *http.Client, arg2, arg3 string) (*crawler.LoginResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoginCAS", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(*crawler.LoginResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LoginCAS indicates an expected call of LoginCAS.
+func (mr *MockLibraryBackendMockRecorder) LoginCAS(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoginCAS", reflect.TypeOf((*MockLibraryBackend)(nil).LoginCAS), arg0, arg1, arg2, arg3)
+}
+
+// LoginLibrary mocks base method.
+func (m *MockLibraryBackend) LoginLibrary(arg0 context.Context, arg1 *http.Client, arg2, arg3 string) (*crawler.LoginResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoginLibrary", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(*crawler.LoginResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LoginLibrary indicates an expected call of LoginLibrary.
+func (mr *MockLibraryBackendMockRecorder) LoginLibrary(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoginLibrary", reflect.TypeOf((*MockLibraryBackend)(nil).LoginLibrary), arg0, arg1, arg2, arg3)
+}
+
+// Probe mocks base method.
+func (m *MockLibraryBackend) Probe(arg0 context.Context, arg1 *http.Client, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Probe", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Probe indicates an expected call of Probe.
+func (mr *MockLibraryBackendMockRecorder) Probe(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Probe", reflect.TypeOf((*MockLibraryBackend)(nil).Probe), arg0, arg1, arg2)
+}
+
+// Reserve mocks base method.
+func (m *MockLibraryBackend) Reserve(arg0 context.Context, arg1 *http.Client, arg2 string, arg3 time.Time, arg4, arg5 string) (*crawler.ActResp, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reserve", arg0, arg1, arg2, arg3, arg4, arg5)
+	ret0, _ := ret[0].(*crawler.ActResp)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Reserve indicates an expected call of Reserve.
+func (mr *MockLibraryBackendMockRecorder) Reserve(arg0, arg1, arg2, arg3, arg4, arg5 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reserve", reflect.TypeOf((*MockLibraryBackend)(nil).Reserve), arg0, arg1, arg2, arg3, arg4, arg5)
+}
+
+// SearchRoomStatus mocks base method.
+func (m *MockLibraryBackend) SearchRoomStatus(arg0 context.Context, arg1 *http.Client, arg2 string, arg3 time.Time, arg4, arg5 string) ([]response.Seat, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SearchRoomStatus", arg0, arg1, arg2, arg3, arg4, arg5)
+	ret0, _ := ret[0].([]response.Seat)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SearchRoomStatus indicates an expected call of SearchRoomStatus.
+func (mr *MockLibraryBackendMockRecorder) SearchRoomStatus(arg0, arg1, arg2, arg3, arg4, arg5 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchRoomStatus", reflect.TypeOf((*MockLibraryBackend)(nil).SearchRoomStatus), arg0, arg1, arg2, arg3, arg4, arg5)
+}
+
+// ServerTime mocks base method.
+func (m *MockLibraryBackend) ServerTime(arg0 context.Context, arg1 *http.Client) (time.Time, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ServerTime", arg0, arg1)
+	ret0, _ := ret[0].(time.Time)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ServerTime indicates an expected call of ServerTime.
+func (mr *MockLibraryBackendMockRecorder) ServerTime(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ServerTime", reflect.TypeOf((*MockLibraryBackend)(nil).ServerTime), arg0, arg1)
+}
@@ -5,9 +5,11 @@
 package mocks
 
 import (
+	context "context"
 	http "net/http"
 	reflect "reflect"
 
+	response "github.com/Serendipity565/GrabSeat/api/response"
 	gomock "github.com/golang/mock/gomock"
 )
 
@@ -34,17 +36,61 @@ func (m *MockLoginService) EXPECT() *MockLoginServiceMockRecorder {
 	return m.recorder
 }
 
+// Login mocks base method.
+func (m *MockLoginService) Login(arg0 context.Context, arg1, arg2 string) (*response.TokenPair, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Login", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*response.TokenPair)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Login indicates an expected call of Login.
+func (mr *MockLoginServiceMockRecorder) Login(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Login", reflect.TypeOf((*MockLoginService)(nil).Login), arg0, arg1, arg2)
+}
+
 // Login2CAS mocks base method.
-func (m *MockLoginService) Login2CAS(arg0, arg1 string) (*http.Client, error) {
+func (m *MockLoginService) Login2CAS(arg0 context.Context, arg1, arg2 string) (*http.Client, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Login2CAS", arg0, arg1)
+	ret := m.ctrl.Call(m, "Login2CAS", arg0, arg1, arg2)
 	ret0, _ := ret[0].(*http.Client)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Login2CAS indicates an expected call of Login2CAS.
-func (mr *MockLoginServiceMockRecorder) Login2CAS(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockLoginServiceMockRecorder) Login2CAS(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Login2CAS", reflect.TypeOf((*MockLoginService)(nil).Login2CAS), arg0, arg1, arg2)
+}
+
+// Logout mocks base method.
+func (m *MockLoginService) Logout(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Logout", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Logout indicates an expected call of Logout.
+func (mr *MockLoginServiceMockRecorder) Logout(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Logout", reflect.TypeOf((*MockLoginService)(nil).Logout), arg0, arg1)
+}
+
+// Refresh mocks base method.
+func (m *MockLoginService) Refresh(arg0 context.Context, arg1 string) (*response.TokenPair, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Refresh", arg0, arg1)
+	ret0, _ := ret[0].(*response.TokenPair)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Refresh indicates an expected call of Refresh.
+func (mr *MockLoginServiceMockRecorder) Refresh(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Login2CAS", reflect.TypeOf((*MockLoginService)(nil).Login2CAS), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Refresh", reflect.TypeOf((*MockLoginService)(nil).Refresh), arg0, arg1)
 }
@@ -1,14 +1,58 @@
 package service
 
 import (
+	"context"
+	"crypto/rand"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
 	"time"
+
+	"github.com/Serendipity565/GrabSeat/api/request"
+	"github.com/Serendipity565/GrabSeat/api/response"
+	"github.com/Serendipity565/GrabSeat/errs"
+	"github.com/Serendipity565/GrabSeat/pkg/logger"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	reserveQueueKey      = "reserve:queue"      // zset: 任务ID -> 执行时间(毫秒)
+	reserveProcessingKey = "reserve:processing" // zset: 任务ID -> 租约到期时间(毫秒)
+	reserveJobKeyPrefix  = "reserve:job:"       // string: 任务详情 JSON
+	reserveUserPrefix    = "reserve:user:"      // set: 用户的任务ID
+	reserveDoneTTL       = 7 * 24 * time.Hour   // 已结束任务的保留时间
+	reservePrewarm       = time.Minute          // 指定座位的任务提前取出，用于登录和校准时钟
+	reserveLease         = time.Minute          // 执行中任务的租约，执行期间定期续约，实例崩溃后租约过期的任务重新放回队列
+	dateLayout           = "2006-01-02"
+	clockLayout          = "15:04"
+)
+
+var (
+	//go:embed scripts/claim_job.lua
+	claimJobScriptSource string
+	//go:embed scripts/requeue_jobs.lua
+	requeueJobsScriptSource string
+
+	claimJobScript    = redis.NewScript(claimJobScriptSource)
+	requeueJobsScript = redis.NewScript(requeueJobsScriptSource)
+)
+
+const (
+	JobPending  = "pending"
+	JobRunning  = "running"
+	JobSuccess  = "success"
+	JobFailed   = "failed"
+	JobCanceled = "canceled"
 )
 
 // BeforeDate 返回前一天的 18:00
 func BeforeDate(date string) (time.Time, error) {
-	// 解析输入的日期字符串
-	layout := "2006-01-02"
-	t, err := time.Parse(layout, date)
+	// 解析输入的日期字符串，图书馆按本地时间放座
+	t, err := time.ParseInLocation(dateLayout, date, time.Local)
 	if err != nil {
 		return time.Time{}, err
 	}
@@ -20,13 +64,363 @@ func BeforeDate(date string) (time.Time, error) {
 	return targetTime, nil
 }
 
-// Reserve 预约明天座位
-// 采用 goroutine + kafka 的方式进行预约
-// 先往 sleep 一定时间，然后往 kafka 发送预约请求
-func Reserve(DevId string, delay time.Duration) {
-	go func() {
-		time.Sleep(delay)
-		// 预约座位
+// reserveJob 保存在 redis 中的预约任务
+type reserveJob struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	Date      string    `json:"date"`
+	StartTime string    `json:"start_time"`
+	EndTime   string    `json:"end_time"`
+	KeyWord   string    `json:"key_word,omitempty"`
+	SeatID    string    `json:"seat_id,omitempty"`
+	FireAt    time.Time `json:"fire_at"`
+	Status    string    `json:"status"`
+	Result    string    `json:"result,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (j *reserveJob) toResponse() response.ReserveJob {
+	return response.ReserveJob{
+		ID:        j.ID,
+		Date:      j.Date,
+		StartTime: j.StartTime,
+		EndTime:   j.EndTime,
+		KeyWord:   j.KeyWord,
+		SeatID:    j.SeatID,
+		FireAt:    j.FireAt.Format(time.DateTime),
+		Status:    j.Status,
+		Result:    j.Result,
+		CreatedAt: j.CreatedAt.Format(time.DateTime),
+	}
+}
+
+// ReserveService 预约任务队列
+// 任务持久化在 redis 中，在图书馆放座时间（前一天 18:00）由 Ticker 触发执行
+type ReserveService interface {
+	Submit(ctx context.Context, userID string, req request.ReserveReq) (*response.ReserveJob, error)
+	List(ctx context.Context, userID string) ([]response.ReserveJob, error)
+	Cancel(ctx context.Context, userID, jobID string) error
+	// Dispatch 取出所有到期的任务并异步执行，ctx 取消后正在执行的任务随之取消
+	Dispatch(ctx context.Context)
+	// Wait 等待所有正在执行的任务结束
+	Wait()
+}
+
+type reserveService struct {
+	rdb     redis.Cmdable
+	gs      GrabberService
+	topo    *Topology
+	log     logger.Logger
+	lease   time.Duration
+	running sync.WaitGroup
+}
+
+func NewReserveService(rdb redis.Cmdable, gs GrabberService, topo *Topology, log logger.Logger) ReserveService {
+	return &reserveService{
+		rdb:   rdb,
+		gs:    gs,
+		topo:  topo,
+		log:   log,
+		lease: reserveLease,
+	}
+}
+
+func (r *reserveService) Submit(ctx context.Context, userID string, req request.ReserveReq) (*response.ReserveJob, error) {
+	start, err1 := time.Parse(clockLayout, req.StartTime)
+	end, err2 := time.Parse(clockLayout, req.EndTime)
+	if err := errors.Join(err1, err2); err != nil {
+		return nil, errs.ReserveTimeError(err)
+	}
+	if !start.Before(end) {
+		return nil, errs.ReserveTimeError(fmt.Errorf("开始时间 %s 需要早于结束时间 %s", req.StartTime, req.EndTime))
+	}
+	date, err := time.ParseInLocation(dateLayout, req.Data, time.Local)
+	if err != nil {
+		return nil, errs.ReserveDateError(err)
+	}
+	if err = r.topo.CheckQueueDate(date); err != nil {
+		return nil, err
+	}
+
+	fireAt, err := BeforeDate(req.Data)
+	if err != nil {
+		return nil, errs.ReserveDateError(err)
+	}
+	now := time.Now()
+	if fireAt.Before(now) {
+		// 已经过了放座时间，立即执行
+		fireAt = now
+	}
+	// 任务在放座时执行，按执行的时间检查可预约范围
+	if err = r.topo.CheckDateAt(date, fireAt); err != nil {
+		return nil, err
+	}
+
+	job := &reserveJob{
+		ID:        newID(),
+		UserID:    userID,
+		Date:      req.Data,
+		StartTime: req.StartTime,
+		EndTime:   req.EndTime,
+		KeyWord:   req.KeyWord,
+		SeatID:    req.SeatID,
+		FireAt:    fireAt,
+		Status:    JobPending,
+		CreatedAt: now,
+	}
+
+	data, err := json.Marshal(job)
+	if err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+	// 指定了座位的任务在放座前取出，放座时刻连发抢座
+	dispatchAt := fireAt
+	if job.SeatID != "" {
+		dispatchAt = fireAt.Add(-reservePrewarm)
+	}
+	_, err = r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, reserveJobKeyPrefix+job.ID, data, 0)
+		pipe.SAdd(ctx, reserveUserPrefix+userID, job.ID)
+		pipe.ZAdd(ctx, reserveQueueKey, redis.Z{Score: float64(dispatchAt.UnixMilli()), Member: job.ID})
+		return nil
+	})
+	if err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+
+	resp := job.toResponse()
+	return &resp, nil
+}
+
+func (r *reserveService) List(ctx context.Context, userID string) ([]response.ReserveJob, error) {
+	ids, err := r.rdb.SMembers(ctx, reserveUserPrefix+userID).Result()
+	if err != nil {
+		return nil, errs.InternalServerError(err)
+	}
+
+	jobs := make([]response.ReserveJob, 0, len(ids))
+	for _, id := range ids {
+		job, err := r.load(ctx, id)
+		if errors.Is(err, redis.Nil) {
+			// 任务已过期，顺手清理索引
+			r.rdb.SRem(ctx, reserveUserPrefix+userID, id)
+			continue
+		}
+		if err != nil {
+			return nil, errs.InternalServerError(err)
+		}
+		jobs = append(jobs, job.toResponse())
+	}
+	return jobs, nil
+}
+
+func (r *reserveService) Cancel(ctx context.Context, userID, jobID string) error {
+	job, err := r.load(ctx, jobID)
+	if errors.Is(err, redis.Nil) || (err == nil && job.UserID != userID) {
+		return errs.ReserveJobNotFoundError(fmt.Errorf("job %s not found", jobID))
+	}
+	if err != nil {
+		return errs.InternalServerError(err)
+	}
+
+	// 只有还在队列中的任务才能取消，ZRem 成功说明任务尚未被取走
+	removed, err := r.rdb.ZRem(ctx, reserveQueueKey, jobID).Result()
+	if err != nil {
+		return errs.InternalServerError(err)
+	}
+	if removed == 0 {
+		return errs.ReserveJobNotFoundError(fmt.Errorf("job %s is %s", jobID, job.Status))
+	}
+	job.Status = JobCanceled
+	return r.finish(ctx, job)
+}
+
+func (r *reserveService) Dispatch(ctx context.Context) {
+	now := time.Now()
+	requeued, err := r.requeueExpired(ctx, now)
+	if err != nil {
+		r.log.Error("放回租约过期的预约任务失败", logger.Error(err))
+	} else if requeued > 0 {
+		r.log.Warn("租约过期的预约任务已放回队列", logger.Int("count", requeued))
+	}
+
+	ids, err := r.rdb.ZRangeByScore(ctx, reserveQueueKey, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(now.UnixMilli(), 10),
+	}).Result()
+	if err != nil {
+		r.log.Error("获取到期预约任务失败", logger.Error(err))
+		return
+	}
+
+	for _, id := range ids {
+		// 从待执行队列移到执行中队列成功才算抢到任务，避免多实例或多次触发时重复执行
+		claimed, err := claimJobScript.Run(ctx, r.rdb, []string{reserveQueueKey, reserveProcessingKey}, id, now.Add(r.lease).UnixMilli()).Int()
+		if err != nil || claimed == 0 {
+			continue
+		}
+		job, err := r.load(ctx, id)
+		if err != nil {
+			r.log.Error("读取预约任务失败", logger.String("job", id), logger.Error(err))
+			if errors.Is(err, redis.Nil) {
+				// 任务详情已经不存在，不需要再执行
+				r.rdb.ZRem(ctx, reserveProcessingKey, id)
+			}
+			continue
+		}
+
+		r.running.Add(1)
+		go func() {
+			defer r.running.Done()
+			r.run(ctx, job)
+		}()
+	}
+}
+
+// requeueExpired 把租约在 now 之前过期的任务放回待执行队列，执行任务的实例崩溃后租约不再续约
+func (r *reserveService) requeueExpired(ctx context.Context, now time.Time) (int, error) {
+	return requeueJobsScript.Run(ctx, r.rdb, []string{reserveQueueKey, reserveProcessingKey}, now.UnixMilli()).Int()
+}
+
+// renewLease 在任务执行期间定期续约，直到 ctx 结束
+func (r *reserveService) renewLease(ctx context.Context, jobID string) {
+	t := time.NewTicker(r.lease / 3)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			err := r.rdb.ZAddXX(ctx, reserveProcessingKey, redis.Z{Score: float64(time.Now().Add(r.lease).UnixMilli()), Member: jobID}).Err()
+			if err != nil && ctx.Err() == nil {
+				r.log.Warn("预约任务续约失败", logger.String("job", jobID), logger.Error(err))
+			}
+		}
+	}
+}
+
+func (r *reserveService) Wait() {
+	r.running.Wait()
+}
+
+// run 执行预约任务
+func (r *reserveService) run(ctx context.Context, job *reserveJob) {
+	leaseCtx, stopLease := context.WithCancel(ctx)
+	go r.renewLease(leaseCtx, job.ID)
+	defer stopLease()
+
+	job.Status = JobRunning
+	if err := r.save(ctx, job, 0); err != nil {
+		r.log.Warn("更新预约任务状态失败", logger.String("job", job.ID), logger.Error(err))
+	}
+
+	result, err := r.grab(ctx, job)
+	if ctx.Err() != nil {
+		// 服务停止时任务还没有执行完，留在执行中队列，租约过期后重新执行
+		r.log.Warn("服务停止，预约任务将在租约过期后重新执行", logger.String("job", job.ID))
+		return
+	}
+	if err != nil {
+		job.Status = JobFailed
+		job.Result = err.Error()
+	} else {
+		job.Status = JobSuccess
+		job.Result = result
+	}
+	r.log.Info("预约任务执行完成",
+		logger.String("job", job.ID),
+		logger.String("user", job.UserID),
+		logger.String("status", job.Status),
+		logger.String("result", job.Result),
+	)
+	if err = r.finish(ctx, job); err != nil {
+		r.log.Error("保存预约任务结果失败", logger.String("job", job.ID), logger.Error(err))
+		return
+	}
+	if err = r.rdb.ZRem(ctx, reserveProcessingKey, job.ID).Err(); err != nil {
+		r.log.Error("移除执行中的预约任务失败", logger.String("job", job.ID), logger.Error(err))
+	}
+}
+
+// grab 为任务对应的用户抢座，返回预约到的座位
+func (r *reserveService) grab(ctx context.Context, job *reserveJob) (string, error) {
+	date, err := time.ParseInLocation(dateLayout, job.Date, time.Local)
+	if err != nil {
+		return "", err
+	}
+	// 执行时从凭据库中读取密码登录
+	client, err := r.gs.GetClient(ctx, job.UserID)
+	if err != nil {
+		return "", err
+	}
+
+	seatID := job.SeatID
+	if seatID != "" {
+		seat, err := r.gs.ResolveSeat(ctx, client, seatID)
+		if err != nil {
+			return "", err
+		}
+		seatID = seat.DevId
+	}
+
+	if seatID != "" && time.Now().Before(job.FireAt) {
+		res, err := r.gs.BurstGrab(ctx, client, seatID, job.StartTime, job.EndTime, job.FireAt)
+		if err != nil {
+			return "", err
+		}
+		if !res.Success {
+			return "", fmt.Errorf("放座时刻连发 %d 次均未成功", len(res.Attempts))
+		}
+		return job.SeatID, nil
+	}
+
+	if seatID != "" {
+		if _, err = r.gs.Grab(ctx, client, seatID, job.StartTime, job.EndTime, date); err != nil {
+			return "", err
+		}
+		return job.SeatID, nil
+	}
+
+	res, err := r.gs.AutoGrab(ctx, client, job.StartTime, job.EndTime, job.KeyWord, date, RoomFilter{})
+	if err != nil {
+		return "", err
+	}
+	return res.Title, nil
+}
+
+func (r *reserveService) load(ctx context.Context, id string) (*reserveJob, error) {
+	data, err := r.rdb.Get(ctx, reserveJobKeyPrefix+id).Bytes()
+	if err != nil {
+		return nil, err
+	}
+	var job reserveJob
+	if err = json.Unmarshal(data, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *reserveService) save(ctx context.Context, job *reserveJob, ttl time.Duration) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return r.rdb.Set(ctx, reserveJobKeyPrefix+job.ID, data, ttl).Err()
+}
+
+// finish 保存已结束的任务，结束后的任务只保留一段时间
+func (r *reserveService) finish(ctx context.Context, job *reserveJob) error {
+	return r.save(ctx, job, reserveDoneTTL)
+}
+
+// today 返回 t 当天的 0 点
+func today(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
 
-	}()
+func newID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
 }
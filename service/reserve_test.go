@@ -0,0 +1,232 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Serendipity565/GrabSeat/api/request"
+	"github.com/Serendipity565/GrabSeat/api/response"
+	"github.com/Serendipity565/GrabSeat/pkg/errorx"
+	"github.com/Serendipity565/GrabSeat/pkg/logger"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// newTestRedis 启动一个测试用的 miniredis
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, redis.Cmdable) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = rdb.Close() })
+	return mr, rdb
+}
+
+func TestReserveService_SubmitValidation(t *testing.T) {
+	log := logger.NewZapLogger(zap.NewNop())
+	// 参数不合法时在写入 redis 之前返回
+	rs := NewReserveService(nil, nil, NewTopology(testTopologyConfig, nil, log), log)
+	tomorrow := time.Now().AddDate(0, 0, 1).Format(dateLayout)
+
+	cases := []request.ReserveReq{
+		{Data: tomorrow, StartTime: "8点", EndTime: "10:00"},
+		{Data: tomorrow, StartTime: "10:00", EndTime: "25:00"},
+		{Data: tomorrow, StartTime: "10:00", EndTime: "9:00"},
+		{Data: tomorrow, StartTime: "10:00", EndTime: "10:00"},
+		{Data: "2025/11/24", StartTime: "8:00", EndTime: "10:00"},
+		{Data: time.Now().AddDate(0, 0, -1).Format(dateLayout), StartTime: "8:00", EndTime: "10:00"},
+		{Data: time.Now().AddDate(0, 0, 10).Format(dateLayout), StartTime: "8:00", EndTime: "10:00"},
+	}
+	for _, req := range cases {
+		if _, err := rs.Submit(context.Background(), "2023000001", req); errorx.ToCustomError(err).HttpCode != http.StatusBadRequest {
+			t.Errorf("%+v: 期望返回 400，实际: %v", req, err)
+		}
+	}
+}
+
+func TestReserveService_SubmitBeyondHorizon(t *testing.T) {
+	ctx := context.Background()
+	_, rdb := newTestRedis(t)
+	log := logger.NewZapLogger(zap.NewNop())
+	rs := NewReserveService(rdb, nil, NewTopology(testTopologyConfig, nil, log), log)
+
+	// 3 天后的座位超出了今天的可预约范围，但在放座时（2 天后 18:00）可以预约
+	date := time.Now().AddDate(0, 0, 3)
+	job, err := rs.Submit(ctx, "2023000001", request.ReserveReq{Data: date.Format(dateLayout), StartTime: "9:00", EndTime: "10:00"})
+	if err != nil {
+		t.Fatalf("期望加入预约队列，实际: %v", err)
+	}
+	fireAt, _ := BeforeDate(date.Format(dateLayout))
+	if job.FireAt != fireAt.Format(time.DateTime) || job.Status != JobPending {
+		t.Fatalf("期望在 %s 执行，实际: %+v", fireAt.Format(time.DateTime), job)
+	}
+	if score, err := rdb.ZScore(ctx, reserveQueueKey, job.ID).Result(); err != nil || int64(score) != fireAt.UnixMilli() {
+		t.Fatalf("期望任务在队列中，实际: %v, %v", score, err)
+	}
+}
+
+// fakeGrabber 预约任务测试用的 GrabberService，只实现任务执行时用到的方法
+type fakeGrabber struct {
+	GrabberService
+	getClient func(ctx context.Context, userID string) (*http.Client, error)
+}
+
+func (g *fakeGrabber) GetClient(ctx context.Context, userID string) (*http.Client, error) {
+	return g.getClient(ctx, userID)
+}
+
+func (g *fakeGrabber) AutoGrab(context.Context, *http.Client, string, string, string, time.Time, RoomFilter) (*response.GrabResult, error) {
+	return &response.GrabResult{SeatID: "101", Title: "N1224", Attempts: 1}, nil
+}
+
+func newTestReserve(rdb redis.Cmdable, gs GrabberService) *reserveService {
+	log := logger.NewZapLogger(zap.NewNop())
+	return NewReserveService(rdb, gs, NewTopology(testTopologyConfig, nil, log), log).(*reserveService)
+}
+
+// submitDue 提交一个今天的任务，已经过了放座时间，立即到期
+func submitDue(t *testing.T, rs ReserveService) string {
+	job, err := rs.Submit(context.Background(), "2023000001", request.ReserveReq{Data: time.Now().Format(dateLayout), StartTime: "20:00", EndTime: "21:00"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return job.ID
+}
+
+func TestReserveService_DispatchClaimsOnce(t *testing.T) {
+	ctx := context.Background()
+	_, rdb := newTestRedis(t)
+	var calls atomic.Int32
+	gs := &fakeGrabber{getClient: func(context.Context, string) (*http.Client, error) {
+		calls.Add(1)
+		return nil, errors.New("登录失败")
+	}}
+	// 两个实例同时取任务，只有一个能取到
+	a, b := newTestReserve(rdb, gs), newTestReserve(rdb, gs)
+	id := submitDue(t, a)
+
+	var wg sync.WaitGroup
+	for _, rs := range []*reserveService{a, b, a} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rs.Dispatch(ctx)
+		}()
+	}
+	wg.Wait()
+	a.Wait()
+	b.Wait()
+
+	if calls.Load() != 1 {
+		t.Fatalf("期望任务只执行一次，实际: %d", calls.Load())
+	}
+	job, err := a.load(ctx, id)
+	if err != nil || job.Status != JobFailed {
+		t.Fatalf("期望任务失败，实际: %+v, %v", job, err)
+	}
+	if n := rdb.ZCard(ctx, reserveProcessingKey).Val(); n != 0 {
+		t.Fatalf("期望任务结束后移出执行中队列，实际还有 %d 个", n)
+	}
+}
+
+func TestReserveService_DispatchSuccess(t *testing.T) {
+	ctx := context.Background()
+	mr, rdb := newTestRedis(t)
+	rs := newTestReserve(rdb, &fakeGrabber{getClient: func(context.Context, string) (*http.Client, error) {
+		return &http.Client{}, nil
+	}})
+	id := submitDue(t, rs)
+
+	rs.Dispatch(ctx)
+	rs.Wait()
+
+	job, err := rs.load(ctx, id)
+	if err != nil || job.Status != JobSuccess || job.Result != "N1224" {
+		t.Fatalf("期望预约到 N1224，实际: %+v, %v", job, err)
+	}
+	if ttl := mr.TTL(reserveJobKeyPrefix + id); ttl <= 0 || ttl > reserveDoneTTL {
+		t.Fatalf("期望结束的任务只保留一段时间，实际 TTL: %s", ttl)
+	}
+	if rdb.ZCard(ctx, reserveQueueKey).Val() != 0 || rdb.ZCard(ctx, reserveProcessingKey).Val() != 0 {
+		t.Fatalf("期望任务已经移出队列")
+	}
+}
+
+func TestReserveService_RenewLease(t *testing.T) {
+	ctx := context.Background()
+	_, rdb := newTestRedis(t)
+	release := make(chan struct{})
+	rs := newTestReserve(rdb, &fakeGrabber{getClient: func(context.Context, string) (*http.Client, error) {
+		<-release
+		return nil, errors.New("登录失败")
+	}})
+	rs.lease = 300 * time.Millisecond
+	id := submitDue(t, rs)
+
+	rs.Dispatch(ctx)
+	first := rdb.ZScore(ctx, reserveProcessingKey, id).Val()
+	// 执行时间超过租约，期间一直续约
+	time.Sleep(2 * rs.lease)
+	renewed, err := rdb.ZScore(ctx, reserveProcessingKey, id).Result()
+	if err != nil || renewed <= first || int64(renewed) < time.Now().UnixMilli() {
+		t.Fatalf("期望租约被续期，实际: %v -> %v, %v", first, renewed, err)
+	}
+	if n, _ := rs.requeueExpired(ctx, time.Now()); n != 0 {
+		t.Fatalf("期望续约中的任务不会被放回队列，实际: %d", n)
+	}
+	close(release)
+	rs.Wait()
+}
+
+func TestReserveService_RequeueExpiredLease(t *testing.T) {
+	ctx := context.Background()
+	_, rdb := newTestRedis(t)
+	var calls atomic.Int32
+	rs := newTestReserve(rdb, &fakeGrabber{getClient: func(context.Context, string) (*http.Client, error) {
+		calls.Add(1)
+		return &http.Client{}, nil
+	}})
+	id := submitDue(t, rs)
+
+	// 模拟执行任务的实例崩溃：任务已经被取走，租约在 1 秒前过期
+	rdb.ZRem(ctx, reserveQueueKey, id)
+	rdb.ZAdd(ctx, reserveProcessingKey, redis.Z{Score: float64(time.Now().Add(-time.Second).UnixMilli()), Member: id})
+
+	if n, err := rs.requeueExpired(ctx, time.Now()); err != nil || n != 1 {
+		t.Fatalf("期望放回 1 个任务，实际: %d, %v", n, err)
+	}
+	if _, err := rdb.ZScore(ctx, reserveQueueKey, id).Result(); err != nil {
+		t.Fatalf("期望任务回到待执行队列，实际: %v", err)
+	}
+	if rdb.ZCard(ctx, reserveProcessingKey).Val() != 0 {
+		t.Fatalf("期望任务移出执行中队列")
+	}
+
+	// 放回后重新执行
+	rs.Dispatch(ctx)
+	rs.Wait()
+	if job, err := rs.load(ctx, id); calls.Load() != 1 || err != nil || job.Status != JobSuccess {
+		t.Fatalf("期望任务重新执行成功，实际: %d, %+v, %v", calls.Load(), job, err)
+	}
+}
+
+func TestReserveService_DispatchMissingJob(t *testing.T) {
+	ctx := context.Background()
+	_, rdb := newTestRedis(t)
+	rs := newTestReserve(rdb, &fakeGrabber{getClient: func(context.Context, string) (*http.Client, error) {
+		t.Error("任务详情不存在时不应该执行")
+		return nil, nil
+	}})
+
+	// 队列中有任务ID，但任务详情已经不存在
+	rdb.ZAdd(ctx, reserveQueueKey, redis.Z{Score: float64(time.Now().Add(-time.Second).UnixMilli()), Member: "missing"})
+	rs.Dispatch(ctx)
+	rs.Wait()
+	if rdb.ZCard(ctx, reserveQueueKey).Val() != 0 || rdb.ZCard(ctx, reserveProcessingKey).Val() != 0 {
+		t.Fatalf("期望丢弃没有详情的任务")
+	}
+}
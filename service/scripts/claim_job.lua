@@ -0,0 +1,11 @@
+-- KEYS[1]: 待执行的任务队列
+-- KEYS[2]: 执行中的任务队列
+-- ARGV[1]: 任务ID
+-- ARGV[2]: 租约到期时间(毫秒)
+-- 返回 1 表示取到了任务，0 表示任务已经被其他实例取走或者被取消
+
+if redis.call('ZREM', KEYS[1], ARGV[1]) == 1 then
+    redis.call('ZADD', KEYS[2], ARGV[2], ARGV[1])
+    return 1
+end
+return 0
@@ -0,0 +1,11 @@
+-- KEYS[1]: 待执行的任务队列
+-- KEYS[2]: 执行中的任务队列
+-- ARGV[1]: 当前时间(毫秒)
+-- 把租约已经过期的任务放回待执行队列，返回放回的任务数
+
+local ids = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', ARGV[1])
+for _, id in ipairs(ids) do
+    redis.call('ZREM', KEYS[2], id)
+    redis.call('ZADD', KEYS[1], ARGV[1], id)
+end
+return #ids
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Serendipity565/GrabSeat/api/response"
+	"github.com/Serendipity565/GrabSeat/config"
+	"github.com/Serendipity565/GrabSeat/internal/data"
+	"github.com/Serendipity565/GrabSeat/pkg/logger"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+)
+
+const snapshotKeyPrefix = "snapshot:" // string: 区域座位状态快照 JSON
+
+// RoomSnapshot 某个区域某一天的座位状态快照
+type RoomSnapshot struct {
+	Seats     []response.Seat `json:"seats"`
+	FetchedAt time.Time       `json:"fetched_at"`
+}
+
+// Age 快照距今的时间
+func (s *RoomSnapshot) Age() time.Duration {
+	return time.Since(s.FetchedAt)
+}
+
+// SnapshotCache 区域座位状态快照缓存
+// 同一区域同一天的座位状态对所有用户都一样，短时间内的查询共用一份快照
+type SnapshotCache interface {
+	// Get 返回快照，没有或已过期时调用 fetch 获取；同一区域同一天的并发请求只会调用一次 fetch
+	// fetch 由多个请求共用，收到的 ctx 不会因为某一个调用方取消而取消；ctx 取消时 Get 直接返回
+	Get(ctx context.Context, room string, date time.Time, fetch func(ctx context.Context) ([]response.Seat, error)) (*RoomSnapshot, error)
+	// Invalidate 清除某一天所有区域的快照，预约成功后座位状态已经改变
+	Invalidate(date time.Time)
+}
+
+type snapshotCache struct {
+	mu     sync.RWMutex
+	items  map[string]*RoomSnapshot
+	ttl    time.Duration
+	rdb    redis.Cmdable // 为 nil 时只使用内存缓存
+	health *data.RedisHealth
+	topo   *Topology
+	group  singleflight.Group
+	log    logger.Logger
+}
+
+func NewSnapshotCache(cfg *config.SearchConfig, topo *Topology, rdb redis.Cmdable, health *data.RedisHealth, log logger.Logger) SnapshotCache {
+	sc := &snapshotCache{
+		items: make(map[string]*RoomSnapshot),
+		ttl:   time.Duration(cfg.SnapshotTTL) * time.Second,
+		topo:  topo,
+		log:   log,
+	}
+	if cfg.SnapshotRedis {
+		sc.rdb = rdb
+		sc.health = health
+	}
+	return sc
+}
+
+func snapshotKey(room string, date time.Time) string {
+	return room + ":" + date.Format(dateLayout)
+}
+
+func (s *snapshotCache) Get(ctx context.Context, room string, date time.Time, fetch func(ctx context.Context) ([]response.Seat, error)) (*RoomSnapshot, error) {
+	key := snapshotKey(room, date)
+	if snap := s.load(ctx, key); snap != nil {
+		return snap, nil
+	}
+
+	shared := context.WithoutCancel(ctx)
+	ch := s.group.DoChan(key, func() (interface{}, error) {
+		// 等待期间可能已经有其他请求更新了快照
+		if snap := s.load(shared, key); snap != nil {
+			return snap, nil
+		}
+		seats, err := fetch(shared)
+		if err != nil {
+			return nil, err
+		}
+		snap := &RoomSnapshot{Seats: seats, FetchedAt: time.Now()}
+		s.store(shared, key, snap)
+		return snap, nil
+	})
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(*RoomSnapshot), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *snapshotCache) Invalidate(date time.Time) {
+	rooms := s.topo.RoomIDs()
+	keys := make([]string, 0, len(rooms))
+	for _, room := range rooms {
+		keys = append(keys, snapshotKey(room, date))
+	}
+
+	s.mu.Lock()
+	for _, key := range keys {
+		delete(s.items, key)
+	}
+	s.mu.Unlock()
+
+	if !s.useRedis() {
+		return
+	}
+	redisKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		redisKeys = append(redisKeys, snapshotKeyPrefix+key)
+	}
+	if err := s.rdb.Del(context.Background(), redisKeys...).Err(); err != nil {
+		s.health.Fail(err)
+		s.log.Warn("清除座位快照失败", logger.Error(err))
+	}
+}
+
+// load 依次从内存和 redis 中读取未过期的快照
+func (s *snapshotCache) load(ctx context.Context, key string) *RoomSnapshot {
+	s.mu.RLock()
+	snap, ok := s.items[key]
+	s.mu.RUnlock()
+	if ok && snap.Age() < s.ttl {
+		return snap
+	}
+	if !s.useRedis() {
+		return nil
+	}
+
+	b, err := s.rdb.Get(ctx, snapshotKeyPrefix+key).Bytes()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			s.health.Fail(err)
+			s.log.Warn("读取座位快照失败", logger.String("key", key), logger.Error(err))
+		}
+		return nil
+	}
+	snap = &RoomSnapshot{}
+	if err = json.Unmarshal(b, snap); err != nil || snap.Age() >= s.ttl {
+		return nil
+	}
+	s.mu.Lock()
+	s.items[key] = snap
+	s.mu.Unlock()
+	return snap
+}
+
+func (s *snapshotCache) store(ctx context.Context, key string, snap *RoomSnapshot) {
+	s.mu.Lock()
+	s.items[key] = snap
+	s.mu.Unlock()
+	if !s.useRedis() {
+		return
+	}
+
+	b, err := json.Marshal(snap)
+	if err != nil {
+		return
+	}
+	if err = s.rdb.Set(ctx, snapshotKeyPrefix+key, b, s.ttl).Err(); err != nil {
+		s.health.Fail(err)
+		s.log.Warn("保存座位快照失败", logger.String("key", key), logger.Error(err))
+	}
+}
+
+// useRedis 是否读写 redis 中的快照，redis 不可用时只使用内存缓存
+func (s *snapshotCache) useRedis() bool {
+	return s.rdb != nil && s.health.Healthy()
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Serendipity565/GrabSeat/api/response"
+	"github.com/Serendipity565/GrabSeat/config"
+	"github.com/Serendipity565/GrabSeat/pkg/logger"
+	"go.uber.org/zap"
+)
+
+func TestSnapshotCache_Get(t *testing.T) {
+	ctx := context.Background()
+	sc := NewSnapshotCache(&config.SearchConfig{SnapshotTTL: 10}, NewTopology(testTopologyConfig, nil, nil), nil, nil, logger.NewZapLogger(zap.NewNop()))
+	date := time.Now()
+
+	var calls atomic.Int32
+	fetch := func(context.Context) ([]response.Seat, error) {
+		calls.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		return []response.Seat{{Title: "N1224", DevId: "101"}}, nil
+	}
+
+	// 同一区域同一天的并发请求只获取一次
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			snap, err := sc.Get(ctx, testRooms[0], date, fetch)
+			if err != nil || len(snap.Seats) != 1 {
+				t.Errorf("期望返回快照，实际: %+v, %v", snap, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls.Load() != 1 {
+		t.Fatalf("期望只获取一次，实际: %d", calls.Load())
+	}
+
+	// 不同日期的快照互不影响
+	if _, err := sc.Get(ctx, testRooms[0], date.AddDate(0, 0, 1), fetch); err != nil || calls.Load() != 2 {
+		t.Fatalf("期望重新获取明天的快照，实际: %d, %v", calls.Load(), err)
+	}
+
+	// 失效后重新获取
+	sc.Invalidate(date)
+	if _, err := sc.Get(ctx, testRooms[0], date, fetch); err != nil || calls.Load() != 3 {
+		t.Fatalf("期望失效后重新获取，实际: %d, %v", calls.Load(), err)
+	}
+}
+
+func TestSnapshotCache_GetCanceled(t *testing.T) {
+	sc := NewSnapshotCache(&config.SearchConfig{SnapshotTTL: 10}, NewTopology(testTopologyConfig, nil, nil), nil, nil, logger.NewZapLogger(zap.NewNop()))
+	date := time.Now()
+
+	release := make(chan struct{})
+	fetch := func(ctx context.Context) ([]response.Seat, error) {
+		<-release
+		// 共用的获取不会因为某一个调用方取消而取消
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return []response.Seat{{Title: "N1224", DevId: "101"}}, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := sc.Get(ctx, testRooms[0], date, fetch)
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("期望调用方取消后立即返回，实际: %v", err)
+	}
+
+	// 其他等待同一份快照的请求仍然能拿到结果
+	result := make(chan error, 1)
+	go func() {
+		_, err := sc.Get(context.Background(), testRooms[0], date, fetch)
+		result <- err
+	}()
+	close(release)
+	if err := <-result; err != nil {
+		t.Fatalf("期望拿到快照，实际: %v", err)
+	}
+}
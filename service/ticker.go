@@ -1,30 +1,64 @@
 package service
 
 import (
+	"context"
+	"fmt"
+
+	"github.com/Serendipity565/GrabSeat/config"
 	"github.com/robfig/cron/v3"
 )
 
 type Ticker struct {
-	c *cron.Cron
+	c      *cron.Cron
+	rs     ReserveService
+	gs     GrabberService
+	search *config.SearchConfig
+	ctx    context.Context // 预约任务的 ctx，等待任务结束超时后取消
+	cancel context.CancelFunc
 }
 
-func NewTicker() *Ticker {
+func NewTicker(rs ReserveService, gs GrabberService, search *config.SearchConfig) *Ticker {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &Ticker{
-		c: cron.New(cron.WithSeconds()),
+		c:      cron.New(cron.WithSeconds()),
+		rs:     rs,
+		gs:     gs,
+		search: search,
+		ctx:    ctx,
+		cancel: cancel,
 	}
 }
 
-// StartDailyTask 定时器服务
+// Start 定时器服务
 // cron 本身是异步的，不需要阻塞
-//func (t Ticker) StartDailyTask() {
-//	// 每天18:00执行
-//	spec := "0 0 18 * * *"
-//	_, errs := t.c.AddFunc(spec, task)
-//	if errs != nil {
-//		fmt.Println("定时任务添加失败:", errs)
-//		return
-//	}
-//
-//	t.c.Start()
-//	fmt.Println("✅ 定时任务已启动，每天18:00执行")
-//}
+func (t *Ticker) Start() error {
+	// 每秒检查一次到期的预约任务
+	if _, err := t.c.AddFunc("@every 1s", func() { t.rs.Dispatch(t.ctx) }); err != nil {
+		return err
+	}
+	// 定期刷新座位名称索引，移除上游已经没有的座位
+	if _, err := t.c.AddFunc(fmt.Sprintf("@every %ds", t.search.DeviceRefresh), func() { t.gs.RefreshDevices(t.ctx) }); err != nil {
+		return err
+	}
+
+	t.c.Start()
+	return nil
+}
+
+// Stop 停止调度新的任务，并等待正在执行的预约任务结束
+// ctx 超时后取消未完成的任务并直接返回
+func (t *Ticker) Stop(ctx context.Context) error {
+	defer t.cancel()
+	done := make(chan struct{})
+	go func() {
+		<-t.c.Stop().Done()
+		t.rs.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Serendipity565/GrabSeat/api/response"
+	"github.com/Serendipity565/GrabSeat/config"
+	"github.com/Serendipity565/GrabSeat/errs"
+	"github.com/Serendipity565/GrabSeat/pkg/logger"
+	"github.com/Serendipity565/GrabSeat/service/crawler"
+	"golang.org/x/sync/singleflight"
+)
+
+// discoverRetry 发现房间失败后，多久之后再重试
+const discoverRetry = time.Minute
+
+// RoomFilter 查询时的房间过滤条件，都为空时查询所有房间
+type RoomFilter struct {
+	Room  string // 房间ID或房间名称
+	Floor string // 楼层
+}
+
+func (f RoomFilter) match(room response.Room) bool {
+	if f.Room != "" && f.Room != room.ID && f.Room != room.Name {
+		return false
+	}
+	return f.Floor == "" || f.Floor == room.Floor
+}
+
+// Topology 图书馆的房间列表和可预约的日期范围
+// 房间来自 TopologyConfig，开启 Discover 时定期从 kjyy 的房间列表接口重新获取，配置中的同一房间用于补充分馆、楼层和开放时间
+type Topology struct {
+	conf    *config.TopologyConfig
+	backend crawler.LibraryBackend
+	log     logger.Logger
+
+	mu           sync.RWMutex
+	rooms        []response.Room
+	nextDiscover time.Time // 下一次需要重新发现房间的时间
+	group        singleflight.Group
+}
+
+func NewTopology(conf *config.TopologyConfig, backend crawler.LibraryBackend, log logger.Logger) *Topology {
+	rooms := make([]response.Room, 0, len(conf.Rooms))
+	for _, rc := range conf.Rooms {
+		rooms = append(rooms, roomOf(rc))
+	}
+	return &Topology{
+		conf:    conf,
+		backend: backend,
+		log:     log,
+		rooms:   rooms,
+	}
+}
+
+func roomOf(rc config.RoomConfig) response.Room {
+	return response.Room{
+		ID:        rc.ID,
+		Name:      rc.Name,
+		Branch:    rc.Branch,
+		Floor:     rc.Floor,
+		OpenTime:  rc.OpenTime,
+		CloseTime: rc.CloseTime,
+	}
+}
+
+// Rooms 返回房间列表，需要重新发现房间时使用 client 访问 kjyy
+// 发现失败时继续使用上一次的房间列表，只有一个房间都没有时才返回错误
+func (t *Topology) Rooms(ctx context.Context, client *http.Client) ([]response.Room, error) {
+	t.mu.RLock()
+	rooms, stale := t.rooms, t.conf.Discover && time.Now().After(t.nextDiscover)
+	t.mu.RUnlock()
+	if !stale {
+		return rooms, nil
+	}
+
+	ch := t.group.DoChan("rooms", func() (interface{}, error) {
+		return t.discover(context.WithoutCancel(ctx), client)
+	})
+	select {
+	case res := <-ch:
+		if res.Err == nil {
+			return res.Val.([]response.Room), nil
+		}
+		if len(rooms) == 0 {
+			return nil, crawlerError(res.Err)
+		}
+		return rooms, nil
+	case <-ctx.Done():
+		if len(rooms) == 0 {
+			return nil, crawlerError(ctx.Err())
+		}
+		return rooms, nil
+	}
+}
+
+// discover 从 kjyy 获取房间列表并与配置合并，以上游的房间为准
+func (t *Topology) discover(ctx context.Context, client *http.Client) ([]response.Room, error) {
+	found, err := t.backend.ListRooms(ctx, client)
+	if err == nil && len(found) == 0 {
+		err = errors.New("kjyy 返回的房间列表为空")
+	}
+	if err != nil {
+		t.mu.Lock()
+		t.nextDiscover = time.Now().Add(min(discoverRetry, time.Duration(t.conf.DiscoverInterval)*time.Second))
+		t.mu.Unlock()
+		t.log.Warn("发现房间失败，继续使用当前的房间列表", logger.Error(err))
+		return nil, err
+	}
+
+	configured := make(map[string]config.RoomConfig, len(t.conf.Rooms))
+	for _, rc := range t.conf.Rooms {
+		configured[rc.ID] = rc
+	}
+	rooms := make([]response.Room, 0, len(found))
+	for _, r := range found {
+		rc, ok := configured[r.ID]
+		if !ok {
+			rc = config.RoomConfig{ID: r.ID, OpenTime: t.conf.OpenTime, CloseTime: t.conf.CloseTime}
+		}
+		room := roomOf(rc)
+		if room.Name == "" {
+			room.Name = r.Name
+		}
+		if room.Branch == "" {
+			room.Branch = r.Branch
+		}
+		rooms = append(rooms, room)
+	}
+
+	t.mu.Lock()
+	t.rooms = rooms
+	t.nextDiscover = time.Now().Add(time.Duration(t.conf.DiscoverInterval) * time.Second)
+	t.mu.Unlock()
+	return rooms, nil
+}
+
+// Select 返回符合过滤条件的房间，没有符合条件的房间时返回 RoomNotFoundError
+func (t *Topology) Select(ctx context.Context, client *http.Client, filter RoomFilter) ([]response.Room, error) {
+	rooms, err := t.Rooms(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	selected := make([]response.Room, 0, len(rooms))
+	for _, room := range rooms {
+		if filter.match(room) {
+			selected = append(selected, room)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, errs.RoomNotFoundError(fmt.Errorf("no room matches room=%q floor=%q", filter.Room, filter.Floor))
+	}
+	return selected, nil
+}
+
+// RoomIDs 当前已知的所有房间ID，不会访问 kjyy
+func (t *Topology) RoomIDs() []string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	ids := make([]string, 0, len(t.rooms))
+	for _, room := range t.rooms {
+		ids = append(ids, room.ID)
+	}
+	return ids
+}
+
+// CheckDate 日期需要在今天到今天之后 HorizonDays 天之间
+func (t *Topology) CheckDate(date time.Time) error {
+	return t.CheckDateAt(date, time.Now())
+}
+
+// CheckDateAt 日期需要在 at 当天到之后 HorizonDays 天之间，预约任务按执行的时间检查
+func (t *Topology) CheckDateAt(date, at time.Time) error {
+	from := today(at)
+	day := today(date)
+	if day.Before(from) || day.After(from.AddDate(0, 0, t.conf.HorizonDays)) {
+		return errs.ReserveDateError(fmt.Errorf("%s 不在可预约范围内，最多可以预约 %d 天后的座位", day.Format(dateLayout), t.conf.HorizonDays))
+	}
+	return nil
+}
+
+// CheckQueueDate 预约任务的日期不能早于今天，最多提前 QueueDays 天提交
+func (t *Topology) CheckQueueDate(date time.Time) error {
+	now := today(time.Now())
+	day := today(date)
+	if day.Before(now) || day.After(now.AddDate(0, 0, t.conf.QueueDays)) {
+		return errs.ReserveDateError(fmt.Errorf("%s 不在可提交的范围内，最多可以提前 %d 天提交预约任务", day.Format(dateLayout), t.conf.QueueDays))
+	}
+	return nil
+}
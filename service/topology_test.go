@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Serendipity565/GrabSeat/config"
+	"github.com/Serendipity565/GrabSeat/pkg/errorx"
+	"github.com/Serendipity565/GrabSeat/pkg/logger"
+	"github.com/Serendipity565/GrabSeat/service/crawler"
+	mockservice "github.com/Serendipity565/GrabSeat/service/mocks"
+	"github.com/golang/mock/gomock"
+	"go.uber.org/zap"
+)
+
+func TestTopology_Discover(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	backend := mockservice.NewMockLibraryBackend(ctrl)
+
+	conf := &config.TopologyConfig{
+		OpenTime:         "8:00",
+		CloseTime:        "22:00",
+		HorizonDays:      2,
+		Discover:         true,
+		DiscoverInterval: 3600,
+		Rooms: []config.RoomConfig{
+			{ID: "1", Floor: "1F", OpenTime: "7:30", CloseTime: "22:30"},
+			{ID: "9", Floor: "9F", OpenTime: "8:00", CloseTime: "22:00"}, // 已经不在上游的房间
+		},
+	}
+	topo := NewTopology(conf, backend, logger.NewZapLogger(zap.NewNop()))
+
+	// 只在第一次查询时发现房间
+	backend.EXPECT().ListRooms(gomock.Any(), gomock.Any()).Return([]crawler.Room{
+		{ID: "1", Name: "一楼自习室", Branch: "南湖分馆"},
+		{ID: "2", Name: "二楼自习室", Branch: "南湖分馆"},
+	}, nil)
+	rooms, err := topo.Rooms(ctx, &http.Client{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rooms) != 2 || rooms[0].Name != "一楼自习室" || rooms[0].Floor != "1F" || rooms[0].OpenTime != "7:30" || rooms[1].OpenTime != "8:00" {
+		t.Fatalf("期望上游的房间合并配置中的楼层和开放时间，实际: %+v", rooms)
+	}
+
+	// 按房间名称和楼层过滤
+	if got, err := topo.Select(ctx, &http.Client{}, RoomFilter{Room: "二楼自习室"}); err != nil || len(got) != 1 || got[0].ID != "2" {
+		t.Fatalf("期望按名称选中房间 2，实际: %+v, %v", got, err)
+	}
+	if got, err := topo.Select(ctx, &http.Client{}, RoomFilter{Floor: "1F"}); err != nil || len(got) != 1 || got[0].ID != "1" {
+		t.Fatalf("期望按楼层选中房间 1，实际: %+v, %v", got, err)
+	}
+	_, err = topo.Select(ctx, &http.Client{}, RoomFilter{Floor: "9F"})
+	if errorx.ToCustomError(err).HttpCode != http.StatusNotFound {
+		t.Fatalf("期望没有符合条件的房间时返回 404，实际: %v", err)
+	}
+
+	// 日期需要在今天到 HorizonDays 天之后之间
+	now := time.Now()
+	if err = topo.CheckDate(now.AddDate(0, 0, 2)); err != nil {
+		t.Fatalf("期望 2 天后可以预约，实际: %v", err)
+	}
+	for _, date := range []time.Time{now.AddDate(0, 0, -1), now.AddDate(0, 0, 3)} {
+		if err = topo.CheckDate(date); errorx.ToCustomError(err).HttpCode != http.StatusBadRequest {
+			t.Fatalf("期望 %s 不在可预约范围内，实际: %v", date.Format(dateLayout), err)
+		}
+	}
+}
+
+func TestTopology_DiscoverFailure(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	backend := mockservice.NewMockLibraryBackend(ctrl)
+
+	conf := *testTopologyConfig
+	conf.Discover = true
+	topo := NewTopology(&conf, backend, logger.NewZapLogger(zap.NewNop()))
+
+	// 发现失败时继续使用配置中的房间，一分钟内不再重试
+	backend.EXPECT().ListRooms(gomock.Any(), gomock.Any()).Return(nil, errors.New("timeout"))
+	for range 2 {
+		if rooms, err := topo.Rooms(ctx, &http.Client{}); err != nil || len(rooms) != len(testRooms) {
+			t.Fatalf("期望使用配置中的房间，实际: %+v, %v", rooms, err)
+		}
+	}
+
+	// 没有任何房间时返回错误
+	empty := NewTopology(&config.TopologyConfig{Discover: true, DiscoverInterval: 3600}, backend, logger.NewZapLogger(zap.NewNop()))
+	backend.EXPECT().ListRooms(gomock.Any(), gomock.Any()).Return(nil, errors.New("timeout"))
+	if _, err := empty.Rooms(ctx, &http.Client{}); err == nil {
+		t.Fatalf("期望没有房间时返回错误")
+	}
+}
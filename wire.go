@@ -5,11 +5,13 @@ package main
 import (
 	"github.com/Serendipity565/GrabSeat/config"
 	"github.com/Serendipity565/GrabSeat/controller"
+	"github.com/Serendipity565/GrabSeat/internal/data"
 	"github.com/Serendipity565/GrabSeat/ioc"
 	"github.com/Serendipity565/GrabSeat/middleware"
 	"github.com/Serendipity565/GrabSeat/pkg/ijwt"
 	"github.com/Serendipity565/GrabSeat/pkg/logger"
 	"github.com/Serendipity565/GrabSeat/service"
+	"github.com/Serendipity565/GrabSeat/service/crawler"
 	"github.com/google/wire"
 )
 
@@ -18,6 +20,7 @@ func InitApp() *App {
 		wire.Struct(new(App), "*"),
 		config.ProviderSet,
 		ioc.ProviderSet,
+		data.ProviderSet,
 		ijwt.NewJWT,
 		logger.NewZapLogger,
 		middleware.NewCorsMiddleware,
@@ -27,6 +30,7 @@ func InitApp() *App {
 		middleware.NewPrometheusMiddleware,
 		middleware.NewLimitMiddleware,
 		service.ProviderSet,
+		crawler.ProviderSet,
 		controller.ProviderSet,
 	)
 	return &App{}
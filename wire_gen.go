@@ -9,44 +9,68 @@ package main
 import (
 	"github.com/Serendipity565/GrabSeat/config"
 	"github.com/Serendipity565/GrabSeat/controller"
+	"github.com/Serendipity565/GrabSeat/internal/data"
 	"github.com/Serendipity565/GrabSeat/ioc"
 	"github.com/Serendipity565/GrabSeat/middleware"
 	"github.com/Serendipity565/GrabSeat/pkg/ijwt"
 	"github.com/Serendipity565/GrabSeat/pkg/logger"
 	"github.com/Serendipity565/GrabSeat/service"
+	"github.com/Serendipity565/GrabSeat/service/crawler"
 )
 
 // Injectors from wire.go:
 
 func InitApp() *App {
-	healthCheckService := service.NewHealthCheckService()
+	redisConfig := config.NewRedisConfig()
+	cmdable := ioc.InitRedis(redisConfig)
+	logConfig := config.NewLogConfig()
+	zapLogger := ioc.InitLogger(logConfig)
+	loggerLogger := logger.NewZapLogger(zapLogger)
+	redisHealth := data.NewRedisHealth(cmdable, redisConfig, loggerLogger)
+	registry := ioc.InitPrometheus()
+	upstreamConfig := config.NewUpstreamConfig()
+	libraryConfig := config.NewLibraryConfig()
+	libraryBackend := crawler.NewKjyyBackend(registry, upstreamConfig, libraryConfig)
+	clientFactory := ioc.InitClientFactory(libraryConfig)
+	healthCheckService := service.NewHealthCheckService(redisHealth, libraryBackend, clientFactory)
 	healthCheckController := controller.NewHealthCheckController(healthCheckService)
 	jwtConfig := config.NewJWTConfig()
 	jwt := ijwt.NewJWT(jwtConfig)
-	loginService := service.NewLoginService()
+	credentialVault := data.NewCredentialVault(cmdable, jwt, jwtConfig, redisHealth)
+	tokenStore := data.NewTokenStore(cmdable, jwtConfig, redisHealth)
+	deadlineConfig := config.NewDeadlineConfig()
+	burstConfig := config.NewBurstConfig()
+	searchConfig := config.NewSearchConfig()
+	topologyConfig := config.NewTopologyConfig()
+	topology := service.NewTopology(topologyConfig, libraryBackend, loggerLogger)
+	snapshotCache := service.NewSnapshotCache(searchConfig, topology, cmdable, redisHealth, loggerLogger)
+	grabberService := service.NewGrabberService(loggerLogger, libraryBackend, credentialVault, burstConfig, searchConfig, deadlineConfig, snapshotCache, topology, clientFactory, registry)
+	loginService := service.NewLoginService(libraryBackend, credentialVault, tokenStore, jwt, deadlineConfig, clientFactory, grabberService, loggerLogger)
 	loginController := controller.NewLoginController(jwt, loginService)
-	logConfig := config.NewLogConfig()
-	zapLogger := ioc.InitLogger(logConfig)
-	loggerLogger := logger.NewZapLogger(zapLogger)
-	grabberService := service.NewGrabberService(loggerLogger)
 	garbController := controller.NewGarbHandler(grabberService)
+	reserveService := service.NewReserveService(cmdable, grabberService, topology, loggerLogger)
+	reserveController := controller.NewReserveHandler(reserveService)
+	adminController := controller.NewAdminController(grabberService)
 	middlewareConfig := config.NewMiddlewareConfig()
 	corsMiddleware := middleware.NewCorsMiddleware(middlewareConfig)
-	authMiddleware := middleware.NewAuthMiddleware(jwt)
+	authMiddleware := middleware.NewAuthMiddleware(jwt, credentialVault, tokenStore)
 	v := config.NewBasicAuthConfig()
 	basicAuthMiddleware := middleware.NewBasicAuthMiddleware(v)
 	loggerMiddleware := middleware.NewLoggerMiddleware(loggerLogger)
 	limiterConfig := config.NewLimiterConfig()
-	redisConfig := config.NewRedisConfig()
-	cmdable := ioc.InitRedis(redisConfig)
-	limitMiddleware := middleware.NewLimitMiddleware(limiterConfig, cmdable)
-	registry := ioc.InitPrometheus()
+	limitMiddleware := middleware.NewLimitMiddleware(limiterConfig, cmdable, jwt, redisHealth)
 	prometheusMiddleware := middleware.NewPrometheusMiddleware(registry)
-	engine := controller.NewGinEngine(healthCheckController, loginController, garbController, corsMiddleware, authMiddleware, basicAuthMiddleware, loggerMiddleware, limitMiddleware, prometheusMiddleware)
-	ticker := service.NewTicker()
+	engine := controller.NewGinEngine(healthCheckController, loginController, garbController, reserveController, adminController, corsMiddleware, authMiddleware, basicAuthMiddleware, loggerMiddleware, limitMiddleware, prometheusMiddleware)
+	serverConfig := config.NewServerConfig(burstConfig)
+	server := ioc.InitServer(serverConfig, engine)
+	ticker := service.NewTicker(reserveService, grabberService, searchConfig)
 	app := &App{
-		r: engine,
-		t: ticker,
+		srv:  server,
+		conf: serverConfig,
+		t:    ticker,
+		h:    redisHealth,
+		gs:   grabberService,
+		l:    loggerLogger,
 	}
 	return app
 }